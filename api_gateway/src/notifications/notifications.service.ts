@@ -91,7 +91,7 @@ export class NotificationsService {
             body: undefined,     // Let Email Service render with variables
             template_code: dto.template_code,
             variables: dto.variables,
-            priority: dto.priority || 1,
+            priority: dto.priority ?? 2,  // Unset is normal priority, not high
             metadata: {
                 timestamp: new Date().toISOString(),
                 retry_count: 0,
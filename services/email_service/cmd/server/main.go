@@ -2,21 +2,32 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/circuit"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/digest"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/handler"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/health"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/idempotency"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/queue"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/ratelimit"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/retry"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/scheduler"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/throttle"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/tracking"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/unsubscribe"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/validation"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -58,27 +69,112 @@ func main() {
 	idempotencyTTL := time.Duration(24) * time.Hour
 	idempotencyChecker := idempotency.NewChecker(redisClient, idempotencyTTL)
 	retryHandler := retry.NewHandler(cfg.Retry.MaxAttempts, cfg.Retry.BackoffBase)
-	circuitBreaker := circuit.NewBreaker(
-		"email-sender",
-		1,
-		0,
+	suppressions, err := suppression.NewStore(cfg.Email.Suppression.Store, redisClient)
+	if err != nil {
+		logger.Log.Fatal("failed to create suppression store", zap.Error(err))
+	}
+	// One-click unsubscribe for non-transactional mail
+	var unsubscribeSigner *unsubscribe.Signer
+	if cfg.Email.Unsubscribe.Secret != "" && cfg.Email.Unsubscribe.BaseURL != "" {
+		unsubscribeSigner, err = unsubscribe.NewSigner(
+			cfg.Email.Unsubscribe.Secret,
+			time.Duration(cfg.Email.Unsubscribe.TokenTTL)*time.Hour,
+			cfg.Email.Unsubscribe.BaseURL,
+			cfg.Email.Unsubscribe.Mailto,
+		)
+		if err != nil {
+			logger.Log.Fatal("failed to configure unsubscribe links", zap.Error(err))
+		}
+	}
+	// Open and click tracking for notifications that opt in
+	var tracker *tracking.Tracker
+	if cfg.Email.Tracking.Secret != "" && cfg.Email.Tracking.BaseURL != "" {
+		tracker, err = tracking.NewTracker(cfg.Email.Tracking.Secret, cfg.Email.Tracking.BaseURL)
+		if err != nil {
+			logger.Log.Fatal("failed to configure tracking", zap.Error(err))
+		}
+	}
+	// Recipient validation: syntax, disposable domains and optional MX lookup
+	var disposableDomains []string
+	if cfg.Email.Validation.DisposableFile != "" {
+		disposableDomains, err = validation.LoadDomainList(cfg.Email.Validation.DisposableFile)
+		if err != nil {
+			logger.Log.Fatal("failed to load disposable domains", zap.Error(err))
+		}
+	}
+	var resolver validation.Resolver
+	if cfg.Email.Validation.CheckMX {
+		resolver = net.DefaultResolver
+	}
+	validator := validation.NewValidator(
+		cfg.Email.Validation.BlockDisposable,
+		disposableDomains,
+		resolver,
+		time.Duration(cfg.Email.Validation.MXTimeout)*time.Second,
+	)
+	// Per-recipient-domain send limits shared through Redis
+	var domainThrottle *throttle.DomainThrottle
+	if len(cfg.Email.Throttle.DomainLimits) > 0 || cfg.Email.Throttle.DefaultLimit != "" {
+		domainLimits, err := throttle.ParseDomainLimits(cfg.Email.Throttle.DomainLimits)
+		if err != nil {
+			logger.Log.Fatal("invalid THROTTLE_DOMAIN_LIMITS", zap.Error(err))
+		}
+		var defaultLimit throttle.Limit
+		if cfg.Email.Throttle.DefaultLimit != "" {
+			defaultLimit, err = throttle.ParseLimit(cfg.Email.Throttle.DefaultLimit)
+			if err != nil {
+				logger.Log.Fatal("invalid THROTTLE_DEFAULT_LIMIT", zap.Error(err))
+			}
+		}
+		domainThrottle = throttle.NewDomainThrottle(redisClient, domainLimits, defaultLimit)
+	}
+	sendScheduler := scheduler.NewScheduler(redisClient)
+	messageIndex := webhook.NewMessageIndex(redisClient, time.Duration(cfg.Email.Webhook.MessageTTL)*time.Hour)
+
+	// Initialize email providers, each behind its own circuit breaker
+	providers, err := sender.NewProviders(cfg.Email)
+	if err != nil {
+		logger.Log.Fatal("failed to create email sender", zap.Error(err))
+	}
+	emailSender, err := sender.NewFailoverSender(
+		providers,
 		time.Duration(cfg.CircuitBreaker.Timeout)*time.Second,
+		time.Duration(cfg.Email.SendTimeout)*time.Second,
+		retryHandler.IsRetryable,
 	)
+	if err != nil {
+		logger.Log.Fatal("failed to create email sender", zap.Error(err))
+	}
+	defer emailSender.Close()
 
-	// Initialize email sender
-	var emailSender sender.EmailSender
-	if cfg.Email.SendGrid.APIKey != "" {
-		emailSender, err = sender.NewSendGridSender(cfg.Email.SendGrid)
+	// Cluster-wide provider quotas, acquired before every provider attempt
+	var quotaLimiter ratelimit.Limiter
+	if len(cfg.Email.Quota.PerSecond) > 0 || len(cfg.Email.Quota.Daily) > 0 {
+		quotaLimits, err := ratelimit.ParseLimits(cfg.Email.Quota.PerSecond, cfg.Email.Quota.Daily)
+		if err != nil {
+			logger.Log.Fatal("invalid provider quotas", zap.Error(err))
+		}
+		quotaLimiter, err = ratelimit.NewLimiter(cfg.Email.Quota.Store, redisClient, quotaLimits)
 		if err != nil {
-			logger.Log.Fatal("failed to create SendGrid sender", zap.Error(err))
+			logger.Log.Fatal("failed to create provider quota limiter", zap.Error(err))
 		}
-		logger.Log.Info("using SendGrid email sender")
-	} else {
-		emailSender, err = sender.NewSMTPSender(cfg.Email.SMTP)
+		emailSender.SetQuotaLimiter(quotaLimiter)
+	}
+	logger.Log.Info("using email providers", zap.String("providers", emailSender.GetProviderName()))
+
+	// Initialize sender identity rules
+	identities, err := sender.NewIdentityResolver(cfg.Email.Identity)
+	if err != nil {
+		logger.Log.Fatal("failed to load sender identities", zap.Error(err))
+	}
+
+	// Verification key for signed SendGrid event webhooks
+	var sendGridWebhookKey *ecdsa.PublicKey
+	if cfg.Email.Webhook.SendGridPublicKey != "" {
+		sendGridWebhookKey, err = webhook.ParseSendGridPublicKey(cfg.Email.Webhook.SendGridPublicKey)
 		if err != nil {
-			logger.Log.Fatal("failed to create SMTP sender", zap.Error(err))
+			logger.Log.Fatal("failed to load SendGrid webhook key", zap.Error(err))
 		}
-		logger.Log.Info("using SMTP email sender", zap.String("host", cfg.Email.SMTP.Host))
 	}
 
 	// Initialize template client
@@ -91,6 +187,20 @@ func main() {
 	}
 	defer publisher.Close()
 
+	// Batches messages flagged "digest" per user and category
+	digester := digest.NewDigester(
+		redisClient,
+		templateClient,
+		publisher,
+		time.Duration(cfg.Email.Digest.Window)*time.Minute,
+		cfg.Email.Digest.TemplateCode,
+	)
+
+	laneWeights, err := queue.ParseLaneWeights(cfg.RabbitMQ.LaneWeights)
+	if err != nil {
+		logger.Log.Fatal("invalid LANE_WEIGHTS", zap.Error(err))
+	}
+
 	// Initialize queue consumer
 	consumer, err := queue.NewConsumer(queue.ConsumerConfig{
 		URL:            cfg.RabbitMQ.URL,
@@ -101,7 +211,28 @@ func main() {
 		Publisher:      publisher,
 		Idempotency:    idempotencyChecker,
 		RetryHandler:   retryHandler,
-		CircuitBreaker: circuitBreaker,
+		Identities:     identities,
+		MessageIndex:   messageIndex,
+		Suppressions:   suppressions,
+		Unsubscribe:    unsubscribeSigner,
+		Tracker:        tracker,
+		Validator:      validator,
+		Throttle:       domainThrottle,
+		Scheduler:      sendScheduler,
+		Digester:       digester,
+		MaxDeferrals:   cfg.RabbitMQ.MaxDeferrals,
+		Lanes: queue.LaneConfig{
+			CriticalWorkers: cfg.RabbitMQ.CriticalWorkers,
+			Weights:         laneWeights,
+			DLX:             cfg.RabbitMQ.DLX,
+			DLXRoutingKey:   cfg.RabbitMQ.DLXRoutingKey,
+		},
+		Attachments: sender.AttachmentPolicy{
+			MaxSize:      cfg.Email.Attachments.MaxSize,
+			MaxTotalSize: cfg.Email.Attachments.MaxTotalSize,
+			MaxCount:     cfg.Email.Attachments.MaxCount,
+			BaseDir:      cfg.Email.Attachments.Dir,
+		},
 	})
 	if err != nil {
 		logger.Log.Fatal("failed to create consumer", zap.Error(err))
@@ -114,7 +245,7 @@ func main() {
 	}
 
 	// Initialize health checker
-	healthChecker := health.NewHealthChecker(cfg.RabbitMQ.URL, redisClient, cfg.TemplateService.URL)
+	healthChecker := health.NewHealthChecker(cfg.RabbitMQ.URL, redisClient, cfg.TemplateService.URL, quotaLimiter, consumer)
 
 	// Setup HTTP server for health checks
 	gin.SetMode(gin.ReleaseMode)
@@ -130,6 +261,88 @@ func main() {
 		c.JSON(httpStatus, status)
 	})
 
+	// Without an API key the admin routes would be open to anyone, which is
+	// only acceptable in development
+	adminRoutes := cfg.Server.AdminAPIKey != "" || cfg.Server.Env == "development"
+	if !adminRoutes {
+		logger.Log.Warn("ADMIN_API_KEY is not set, admin endpoints are disabled",
+			zap.String("env", cfg.Server.Env),
+		)
+	}
+
+	v1 := router.Group("/api/v1")
+	{
+		if adminRoutes {
+			// Development inbox, only available with the capture provider
+			for _, provider := range providers {
+				capture, ok := provider.(*sender.CaptureSender)
+				if !ok {
+					continue
+				}
+				captureHandler := handler.NewCaptureHandler(capture)
+				inbox := v1.Group("/capture/messages", handler.RequireAPIKey(cfg.Server.AdminAPIKey))
+				{
+					inbox.GET("", captureHandler.ListMessages)
+					inbox.DELETE("", captureHandler.ClearMessages)
+					inbox.GET("/:id", captureHandler.GetMessage)
+					inbox.GET("/:id/raw", captureHandler.GetRawMessage)
+					inbox.GET("/:id/html", captureHandler.GetHTML)
+				}
+				logger.Log.Info("capture inbox enabled", zap.String("path", "/api/v1/capture/messages"))
+			}
+
+			// Suppression list administration
+			suppressionHandler := handler.NewSuppressionHandler(suppressions)
+			suppressed := v1.Group("/suppressions", handler.RequireAPIKey(cfg.Server.AdminAPIKey))
+			{
+				suppressed.GET("", suppressionHandler.ListSuppressions)
+				suppressed.POST("", suppressionHandler.AddSuppression)
+				suppressed.DELETE("/:address", suppressionHandler.RemoveSuppression)
+			}
+
+			// Pending scheduled sends (send_at and quiet hours)
+			scheduleHandler := handler.NewScheduleHandler(sendScheduler, idempotencyChecker, publisher)
+			scheduled := v1.Group("/scheduled", handler.RequireAPIKey(cfg.Server.AdminAPIKey))
+			{
+				scheduled.GET("", scheduleHandler.ListScheduled)
+				scheduled.DELETE("/:notification_id", scheduleHandler.CancelScheduled)
+			}
+		}
+
+		// One-click unsubscribe target of the List-Unsubscribe header
+		if unsubscribeSigner != nil {
+			unsubscribeHandler := handler.NewUnsubscribeHandler(unsubscribeSigner, suppressions)
+			v1.GET("/unsubscribe", unsubscribeHandler.ConfirmPage)
+			v1.POST("/unsubscribe", unsubscribeHandler.Unsubscribe)
+			logger.Log.Info("one-click unsubscribe enabled", zap.String("path", "/api/v1/unsubscribe"))
+		}
+
+		// Open pixel and click redirects embedded by the tracker
+		if tracker != nil {
+			trackingHandler := handler.NewTrackingHandler(tracker, publisher)
+			v1.GET("/track/open/:token", trackingHandler.Open)
+			v1.GET("/track/click/:token", trackingHandler.Click)
+			logger.Log.Info("open and click tracking enabled", zap.String("path", "/api/v1/track"))
+		}
+
+		// Provider event webhooks, each enabled by its verification secret
+		webhookHandler := handler.NewWebhookHandler(
+			webhook.NewProcessor(messageIndex, publisher, suppressions),
+			sendGridWebhookKey,
+			cfg.Email.Webhook.Secret,
+			time.Duration(cfg.Email.Webhook.Tolerance)*time.Second,
+		)
+		webhooks := v1.Group("/webhooks")
+		if sendGridWebhookKey != nil {
+			webhooks.POST("/sendgrid", webhookHandler.SendGrid)
+			logger.Log.Info("SendGrid event webhook enabled", zap.String("path", "/api/v1/webhooks/sendgrid"))
+		}
+		if cfg.Email.Webhook.Secret != "" {
+			webhooks.POST("/events", webhookHandler.Generic)
+			logger.Log.Info("generic event webhook enabled", zap.String("path", "/api/v1/webhooks/events"))
+		}
+	}
+
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Server.Port),
 		Handler: router,
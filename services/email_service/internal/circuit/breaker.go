@@ -6,7 +6,11 @@ import (
 	"github.com/sony/gobreaker"
 )
 
-func NewBreaker(name string, maxRequests uint32, interval time.Duration, timeout time.Duration) *gobreaker.CircuitBreaker {
+// NewBreaker creates a breaker that opens once 60% of at least 3 requests
+// failed. isSuccessful decides which errors still count as successes, e.g.
+// ones that say nothing about the downstream's health; nil counts every error
+// as a failure.
+func NewBreaker(name string, maxRequests uint32, interval time.Duration, timeout time.Duration, isSuccessful func(error) bool) *gobreaker.CircuitBreaker {
 	settings := gobreaker.Settings{
 		Name:        name,
 		MaxRequests: maxRequests,
@@ -16,6 +20,7 @@ func NewBreaker(name string, maxRequests uint32, interval time.Duration, timeout
 			failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
 			return counts.Requests >= 3 && failureRatio >= 0.6
 		},
+		IsSuccessful: isSuccessful,
 	}
 
 	return gobreaker.NewCircuitBreaker(settings)
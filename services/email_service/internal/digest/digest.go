@@ -0,0 +1,328 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	dueKey      = "email:digest:due"      // sorted set of batch keys by closing time (ms)
+	itemsPrefix = "email:digest:items:"   // list of queued messages per batch
+	attemptsKey = "email:digest:attempts" // hash of failed flushes per batch
+
+	// maxListed caps the items listed in one digest; the rest are counted
+	maxListed = 50
+
+	// retryDelay is how long a batch waits after a failed flush
+	retryDelay = time.Minute
+
+	// maxFlushAttempts is how often a batch is tried before its
+	// notifications are failed, about half an hour at retryDelay
+	maxFlushAttempts = 30
+)
+
+// errUndeliverable marks flush errors that retrying cannot fix, such as a
+// missing digest template or one that does not render
+var errUndeliverable = errors.New("digest cannot be sent")
+
+// addScript appends a message to its batch and opens the batch's window
+// when it is the first message
+var addScript = redis.NewScript(`
+redis.call('RPUSH', KEYS[2], ARGV[1])
+redis.call('ZADD', KEYS[1], 'NX', ARGV[2], ARGV[3])
+return 0
+`)
+
+// takeScript removes a closed batch and returns its messages. A batch that
+// another replica took first comes back empty.
+var takeScript = redis.NewScript(`
+local items = redis.call('LRANGE', KEYS[2], 0, -1)
+redis.call('DEL', KEYS[2])
+redis.call('ZREM', KEYS[1], ARGV[1])
+return items
+`)
+
+// Digester collects digestible messages per user and category and, when a
+// batch's window closes, renders them into one digest email through the
+// digest template
+type Digester struct {
+	redis        *redis.Client
+	templates    *template.Client
+	publisher    webhook.StatusPublisher // reports notifications of batches that give up
+	window       time.Duration
+	templateCode string
+}
+
+func NewDigester(redis *redis.Client, templates *template.Client, publisher webhook.StatusPublisher, window time.Duration, templateCode string) *Digester {
+	return &Digester{
+		redis:        redis,
+		templates:    templates,
+		publisher:    publisher,
+		window:       window,
+		templateCode: templateCode,
+	}
+}
+
+// Add queues a message for its user's digest in its category
+func (d *Digester) Add(ctx context.Context, msg *models.EmailMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return d.add(ctx, batchKey(msg.UserID, msg.Category), body, time.Now().Add(d.window))
+}
+
+func (d *Digester) add(ctx context.Context, key string, body []byte, closeAt time.Time) error {
+	err := addScript.Run(ctx, d.redis, []string{dueKey, itemsPrefix + key}, body, closeAt.UnixMilli(), key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add message to digest: %w", err)
+	}
+	return nil
+}
+
+// Run flushes closed batches every interval until ctx is cancelled, handing
+// each digest message to release
+func (d *Digester) Run(ctx context.Context, interval time.Duration, release func(ctx context.Context, body []byte) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.flushDue(ctx, release); err != nil && ctx.Err() == nil {
+				logger.Log.Error("failed to flush digests", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (d *Digester) flushDue(ctx context.Context, release func(ctx context.Context, body []byte) error) error {
+	keys, err := d.redis.ZRangeByScore(ctx, dueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprint(time.Now().UnixMilli()),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		raw, err := takeScript.Run(ctx, d.redis, []string{dueKey, itemsPrefix + key}, key).StringSlice()
+		if err != nil {
+			return err
+		}
+		items := decodeItems(raw)
+		if len(items) == 0 {
+			continue
+		}
+
+		err = d.flush(ctx, items, release)
+		if err == nil {
+			d.resetAttempts(ctx, key)
+			continue
+		}
+		if ctx.Err() != nil {
+			d.restore(ctx, key, raw)
+			return ctx.Err()
+		}
+
+		// Give up on errors a retry cannot fix and after maxFlushAttempts
+		if !errors.Is(err, errUndeliverable) {
+			attempts, countErr := d.redis.HIncrBy(ctx, attemptsKey, key, 1).Result()
+			if countErr != nil {
+				logger.Log.Error("failed to count digest attempts", zap.Error(countErr), zap.String("batch", key))
+			}
+			if countErr != nil || attempts < maxFlushAttempts {
+				logger.Log.Error("failed to send digest, retrying later",
+					zap.Error(err),
+					zap.String("batch", key),
+					zap.Int64("attempts", attempts),
+				)
+				d.restore(ctx, key, raw)
+				continue
+			}
+		}
+
+		logger.Log.Error("failed to send digest, giving up", zap.Error(err), zap.String("batch", key))
+		d.resetAttempts(ctx, key)
+		d.fail(ctx, items, err)
+	}
+	return nil
+}
+
+// restore puts a batch taken by takeScript back to be flushed after
+// retryDelay
+func (d *Digester) restore(ctx context.Context, key string, raw []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, body := range raw {
+		if err := d.add(ctx, key, []byte(body), time.Now().Add(retryDelay)); err != nil {
+			logger.Log.Error("failed to restore digest batch", zap.Error(err), zap.String("batch", key))
+		}
+	}
+}
+
+func (d *Digester) resetAttempts(ctx context.Context, key string) {
+	if err := d.redis.HDel(ctx, attemptsKey, key).Err(); err != nil {
+		logger.Log.Error("failed to reset digest attempts", zap.Error(err), zap.String("batch", key))
+	}
+}
+
+// fail reports every notification of a batch that will not be sent. They
+// were acked when added to the batch, so this is their final status.
+func (d *Digester) fail(ctx context.Context, items []models.EmailMessage, reason error) {
+	for _, item := range items {
+		status := models.StatusMessage{
+			NotificationID: item.NotificationID,
+			UserID:         item.UserID,
+			Status:         "failed",
+			Timestamp:      time.Now(),
+			Error:          reason.Error(),
+			BatchID:        item.BatchID,
+		}
+		if err := d.publisher.PublishStatus(ctx, status); err != nil {
+			logger.Log.Error("failed to publish status", zap.Error(err), zap.String("notification_id", item.NotificationID))
+		}
+	}
+}
+
+// decodeItems reads a batch's queued messages, dropping unreadable ones
+func decodeItems(raw []string) []models.EmailMessage {
+	items := make([]models.EmailMessage, 0, len(raw))
+	for _, body := range raw {
+		var msg models.EmailMessage
+		if err := json.Unmarshal([]byte(body), &msg); err != nil {
+			logger.Log.Error("dropping unreadable digest item", zap.Error(err))
+			continue
+		}
+		items = append(items, msg)
+	}
+	return items
+}
+
+// flush renders one batch into a digest message and releases it. Errors
+// wrapping errUndeliverable will not go away on retry.
+func (d *Digester) flush(ctx context.Context, items []models.EmailMessage, release func(ctx context.Context, body []byte) error) error {
+	tmpl, err := d.templates.FetchTemplate(ctx, d.templateCode)
+	if errors.Is(err, template.ErrTemplateNotFound) {
+		return fmt.Errorf("%w: %w", errUndeliverable, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch digest template: %w", err)
+	}
+
+	summaries := make([]string, len(items))
+	for i := range items {
+		summaries[i] = d.summary(ctx, &items[i])
+	}
+
+	digest, err := Build(items, summaries, tmpl, d.templateCode)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUndeliverable, err)
+	}
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return err
+	}
+	if err := release(ctx, body); err != nil {
+		return err
+	}
+
+	logger.Log.Info("digest queued",
+		zap.String("notification_id", digest.NotificationID),
+		zap.String("user_id", digest.UserID),
+		zap.String("category", digest.Category),
+		zap.Int("items", len(items)),
+	)
+	return nil
+}
+
+// summary is the line listed for a message in the digest: its subject,
+// rendered from its own template when it was not pre-rendered
+func (d *Digester) summary(ctx context.Context, msg *models.EmailMessage) string {
+	if msg.Subject != "" {
+		return msg.Subject
+	}
+	if msg.TemplateCode != "" {
+		if tmpl, err := d.templates.FetchTemplate(ctx, msg.TemplateCode); err == nil {
+			if subject, err := template.RenderTemplate(tmpl.Subject, msg.Variables); err == nil {
+				return subject
+			}
+		}
+	}
+	return msg.NotificationType
+}
+
+// Build renders batch items into a pre-rendered digest message. The digest
+// template receives the latest item's variables plus digest_items (an HTML
+// list of the summaries), digest_count and category.
+func Build(items []models.EmailMessage, summaries []string, tmpl *models.EmailTemplate, templateCode string) (*models.EmailMessage, error) {
+	latest := items[len(items)-1]
+
+	var list strings.Builder
+	list.WriteString("<ul>")
+	for i, summary := range summaries {
+		if i == maxListed {
+			fmt.Fprintf(&list, "<li>and %d more</li>", len(summaries)-maxListed)
+			break
+		}
+		list.WriteString("<li>" + html.EscapeString(summary) + "</li>")
+	}
+	list.WriteString("</ul>")
+
+	variables := make(map[string]interface{}, len(latest.Variables)+3)
+	for key, value := range latest.Variables {
+		variables[key] = value
+	}
+	variables["digest_items"] = list.String()
+	variables["digest_count"] = len(items)
+	variables["category"] = latest.Category
+
+	subject, err := template.RenderTemplate(tmpl.Subject, variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest subject: %w", err)
+	}
+	body, err := template.RenderTemplate(tmpl.Body, variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render digest body: %w", err)
+	}
+
+	digestOf := make([]string, 0, len(items))
+	for _, item := range items {
+		digestOf = append(digestOf, item.NotificationID)
+	}
+
+	return &models.EmailMessage{
+		NotificationID:   "digest-" + items[0].NotificationID,
+		NotificationType: "digest",
+		Category:         latest.Category,
+		UserID:           latest.UserID,
+		Recipient:        latest.Recipient,
+		TenantID:         latest.TenantID,
+		SenderIdentity:   latest.SenderIdentity,
+		Subject:          subject,
+		Body:             body,
+		TemplateCode:     templateCode,
+		Variables:        variables,
+		DigestOf:         digestOf,
+	}, nil
+}
+
+// batchKey identifies a user's batch in a category
+func batchKey(userID, category string) string {
+	return url.QueryEscape(userID) + ":" + url.QueryEscape(category)
+}
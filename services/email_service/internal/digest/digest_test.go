@@ -0,0 +1,312 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestBuildRendersDigest(t *testing.T) {
+	items := []models.EmailMessage{
+		{NotificationID: "n1", UserID: "u1", Category: "social", Recipient: "old@example.com", Variables: map[string]interface{}{"user_name": "Ade"}},
+		{NotificationID: "n2", UserID: "u1", Category: "social", Recipient: "ade@example.com", Variables: map[string]interface{}{"user_name": "Adé"}},
+	}
+	tmpl := &models.EmailTemplate{
+		Subject: "{{digest_count}} new {{category}} updates",
+		Body:    "<p>Hi {{user_name}}</p>{{digest_items}}",
+	}
+
+	digest, err := Build(items, []string{"Tolu liked your post", "<b>Bisi</b> replied"}, tmpl, "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest.Subject != "2 new social updates" {
+		t.Errorf("Subject = %q", digest.Subject)
+	}
+	want := "<p>Hi Adé</p><ul><li>Tolu liked your post</li><li>&lt;b&gt;Bisi&lt;/b&gt; replied</li></ul>"
+	if digest.Body != want {
+		t.Errorf("Body = %q, want %q", digest.Body, want)
+	}
+	if digest.Recipient != "ade@example.com" || digest.NotificationID != "digest-n1" {
+		t.Errorf("digest = %+v", digest)
+	}
+	if strings.Join(digest.DigestOf, ",") != "n1,n2" || digest.Digest {
+		t.Errorf("DigestOf = %v, Digest = %v", digest.DigestOf, digest.Digest)
+	}
+}
+
+func TestBuildCapsListedItems(t *testing.T) {
+	items := make([]models.EmailMessage, maxListed+5)
+	summaries := make([]string, len(items))
+	for i := range items {
+		items[i].NotificationID = fmt.Sprint(i)
+		summaries[i] = fmt.Sprint("item ", i)
+	}
+
+	digest, err := Build(items, summaries, &models.EmailTemplate{Subject: "Digest", Body: "{{digest_items}}"}, "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(digest.Body, "<li>"); got != maxListed+1 {
+		t.Errorf("listed %d items, want %d", got, maxListed+1)
+	}
+	if !strings.Contains(digest.Body, "and 5 more") || len(digest.DigestOf) != len(items) {
+		t.Errorf("body = %q", digest.Body[len(digest.Body)-40:])
+	}
+}
+
+func TestBatchKeyEscapesSeparators(t *testing.T) {
+	if batchKey("a:b", "c") == batchKey("a", "b:c") {
+		t.Error("batch keys collide")
+	}
+}
+
+type recordingPublisher struct {
+	statuses []models.StatusMessage
+}
+
+func (p *recordingPublisher) PublishStatus(ctx context.Context, status models.StatusMessage) error {
+	p.statuses = append(p.statuses, status)
+	return nil
+}
+
+// releaser collects released digests, failing while err is set
+type releaser struct {
+	digests []models.EmailMessage
+	err     error
+}
+
+func (r *releaser) release(ctx context.Context, body []byte) error {
+	if r.err != nil {
+		return r.err
+	}
+	var msg models.EmailMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return err
+	}
+	r.digests = append(r.digests, msg)
+	return nil
+}
+
+// newTestDigester returns a digester whose digest template is cached in
+// miniredis, and whose Template Service knows no templates
+func newTestDigester(t *testing.T) (*Digester, *miniredis.Miniredis, *recordingPublisher) {
+	t.Helper()
+	logger.Log = zap.NewNop()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	templates := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(templates.Close)
+
+	cached, _ := json.Marshal(models.EmailTemplate{Subject: "{{digest_count}} updates", Body: "{{digest_items}}"})
+	mr.Set("template:digest", string(cached))
+
+	publisher := &recordingPublisher{}
+	return NewDigester(rdb, template.NewClient(templates.URL, rdb), publisher, time.Hour, "digest"), mr, publisher
+}
+
+// addClosed queues a message in a batch whose window has already closed
+func addClosed(t *testing.T, d *Digester, msg models.EmailMessage) {
+	t.Helper()
+	body, _ := json.Marshal(msg)
+	if err := d.add(context.Background(), batchKey(msg.UserID, msg.Category), body, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddBatchesPerUserAndCategory(t *testing.T) {
+	d, mr, _ := newTestDigester(t)
+	ctx := context.Background()
+
+	for _, msg := range []models.EmailMessage{
+		{NotificationID: "n1", UserID: "u1", Category: "social"},
+		{NotificationID: "n2", UserID: "u1", Category: "social"},
+		{NotificationID: "n3", UserID: "u1", Category: "billing"},
+		{NotificationID: "n4", UserID: "u2", Category: "social"},
+	} {
+		if err := d.Add(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batches, err := mr.ZMembers(dueKey)
+	if err != nil || len(batches) != 3 {
+		t.Fatalf("batches = %v, %v", batches, err)
+	}
+	items, _ := mr.List(itemsPrefix + batchKey("u1", "social"))
+	if len(items) != 2 {
+		t.Errorf("u1 social has %d items", len(items))
+	}
+
+	// The window opens with the first message and is not extended by later ones
+	first, _ := mr.ZScore(dueKey, batchKey("u1", "social"))
+	if err := d.Add(ctx, &models.EmailMessage{NotificationID: "n5", UserID: "u1", Category: "social"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := mr.ZScore(dueKey, batchKey("u1", "social")); again != first {
+		t.Errorf("window moved from %v to %v", first, again)
+	}
+}
+
+func TestFlushDueSendsClosedBatchesOnce(t *testing.T) {
+	d, mr, publisher := newTestDigester(t)
+	ctx := context.Background()
+
+	addClosed(t, d, models.EmailMessage{NotificationID: "n1", UserID: "u1", Category: "social", Subject: "Tolu liked your post"})
+	addClosed(t, d, models.EmailMessage{NotificationID: "n2", UserID: "u1", Category: "social", Subject: "Bisi replied"})
+	if err := d.Add(ctx, &models.EmailMessage{NotificationID: "n3", UserID: "u2", Category: "social"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &releaser{}
+	for i := 0; i < 2; i++ {
+		if err := d.flushDue(ctx, r.release); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(r.digests) != 1 {
+		t.Fatalf("released %d digests, want 1", len(r.digests))
+	}
+	digest := r.digests[0]
+	if strings.Join(digest.DigestOf, ",") != "n1,n2" || digest.Subject != "2 updates" {
+		t.Errorf("digest = %+v", digest)
+	}
+	if mr.Exists(itemsPrefix + batchKey("u1", "social")) {
+		t.Error("flushed batch still queued")
+	}
+	// The open batch waits for its window
+	if members, _ := mr.ZMembers(dueKey); len(members) != 1 || members[0] != batchKey("u2", "social") {
+		t.Errorf("due batches = %v", members)
+	}
+	if len(publisher.statuses) != 0 {
+		t.Errorf("statuses = %+v", publisher.statuses)
+	}
+}
+
+func TestFlushDueRestoresFailedBatch(t *testing.T) {
+	d, mr, publisher := newTestDigester(t)
+	ctx := context.Background()
+	key := batchKey("u1", "social")
+
+	addClosed(t, d, models.EmailMessage{NotificationID: "n1", UserID: "u1", Category: "social"})
+	addClosed(t, d, models.EmailMessage{NotificationID: "n2", UserID: "u1", Category: "social"})
+
+	r := &releaser{err: errors.New("channel closed")}
+	if err := d.flushDue(ctx, r.release); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _ := mr.List(itemsPrefix + key)
+	if len(items) != 2 {
+		t.Fatalf("restored %d items, want 2", len(items))
+	}
+	score, err := mr.ZScore(dueKey, key)
+	if err != nil || time.UnixMilli(int64(score)).Before(time.Now().Add(retryDelay/2)) {
+		t.Errorf("batch due at %v, want about %v from now", time.UnixMilli(int64(score)), retryDelay)
+	}
+	if got := mr.HGet(attemptsKey, key); got != "1" {
+		t.Errorf("attempts = %q, want 1", got)
+	}
+	if len(publisher.statuses) != 0 {
+		t.Errorf("statuses = %+v", publisher.statuses)
+	}
+
+	// The next successful flush sends it and forgets the failures
+	mr.ZAdd(dueKey, 0, key)
+	r.err = nil
+	if err := d.flushDue(ctx, r.release); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.digests) != 1 || len(r.digests[0].DigestOf) != 2 {
+		t.Errorf("digests = %+v", r.digests)
+	}
+	if got := mr.HGet(attemptsKey, key); got != "" {
+		t.Errorf("attempts = %q after success", got)
+	}
+}
+
+func TestFlushDueGivesUpAfterMaxAttempts(t *testing.T) {
+	d, mr, publisher := newTestDigester(t)
+	key := batchKey("u1", "social")
+
+	addClosed(t, d, models.EmailMessage{NotificationID: "n1", UserID: "u1", Category: "social", BatchID: "b1"})
+	addClosed(t, d, models.EmailMessage{NotificationID: "n2", UserID: "u1", Category: "social"})
+	mr.HSet(attemptsKey, key, fmt.Sprint(maxFlushAttempts-1))
+
+	r := &releaser{err: errors.New("channel closed")}
+	if err := d.flushDue(context.Background(), r.release); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFailed(t, publisher, "n1", "n2")
+	if publisher.statuses[0].BatchID != "b1" || publisher.statuses[0].UserID != "u1" {
+		t.Errorf("status = %+v", publisher.statuses[0])
+	}
+	if mr.Exists(itemsPrefix+key) || mr.HGet(attemptsKey, key) != "" {
+		t.Error("abandoned batch left behind")
+	}
+}
+
+func TestFlushDueFailsBatchWithoutTemplate(t *testing.T) {
+	d, mr, publisher := newTestDigester(t)
+	mr.Del("template:digest") // the Template Service answers 404
+
+	addClosed(t, d, models.EmailMessage{NotificationID: "n1", UserID: "u1", Category: "social"})
+	addClosed(t, d, models.EmailMessage{NotificationID: "n2", UserID: "u1", Category: "social"})
+
+	r := &releaser{}
+	if err := d.flushDue(context.Background(), r.release); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFailed(t, publisher, "n1", "n2")
+	if !strings.Contains(publisher.statuses[0].Error, "template not found") {
+		t.Errorf("error = %q", publisher.statuses[0].Error)
+	}
+	if len(r.digests) != 0 || mr.Exists(itemsPrefix+batchKey("u1", "social")) {
+		t.Error("batch without a template was kept or sent")
+	}
+}
+
+func TestFlushDueFailsBatchThatDoesNotRender(t *testing.T) {
+	d, mr, publisher := newTestDigester(t)
+	cached, _ := json.Marshal(models.EmailTemplate{Subject: "Hi {{user_name}}", Body: "{{digest_items}}"})
+	mr.Set("template:digest", string(cached))
+
+	addClosed(t, d, models.EmailMessage{NotificationID: "n1", UserID: "u1", Category: "social"})
+
+	if err := d.flushDue(context.Background(), (&releaser{}).release); err != nil {
+		t.Fatal(err)
+	}
+	assertFailed(t, publisher, "n1")
+}
+
+func assertFailed(t *testing.T, publisher *recordingPublisher, notificationIDs ...string) {
+	t.Helper()
+	if len(publisher.statuses) != len(notificationIDs) {
+		t.Fatalf("published %d statuses, want %d", len(publisher.statuses), len(notificationIDs))
+	}
+	for i, status := range publisher.statuses {
+		if status.NotificationID != notificationIDs[i] || status.Status != "failed" || status.Error == "" {
+			t.Errorf("status %d = %+v", i, status)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CaptureHandler exposes the messages stored by the capture sender, similar
+// to a MailHog inbox
+type CaptureHandler struct {
+	capture *sender.CaptureSender
+}
+
+func NewCaptureHandler(capture *sender.CaptureSender) *CaptureHandler {
+	return &CaptureHandler{capture: capture}
+}
+
+// ListMessages handles GET /api/v1/capture/messages
+func (h *CaptureHandler) ListMessages(c *gin.Context) {
+	messages, err := h.capture.List()
+	if err != nil {
+		logger.Log.Error("failed to list captured messages", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to list captured messages")
+		return
+	}
+
+	response.Success(c, http.StatusOK, messages, "Captured messages retrieved successfully")
+}
+
+// GetMessage handles GET /api/v1/capture/messages/:id
+func (h *CaptureHandler) GetMessage(c *gin.Context) {
+	msg, ok := h.getMessage(c)
+	if !ok {
+		return
+	}
+
+	response.Success(c, http.StatusOK, msg, "Captured message retrieved successfully")
+}
+
+// GetRawMessage handles GET /api/v1/capture/messages/:id/raw
+func (h *CaptureHandler) GetRawMessage(c *gin.Context) {
+	msg, ok := h.getMessage(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+msg.ID+`.eml"`)
+	c.Data(http.StatusOK, "message/rfc822", msg.Raw)
+}
+
+// GetHTML handles GET /api/v1/capture/messages/:id/html
+func (h *CaptureHandler) GetHTML(c *gin.Context) {
+	msg, ok := h.getMessage(c)
+	if !ok {
+		return
+	}
+
+	// Captured HTML is untrusted; keep it from running scripts in the inbox origin
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTMLBody))
+}
+
+// ClearMessages handles DELETE /api/v1/capture/messages
+func (h *CaptureHandler) ClearMessages(c *gin.Context) {
+	if err := h.capture.Clear(); err != nil {
+		logger.Log.Error("failed to clear captured messages", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to clear captured messages")
+		return
+	}
+
+	response.Success(c, http.StatusOK, nil, "Captured messages cleared successfully")
+}
+
+func (h *CaptureHandler) getMessage(c *gin.Context) (*sender.CapturedMessage, bool) {
+	id := c.Param("id")
+	msg, err := h.capture.Get(id)
+	if err != nil {
+		if errors.Is(err, sender.ErrCapturedMessageNotFound) {
+			response.Error(c, http.StatusNotFound, err, "Captured message not found")
+			return nil, false
+		}
+		logger.Log.Error("failed to get captured message", zap.Error(err), zap.String("id", id))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to get captured message")
+		return nil, false
+	}
+
+	return msg, true
+}
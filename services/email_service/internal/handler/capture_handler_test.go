@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func newCaptureRouter(t *testing.T) (*gin.Engine, *sender.CaptureSender) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	capture, err := sender.NewCaptureSender(config.CaptureConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewCaptureHandler(capture)
+
+	router := gin.New()
+	inbox := router.Group("/api/v1/capture/messages")
+	inbox.GET("", h.ListMessages)
+	inbox.DELETE("", h.ClearMessages)
+	inbox.GET("/:id", h.GetMessage)
+	inbox.GET("/:id/raw", h.GetRawMessage)
+	inbox.GET("/:id/html", h.GetHTML)
+	return router, capture
+}
+
+func serve(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, data interface{}) {
+	t.Helper()
+	var body struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+	if !body.Success {
+		t.Fatalf("success = false: %s", rec.Body)
+	}
+	if data != nil {
+		if err := json.Unmarshal(body.Data, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCaptureHandlerInbox(t *testing.T) {
+	router, capture := newCaptureRouter(t)
+
+	email := sender.NewEmail("ada@example.com", "Welcome", "<p>Hi <script>alert(1)</script></p>", "Hi")
+	result, err := capture.Send(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "/api/v1/capture/messages"
+
+	rec := serve(router, http.MethodGet, base)
+	var list []sender.CapturedMessage
+	decodeData(t, rec, &list)
+	if len(list) != 1 || list[0].ID != result.ProviderMessageID || list[0].HTMLBody != "" {
+		t.Fatalf("list = %+v", list)
+	}
+
+	rec = serve(router, http.MethodGet, base+"/"+result.ProviderMessageID)
+	var msg sender.CapturedMessage
+	decodeData(t, rec, &msg)
+	if msg.Subject != "Welcome" || msg.TextBody != "Hi" || !strings.Contains(msg.HTMLBody, "<p>Hi") {
+		t.Errorf("message = %+v", msg)
+	}
+
+	rec = serve(router, http.MethodGet, base+"/"+result.ProviderMessageID+"/raw")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "message/rfc822" ||
+		!strings.Contains(rec.Body.String(), "Subject: Welcome") {
+		t.Errorf("raw = %d %s\n%s", rec.Code, rec.Header(), rec.Body)
+	}
+
+	rec = serve(router, http.MethodGet, base+"/"+result.ProviderMessageID+"/html")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("html = %d %s", rec.Code, rec.Header())
+	}
+
+	rec = serve(router, http.MethodDelete, base)
+	decodeData(t, rec, nil)
+	rec = serve(router, http.MethodGet, base)
+	list = nil
+	decodeData(t, rec, &list)
+	if len(list) != 0 {
+		t.Errorf("list after clear = %+v", list)
+	}
+}
+
+func TestCaptureHandlerNotFound(t *testing.T) {
+	router, _ := newCaptureRouter(t)
+
+	for _, path := range []string{"/missing", "/missing/raw", "/missing/html"} {
+		rec := serve(router, http.MethodGet, "/api/v1/capture/messages"+path)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAPIKey protects admin routes with the X-API-Key header. An empty key
+// leaves the routes open, so outside development they are not registered
+// without one.
+func RequireAPIKey(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key == "" {
+			c.Next()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-API-Key")), []byte(key)) != 1 {
+			response.ErrorMessage(c, http.StatusUnauthorized, "invalid API key", "Unauthorized")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/idempotency"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/scheduler"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ScheduleHandler lists and cancels scheduled sends
+type ScheduleHandler struct {
+	scheduler   *scheduler.Scheduler
+	idempotency *idempotency.Checker
+	publisher   webhook.StatusPublisher
+}
+
+func NewScheduleHandler(scheduler *scheduler.Scheduler, idempotency *idempotency.Checker, publisher webhook.StatusPublisher) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduler:   scheduler,
+		idempotency: idempotency,
+		publisher:   publisher,
+	}
+}
+
+// ListScheduled handles GET /api/v1/scheduled?limit=
+func (h *ScheduleHandler) ListScheduled(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 {
+		response.ErrorMessage(c, http.StatusBadRequest, "limit must be a positive integer", "Invalid limit")
+		return
+	}
+
+	scheduled, err := h.scheduler.List(c.Request.Context(), limit)
+	if err != nil {
+		logger.Log.Error("failed to list scheduled messages", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to list scheduled messages")
+		return
+	}
+
+	response.Success(c, http.StatusOK, scheduled, "Scheduled messages retrieved successfully")
+}
+
+// CancelScheduled handles DELETE /api/v1/scheduled/:notification_id
+func (h *ScheduleHandler) CancelScheduled(c *gin.Context) {
+	ctx := c.Request.Context()
+	notificationID := c.Param("notification_id")
+
+	body, err := h.scheduler.Cancel(ctx, notificationID)
+	if errors.Is(err, scheduler.ErrNotFound) {
+		response.Error(c, http.StatusNotFound, err, "No pending scheduled send for this notification")
+		return
+	}
+	if err != nil {
+		logger.Log.Error("failed to cancel scheduled message", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to cancel scheduled message")
+		return
+	}
+
+	// A message released moments ago may already be back on the queue; marking
+	// it processed makes the consumer drop it
+	if err := h.idempotency.MarkProcessed(ctx, notificationID); err != nil {
+		logger.Log.Error("failed to mark cancelled message as processed", zap.Error(err))
+	}
+
+	var emailMsg models.EmailMessage
+	_ = json.Unmarshal(body, &emailMsg)
+	err = h.publisher.PublishStatus(ctx, models.StatusMessage{
+		NotificationID: notificationID,
+		UserID:         emailMsg.UserID,
+		Status:         "cancelled",
+		Timestamp:      time.Now().UTC(),
+	})
+	if err != nil {
+		logger.Log.Error("failed to publish cancelled status", zap.Error(err))
+	}
+
+	logger.Log.Info("scheduled message cancelled", zap.String("notification_id", notificationID))
+	response.Success(c, http.StatusOK, nil, "Scheduled send cancelled successfully")
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/idempotency"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/scheduler"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newScheduleRouter(t *testing.T) (*gin.Engine, *scheduler.Scheduler, *idempotency.Checker, *recordingPublisher) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	sched := scheduler.NewScheduler(client)
+	checker := idempotency.NewChecker(client, time.Hour)
+	publisher := &recordingPublisher{}
+
+	h := NewScheduleHandler(sched, checker, publisher)
+	router := gin.New()
+	router.GET("/api/v1/scheduled", h.ListScheduled)
+	router.DELETE("/api/v1/scheduled/:notification_id", h.CancelScheduled)
+	return router, sched, checker, publisher
+}
+
+func TestScheduleHandlerCancel(t *testing.T) {
+	router, sched, checker, publisher := newScheduleRouter(t)
+	ctx := context.Background()
+
+	body := []byte(`{"notification_id":"notif-1","user_id":"user-1"}`)
+	if err := sched.Schedule(ctx, "notif-1", body, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(router, http.MethodDelete, "/api/v1/scheduled/notif-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel = %d: %s", rec.Code, rec.Body)
+	}
+	// Marked processed so a copy released moments ago is dropped
+	if processed, err := checker.IsProcessed(ctx, "notif-1"); err != nil || !processed {
+		t.Errorf("IsProcessed = %v, %v, want the cancelled send marked", processed, err)
+	}
+	if len(publisher.statuses) != 1 {
+		t.Fatalf("published %d statuses, want 1", len(publisher.statuses))
+	}
+	if got := publisher.statuses[0]; got.Status != "cancelled" || got.NotificationID != "notif-1" || got.UserID != "user-1" {
+		t.Errorf("status = %+v", got)
+	}
+	if scheduled, _ := sched.List(ctx, 10); len(scheduled) != 0 {
+		t.Errorf("still scheduled: %+v", scheduled)
+	}
+
+	// Cancelling again, or a send that was never scheduled, is a 404 and
+	// neither marks nor publishes anything
+	for _, id := range []string{"notif-1", "notif-2"} {
+		rec = serve(router, http.MethodDelete, "/api/v1/scheduled/"+id)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("cancel %s = %d, want 404", id, rec.Code)
+		}
+	}
+	if processed, _ := checker.IsProcessed(ctx, "notif-2"); processed {
+		t.Error("unknown notification marked processed")
+	}
+	if len(publisher.statuses) != 1 {
+		t.Errorf("published %d statuses, want 1", len(publisher.statuses))
+	}
+}
+
+func TestScheduleHandlerList(t *testing.T) {
+	router, sched, _, _ := newScheduleRouter(t)
+
+	for i, id := range []string{"notif-1", "notif-2"} {
+		if err := sched.Schedule(context.Background(), id, []byte("{}"), time.Now().Add(time.Duration(i+1)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var scheduled []scheduler.Scheduled
+	decodeData(t, serve(router, http.MethodGet, "/api/v1/scheduled?limit=1"), &scheduled)
+	if len(scheduled) != 1 || scheduled[0].NotificationID != "notif-1" {
+		t.Errorf("list = %+v, want notif-1 only", scheduled)
+	}
+
+	for _, limit := range []string{"0", "abc"} {
+		if rec := serve(router, http.MethodGet, "/api/v1/scheduled?limit="+limit); rec.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s = %d, want 400", limit, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SuppressionHandler manages the suppression list
+type SuppressionHandler struct {
+	store suppression.Store
+}
+
+func NewSuppressionHandler(store suppression.Store) *SuppressionHandler {
+	return &SuppressionHandler{store: store}
+}
+
+// AddSuppressionRequest is the body of POST /api/v1/suppressions
+type AddSuppressionRequest struct {
+	Address   string     `json:"address" binding:"required"`
+	Category  string     `json:"category"` // empty suppresses all mail
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ListSuppressions handles GET /api/v1/suppressions
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	entries, err := h.store.List(c.Request.Context())
+	if err != nil {
+		logger.Log.Error("failed to list suppressions", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to list suppressions")
+		return
+	}
+
+	response.Success(c, http.StatusOK, entries, "Suppressions retrieved successfully")
+}
+
+// AddSuppression handles POST /api/v1/suppressions
+func (h *SuppressionHandler) AddSuppression(c *gin.Context) {
+	var req AddSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+	address, err := suppression.Normalize(req.Address)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err, "Invalid email address")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		response.ErrorMessage(c, http.StatusBadRequest, "expires_at is in the past", "Invalid expiry")
+		return
+	}
+
+	entry := suppression.Entry{
+		Address:   address,
+		Category:  req.Category,
+		Reason:    req.Reason,
+		Source:    req.Source,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if entry.Reason == "" {
+		entry.Reason = suppression.ReasonManual
+	}
+	if entry.Source == "" {
+		entry.Source = "admin"
+	}
+
+	if err := h.store.Add(c.Request.Context(), entry); err != nil {
+		logger.Log.Error("failed to add suppression", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to add suppression")
+		return
+	}
+
+	logger.Log.Info("suppression added", zap.String("address", address), zap.String("reason", entry.Reason))
+	response.Success(c, http.StatusCreated, entry, "Suppression added successfully")
+}
+
+// RemoveSuppression handles DELETE /api/v1/suppressions/:address, with an
+// optional ?category= for category opt-outs
+func (h *SuppressionHandler) RemoveSuppression(c *gin.Context) {
+	address, err := suppression.Normalize(c.Param("address"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err, "Invalid email address")
+		return
+	}
+
+	err = h.store.Remove(c.Request.Context(), address, c.Query("category"))
+	if errors.Is(err, suppression.ErrNotFound) {
+		response.Error(c, http.StatusNotFound, err, "Suppression not found")
+		return
+	}
+	if err != nil {
+		logger.Log.Error("failed to remove suppression", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to remove suppression")
+		return
+	}
+
+	logger.Log.Info("suppression removed", zap.String("address", address))
+	response.Success(c, http.StatusOK, nil, "Suppression removed successfully")
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/tracking"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// transparentGIF is a 1x1 transparent GIF
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// TrackingHandler serves the open pixel and click redirects embedded by
+// tracking.Tracker and reports each hit as a status event
+type TrackingHandler struct {
+	tracker   *tracking.Tracker
+	publisher webhook.StatusPublisher
+}
+
+func NewTrackingHandler(tracker *tracking.Tracker, publisher webhook.StatusPublisher) *TrackingHandler {
+	return &TrackingHandler{
+		tracker:   tracker,
+		publisher: publisher,
+	}
+}
+
+// Open handles GET /api/v1/track/open/:token. The pixel is served even for
+// bad tokens so mail clients never show a broken image.
+func (h *TrackingHandler) Open(c *gin.Context) {
+	if target, err := h.tracker.Verify(c.Param("token")); err == nil {
+		h.publish(c, target, "opened")
+	}
+
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+	c.Header("Pragma", "no-cache")
+	c.Data(http.StatusOK, "image/gif", transparentGIF)
+}
+
+// Click handles GET /api/v1/track/click/:token. Only signed links are
+// followed, so the endpoint cannot be used as an open redirect.
+func (h *TrackingHandler) Click(c *gin.Context) {
+	target, err := h.tracker.Verify(c.Param("token"))
+	if err != nil || target.URL == "" {
+		response.ErrorMessage(c, http.StatusNotFound, "invalid tracking link", "Tracking link not found")
+		return
+	}
+
+	h.publish(c, target, "clicked")
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target.URL)
+}
+
+// publish reports the event; failures are logged but never block the reader
+func (h *TrackingHandler) publish(c *gin.Context, target *tracking.Target, status string) {
+	err := h.publisher.PublishStatus(c.Request.Context(), models.StatusMessage{
+		NotificationID: target.NotificationID,
+		UserID:         target.UserID,
+		Status:         status,
+		Timestamp:      time.Now().UTC(),
+		URL:            target.URL,
+	})
+	if err != nil {
+		logger.Log.Error("failed to publish tracking event",
+			zap.Error(err),
+			zap.String("notification_id", target.NotificationID),
+			zap.String("status", status),
+		)
+	}
+}
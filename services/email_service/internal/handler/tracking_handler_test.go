@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/tracking"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// recordingPublisher collects the status messages published by a handler
+type recordingPublisher struct {
+	mu       sync.Mutex
+	statuses []models.StatusMessage
+}
+
+func (p *recordingPublisher) PublishStatus(ctx context.Context, status models.StatusMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = append(p.statuses, status)
+	return nil
+}
+
+func TestTrackingHandlerClick(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	tracker, err := tracking.NewTracker("0123456789abcdef", "https://mail.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &recordingPublisher{}
+	h := NewTrackingHandler(tracker, publisher)
+	router := gin.New()
+	router.GET("/api/v1/track/click/:token", h.Click)
+
+	token, err := tracker.Token(tracking.Target{NotificationID: "notif-1", URL: "https://example.com/offer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serve(router, http.MethodGet, "/api/v1/track/click/"+token)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/offer" {
+		t.Errorf("click = %d to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if len(publisher.statuses) != 1 || publisher.statuses[0].Status != "clicked" || publisher.statuses[0].NotificationID != "notif-1" {
+		t.Errorf("published %+v", publisher.statuses)
+	}
+
+	rec = serve(router, http.MethodGet, "/api/v1/track/click/forged")
+	var body response.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusNotFound || body.Success || body.Error != "invalid tracking link" || body.Message == "" {
+		t.Errorf("forged token = %d %+v", rec.Code, body)
+	}
+	if len(publisher.statuses) != 1 {
+		t.Errorf("forged token published a status")
+	}
+}
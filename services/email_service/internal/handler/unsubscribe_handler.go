@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/unsubscribe"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body style="font-family:sans-serif;max-width:32em;margin:4em auto">
+{{if .Error}}<p>{{.Error}}</p>
+{{else if .Done}}<p>{{.Address}} will no longer receive {{.Category}} emails.</p>
+{{else}}<p>Stop sending {{.Category}} emails to {{.Address}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body></html>
+`))
+
+type unsubscribePageData struct {
+	Address  string
+	Category string
+	Done     bool
+	Error    string
+}
+
+// UnsubscribeHandler serves the List-Unsubscribe URL. POST performs the
+// RFC 8058 one-click opt-out; GET only shows a confirmation page so link
+// scanners that prefetch URLs cannot unsubscribe anyone.
+type UnsubscribeHandler struct {
+	signer *unsubscribe.Signer
+	store  suppression.Store
+}
+
+func NewUnsubscribeHandler(signer *unsubscribe.Signer, store suppression.Store) *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		signer: signer,
+		store:  store,
+	}
+}
+
+// ConfirmPage handles GET /api/v1/unsubscribe?token=
+func (h *UnsubscribeHandler) ConfirmPage(c *gin.Context) {
+	claims, ok := h.verify(c)
+	if !ok {
+		return
+	}
+
+	h.render(c, http.StatusOK, unsubscribePageData{Address: claims.Address, Category: claims.Category})
+}
+
+// Unsubscribe handles POST /api/v1/unsubscribe?token=
+func (h *UnsubscribeHandler) Unsubscribe(c *gin.Context) {
+	claims, ok := h.verify(c)
+	if !ok {
+		return
+	}
+
+	// One-click requests carry "List-Unsubscribe=One-Click" in the form body
+	source := "unsubscribe-page"
+	if c.PostForm("List-Unsubscribe") == "One-Click" {
+		source = "one-click"
+	}
+
+	err := h.store.Add(c.Request.Context(), suppression.Entry{
+		Address:   claims.Address,
+		Category:  claims.Category,
+		Reason:    suppression.ReasonUnsubscribe,
+		Source:    source,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.Log.Error("failed to record unsubscribe", zap.Error(err), zap.String("user_id", claims.UserID))
+		h.render(c, http.StatusInternalServerError, unsubscribePageData{Error: "Something went wrong, please try again later."})
+		return
+	}
+
+	logger.Log.Info("recipient unsubscribed",
+		zap.String("user_id", claims.UserID),
+		zap.String("category", claims.Category),
+		zap.String("source", source),
+	)
+	h.render(c, http.StatusOK, unsubscribePageData{Address: claims.Address, Category: claims.Category, Done: true})
+}
+
+func (h *UnsubscribeHandler) verify(c *gin.Context) (*unsubscribe.Claims, bool) {
+	claims, err := h.signer.Verify(c.Query("token"))
+	if err != nil {
+		message := "This unsubscribe link is invalid."
+		if errors.Is(err, unsubscribe.ErrExpiredToken) {
+			message = "This unsubscribe link has expired. Use the link in a more recent email."
+		}
+		h.render(c, http.StatusBadRequest, unsubscribePageData{Error: message})
+		return nil, false
+	}
+	return claims, true
+}
+
+func (h *UnsubscribeHandler) render(c *gin.Context, status int, data unsubscribePageData) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := unsubscribePage.Execute(c.Writer, data); err != nil {
+		logger.Log.Error("failed to render unsubscribe page", zap.Error(err))
+	}
+}
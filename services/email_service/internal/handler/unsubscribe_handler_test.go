@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/unsubscribe"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const testUnsubscribeSecret = "0123456789abcdef-secret"
+
+func newUnsubscribeRouter(t *testing.T) (*gin.Engine, *unsubscribe.Signer, *suppression.MemoryStore) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+
+	signer, err := unsubscribe.NewSigner(testUnsubscribeSecret, time.Hour, "https://email.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := suppression.NewMemoryStore()
+	h := NewUnsubscribeHandler(signer, store)
+
+	router := gin.New()
+	router.GET("/api/v1/unsubscribe", h.ConfirmPage)
+	router.POST("/api/v1/unsubscribe", h.Unsubscribe)
+	return router, signer, store
+}
+
+func postUnsubscribe(router *gin.Engine, token string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/unsubscribe?token="+url.QueryEscape(token), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUnsubscribeConfirmPageDoesNotUnsubscribe(t *testing.T) {
+	router, signer, store := newUnsubscribeRouter(t)
+	token, _ := signer.Token("user-42", "newsletter", "ade@example.com")
+
+	rec := serve(router, http.MethodGet, "/api/v1/unsubscribe?token="+url.QueryEscape(token))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+		t.Errorf("confirm page = %d %s", rec.Code, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), "Stop sending newsletter emails to ade@example.com?") {
+		t.Errorf("confirm page does not name the address and category: %s", rec.Body)
+	}
+
+	entries, _ := store.List(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("GET suppressed %+v", entries)
+	}
+}
+
+func TestUnsubscribeOneClickSuppressesCategory(t *testing.T) {
+	router, signer, store := newUnsubscribeRouter(t)
+	ctx := context.Background()
+	token, _ := signer.Token("user-42", "newsletter", "Ade@Example.com")
+
+	rec := postUnsubscribe(router, token, url.Values{"List-Unsubscribe": {"One-Click"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "will no longer receive newsletter emails") {
+		t.Fatalf("one-click = %d %s", rec.Code, rec.Body)
+	}
+
+	entry, err := store.Get(ctx, "ade@example.com", "newsletter")
+	if err != nil || entry == nil {
+		t.Fatalf("newsletter suppression = %+v, %v", entry, err)
+	}
+	if entry.Category != "newsletter" || entry.Reason != suppression.ReasonUnsubscribe || entry.Source != "one-click" {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	// Only the token's category is suppressed
+	if entry, _ := store.Get(ctx, "ade@example.com", "billing"); entry != nil {
+		t.Errorf("billing suppressed by a newsletter unsubscribe: %+v", entry)
+	}
+
+	// The confirmation page's form posts without the one-click field
+	token, _ = signer.Token("user-42", "promotions", "ade@example.com")
+	if rec := postUnsubscribe(router, token, nil); rec.Code != http.StatusOK {
+		t.Fatalf("page unsubscribe = %d", rec.Code)
+	}
+	if entry, _ := store.Get(ctx, "ade@example.com", "promotions"); entry == nil || entry.Source != "unsubscribe-page" {
+		t.Errorf("promotions entry = %+v", entry)
+	}
+}
+
+func TestUnsubscribeRejectsBadTokens(t *testing.T) {
+	router, signer, store := newUnsubscribeRouter(t)
+	token, _ := signer.Token("user-42", "newsletter", "ade@example.com")
+
+	// A payload changed to another address keeps the old signature
+	payload, signature, _ := strings.Cut(token, ".")
+	other, _ := signer.Token("user-42", "newsletter", "someone@example.com")
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	// A token signed with another secret, e.g. from another environment
+	foreign, err := unsubscribe.NewSigner("another-secret-of-16+", time.Hour, "https://email.example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched, _ := foreign.Token("user-42", "newsletter", "ade@example.com")
+
+	expiredSigner, _ := unsubscribe.NewSigner(testUnsubscribeSecret, -time.Minute, "https://email.example.com", "")
+	expired, _ := expiredSigner.Token("user-42", "newsletter", "ade@example.com")
+
+	cases := map[string]struct {
+		token string
+		want  string
+	}{
+		"tampered":   {otherPayload + "." + signature, "This unsubscribe link is invalid."},
+		"truncated":  {payload, "This unsubscribe link is invalid."},
+		"mismatched": {mismatched, "This unsubscribe link is invalid."},
+		"missing":    {"", "This unsubscribe link is invalid."},
+		"expired":    {expired, "This unsubscribe link has expired."},
+	}
+	for name, tc := range cases {
+		for _, rec := range []*httptest.ResponseRecorder{
+			serve(router, http.MethodGet, "/api/v1/unsubscribe?token="+url.QueryEscape(tc.token)),
+			postUnsubscribe(router, tc.token, url.Values{"List-Unsubscribe": {"One-Click"}}),
+		} {
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tc.want) {
+				t.Errorf("%s token = %d %s", name, rec.Code, rec.Body)
+			}
+		}
+	}
+
+	entries, _ := store.List(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("bad tokens suppressed %+v", entries)
+	}
+}
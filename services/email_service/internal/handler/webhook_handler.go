@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/response"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBody bounds webhook payloads; SendGrid batches stay well below it
+const maxWebhookBody = 5 << 20
+
+// WebhookHandler receives delivery, bounce and complaint events from email
+// providers and publishes them as status updates
+type WebhookHandler struct {
+	processor   *webhook.Processor
+	sendGridKey *ecdsa.PublicKey
+	secret      []byte
+	tolerance   time.Duration
+}
+
+// NewWebhookHandler creates the handler. Routes should only be registered for
+// the endpoints whose key or secret is configured.
+func NewWebhookHandler(processor *webhook.Processor, sendGridKey *ecdsa.PublicKey, secret string, tolerance time.Duration) *WebhookHandler {
+	return &WebhookHandler{
+		processor:   processor,
+		sendGridKey: sendGridKey,
+		secret:      []byte(secret),
+		tolerance:   tolerance,
+	}
+}
+
+// SendGrid handles POST /api/v1/webhooks/sendgrid
+func (h *WebhookHandler) SendGrid(c *gin.Context) {
+	body, ok := readWebhookBody(c)
+	if !ok {
+		return
+	}
+
+	err := webhook.VerifySendGridSignature(h.sendGridKey,
+		c.GetHeader(webhook.SendGridSignatureHeader),
+		c.GetHeader(webhook.SendGridTimestampHeader),
+		body, h.tolerance)
+	if err != nil {
+		logger.Log.Warn("rejected SendGrid webhook", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		response.Error(c, http.StatusUnauthorized, err, "Webhook signature verification failed")
+		return
+	}
+
+	events, err := webhook.ParseSendGridEvents(body)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err, "Invalid webhook payload")
+		return
+	}
+
+	h.process(c, events)
+}
+
+// Generic handles POST /api/v1/webhooks/events
+func (h *WebhookHandler) Generic(c *gin.Context) {
+	body, ok := readWebhookBody(c)
+	if !ok {
+		return
+	}
+
+	err := webhook.VerifyGenericSignature(h.secret,
+		c.GetHeader(webhook.GenericSignatureHeader),
+		c.GetHeader(webhook.GenericTimestampHeader),
+		body, h.tolerance)
+	if err != nil {
+		logger.Log.Warn("rejected generic webhook", zap.Error(err), zap.String("client_ip", c.ClientIP()))
+		response.Error(c, http.StatusUnauthorized, err, "Webhook signature verification failed")
+		return
+	}
+
+	events, err := webhook.ParseGenericEvents(body)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err, "Invalid webhook payload")
+		return
+	}
+
+	h.process(c, events)
+}
+
+// process publishes the events. Failures leave nothing published and return
+// 500 so the provider retries the batch later.
+func (h *WebhookHandler) process(c *gin.Context, events []webhook.Event) {
+	if err := h.processor.Process(c.Request.Context(), events); err != nil {
+		logger.Log.Error("failed to process webhook events", zap.Error(err))
+		response.Error(c, http.StatusInternalServerError, err, "Failed to process webhook events")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"processed": len(events)}, "Webhook events processed successfully")
+}
+
+func readWebhookBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, err, "Webhook payload too large")
+		} else {
+			response.Error(c, http.StatusBadRequest, err, "Failed to read webhook payload")
+		}
+		return nil, false
+	}
+	return body, true
+}
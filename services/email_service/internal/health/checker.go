@@ -6,22 +6,35 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/queue"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/ratelimit"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 )
 
+// ConsumerReporter reports the consumer's connection state and the backlog of
+// its queues
+type ConsumerReporter interface {
+	Status() queue.ConsumerStatus
+	LaneDepths() ([]queue.LaneDepth, error)
+}
+
 type HealthChecker struct {
 	rabbitmqURL        string
 	redis              *redis.Client
 	templateServiceURL string
 	httpClient         *http.Client
+	quota              ratelimit.Limiter // nil when no provider quotas are configured
+	consumer           ConsumerReporter
 }
 
-func NewHealthChecker(rabbitmqURL string, redis *redis.Client, templateServiceURL string) *HealthChecker {
+func NewHealthChecker(rabbitmqURL string, redis *redis.Client, templateServiceURL string, quota ratelimit.Limiter, consumer ConsumerReporter) *HealthChecker {
 	return &HealthChecker{
 		rabbitmqURL:        rabbitmqURL,
 		redis:              redis,
 		templateServiceURL: templateServiceURL,
+		quota:              quota,
+		consumer:           consumer,
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
@@ -31,7 +44,11 @@ func NewHealthChecker(rabbitmqURL string, redis *redis.Client, templateServiceUR
 type HealthStatus struct {
 	Status    string            `json:"status"`
 	Checks    map[string]string `json:"checks"`
+	Quotas    []ratelimit.Quota `json:"quotas,omitempty"`
+	Lanes     []queue.LaneDepth `json:"lanes,omitempty"`
 	Timestamp string            `json:"timestamp"`
+
+	Consumer *queue.ConsumerStatus `json:"consumer,omitempty"`
 }
 
 func (h *HealthChecker) Check() HealthStatus {
@@ -76,6 +93,38 @@ func (h *HealthChecker) Check() HealthStatus {
 		}
 	}
 
+	// Remaining provider quota is informational and never makes the service unhealthy
+	var quotas []ratelimit.Quota
+	if h.quota != nil {
+		quotas, err = h.quota.Remaining(ctx)
+		if err != nil {
+			checks["provider_quota"] = "unavailable: " + err.Error()
+		}
+	}
+
+	// A consumer that lost RabbitMQ is unhealthy until it reconnects
+	var consumer *queue.ConsumerStatus
+	var lanes []queue.LaneDepth
+	if h.consumer != nil {
+		status := h.consumer.Status()
+		consumer = &status
+		if status.State == queue.StateConsuming {
+			checks["consumer"] = "healthy"
+		} else {
+			checks["consumer"] = "unhealthy: " + status.State
+			if status.LastError != "" {
+				checks["consumer"] += ": " + status.LastError
+			}
+			allHealthy = false
+		}
+
+		// Queue depths are informational
+		lanes, err = h.consumer.LaneDepths()
+		if err != nil {
+			checks["queue_lanes"] = "unavailable: " + err.Error()
+		}
+	}
+
 	status := "healthy"
 	if !allHealthy {
 		status = "unhealthy"
@@ -84,6 +133,9 @@ func (h *HealthChecker) Check() HealthStatus {
 	return HealthStatus{
 		Status:    status,
 		Checks:    checks,
+		Quotas:    quotas,
+		Lanes:     lanes,
 		Timestamp: time.Now().Format(time.RFC3339),
+		Consumer:  consumer,
 	}
 }
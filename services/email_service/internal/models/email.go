@@ -6,19 +6,70 @@ import "time"
 type EmailMessage struct {
 	NotificationID   string                 `json:"notification_id"`
 	NotificationType string                 `json:"notification_type"`
+	Category         string                 `json:"category,omitempty"` // e.g. "newsletter"; empty or "transactional" for transactional mail
 	UserID           string                 `json:"user_id"`
 	Recipient        string                 `json:"recipient"`
+	Cc               []string               `json:"cc,omitempty"`
+	Bcc              []string               `json:"bcc,omitempty"`
+	ReplyTo          string                 `json:"reply_to,omitempty"`
+	Headers          map[string]string      `json:"headers,omitempty"`
 	Subject          string                 `json:"subject"`
 	Body             string                 `json:"body"`
+	TextBody         string                 `json:"text_body,omitempty"`
 	TemplateCode     string                 `json:"template_code"`
+	TenantID         string                 `json:"tenant_id,omitempty"`
+	SenderIdentity   string                 `json:"sender_identity,omitempty"` // named identity overriding the rules
 	Variables        map[string]interface{} `json:"variables"`
 	Priority         int                    `json:"priority"`
+	Attachments      []Attachment           `json:"attachments,omitempty"`
+	Tracking         Tracking               `json:"tracking"`
+	SendAt           *time.Time             `json:"send_at,omitempty"`     // not sent before this time
+	Timezone         string                 `json:"timezone,omitempty"`    // recipient's IANA zone for quiet hours, e.g. "Africa/Lagos"
+	QuietHours       *QuietHours            `json:"quiet_hours,omitempty"` // local window in which nothing is sent
+	Digest           bool                   `json:"digest,omitempty"`      // batch into a per-user, per-category digest
+	DigestOf         []string               `json:"digest_of,omitempty"`   // set on digests: the notifications they cover
+	Recipients       []BulkRecipient        `json:"recipients,omitempty"`  // makes this a bulk message, sent once per recipient
+	BatchID          string                 `json:"batch_id,omitempty"`    // set on the per-recipient messages of a bulk message
 	Metadata         struct {
 		Timestamp  string `json:"timestamp"`
 		RetryCount int    `json:"retry_count"`
 	} `json:"metadata"`
 }
 
+// Attachment represents a file attached to an email message. The file is
+// either embedded as base64 content or referenced by a path relative to the
+// service's attachment directory.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content,omitempty"` // base64 encoded
+	Path        string `json:"path,omitempty"`
+	Inline      bool   `json:"inline,omitempty"`
+	ContentID   string `json:"content_id,omitempty"` // referenced from HTML as cid:<content_id>
+}
+
+// BulkRecipient is one recipient of a bulk message. Its variables override
+// the message's shared variables.
+type BulkRecipient struct {
+	Email          string                 `json:"email"`
+	UserID         string                 `json:"user_id,omitempty"`
+	NotificationID string                 `json:"notification_id,omitempty"` // defaults to "<batch id>-<position>"
+	Variables      map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Tracking opts a notification into open and click tracking
+type Tracking struct {
+	Opens  bool `json:"opens,omitempty"`
+	Clicks bool `json:"clicks,omitempty"`
+}
+
+// QuietHours is a daily window, in the recipient's timezone, during which
+// mail is held until End. Start after End spans midnight.
+type QuietHours struct {
+	Start string `json:"start"` // "22:00"
+	End   string `json:"end"`   // "07:00"
+}
+
 // EmailTemplate represents template data from Template Service
 type EmailTemplate struct {
 	Subject   string   `json:"subject"`
@@ -30,10 +81,19 @@ type EmailTemplate struct {
 type StatusMessage struct {
 	NotificationID string    `json:"notification_id"`
 	UserID         string    `json:"user_id"`
-	Status         string    `json:"status"` // "sent", "delivered", "failed"
+	Status         string    `json:"status"` // "sent", "delivered", "failed", "scheduled", "digested", "opened", "clicked"
 	Timestamp      time.Time `json:"timestamp"`
 	Error          string    `json:"error,omitempty"`
 	Provider       string    `json:"provider"`
+
+	// ProviderMessageID is the provider's ID for the message (SMTP queue ID,
+	// SendGrid X-Message-Id, Mailgun id), used to correlate bounces and tickets
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	MessageID         string `json:"message_id,omitempty"` // RFC 5322 Message-ID header
+
+	URL         string     `json:"url,omitempty"`          // link followed, for "clicked"
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"` // release time, for "scheduled"
+	BatchID     string     `json:"batch_id,omitempty"`     // bulk message the notification belongs to
 }
 
 // TemplateResponse represents the response from Template Service
@@ -0,0 +1,321 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/unsubscribe"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/validation"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// processBulk sends a bulk message. When the active provider supports it,
+// recipients go out in batches of personalizations; recipients that need
+// individual handling (bad address, suppressed, throttled, tracking, failed
+// batch) are fanned out as single messages onto the work queue. Bulk messages
+// render from template_code; cc and bcc do not apply.
+func (c *Consumer) processBulk(delivery amqp.Delivery, bulk *models.EmailMessage) {
+	if bulk.TemplateCode == "" {
+		err := errors.New("bulk messages require template_code")
+		logger.Log.Error("invalid bulk message", zap.Error(err), zap.String("notification_id", bulk.NotificationID))
+		c.publishStatus(models.StatusMessage{
+			NotificationID: bulk.NotificationID,
+			UserID:         bulk.UserID,
+			Status:         "failed",
+			Error:          err.Error(),
+			BatchID:        bulk.NotificationID,
+		})
+		delivery.Nack(false, false)
+		return
+	}
+
+	// A redelivered bulk message skips the recipients already sent
+	var pending []models.EmailMessage
+	for _, msg := range expandBulk(bulk) {
+		processed, err := c.idempotency.IsProcessed(c.ctx, msg.NotificationID)
+		if err != nil {
+			logger.Log.Error("failed to check idempotency", zap.Error(err))
+		}
+		if !processed {
+			pending = append(pending, msg)
+		}
+	}
+
+	// Tracking links and digests are per recipient, so those always fan out.
+	// So does everything when the active provider cannot send bulk mail,
+	// before any recipient is validated or takes a throttle slot.
+	individual := pending
+	bulkSender, ok := c.emailSender.(sender.BulkSender)
+	if ok && sender.SupportsBulk(c.emailSender) && len(pending) > 0 && !bulk.Tracking.Opens && !bulk.Tracking.Clicks && !bulk.Digest {
+		var err error
+		individual, err = c.sendBulk(c.ctx, bulkSender, bulk, pending)
+		if err != nil {
+			// Interrupted by shutdown; sent recipients are already marked processed
+			logger.Log.Warn("bulk send interrupted by shutdown, requeueing",
+				zap.Error(err),
+				zap.String("notification_id", bulk.NotificationID),
+			)
+			delivery.Nack(false, true)
+			return
+		}
+	}
+
+	for i := range individual {
+		body, err := json.Marshal(&individual[i])
+		if err == nil {
+			err = c.deferrer.Release(c.ctx, body)
+		}
+		if err != nil {
+			logger.Log.Error("failed to fan out bulk message, requeueing",
+				zap.Error(err),
+				zap.String("notification_id", bulk.NotificationID),
+			)
+			delivery.Nack(false, true)
+			return
+		}
+	}
+
+	if err := c.idempotency.MarkProcessed(c.ctx, bulk.NotificationID); err != nil {
+		logger.Log.Error("failed to mark as processed", zap.Error(err))
+	}
+	delivery.Ack(false)
+
+	logger.Log.Info("bulk message processed",
+		zap.String("batch_id", bulk.NotificationID),
+		zap.Int("recipients", len(bulk.Recipients)),
+		zap.Int("sent_in_bulk", len(pending)-len(individual)),
+		zap.Int("fanned_out", len(individual)),
+	)
+}
+
+// sendBulk renders the template once, leaving per-recipient variables as
+// substitution tags, and sends the recipients in batches of up to
+// sender.MaxBulkRecipients. It returns the recipients to send individually;
+// an error means shutdown interrupted it.
+func (c *Consumer) sendBulk(ctx context.Context, bulkSender sender.BulkSender, bulk *models.EmailMessage, pending []models.EmailMessage) ([]models.EmailMessage, error) {
+	tmpl, err := c.templateClient.FetchTemplate(ctx, bulk.TemplateCode)
+	if err != nil {
+		logger.Log.Warn("failed to fetch bulk template, sending individually", zap.Error(err))
+		return pending, nil
+	}
+
+	// Variables any recipient overrides become tags; the rest render now
+	variables := make(map[string]interface{}, len(bulk.Variables))
+	for key, value := range bulk.Variables {
+		variables[key] = value
+	}
+	tags := make(map[string]string)
+	for _, recipient := range bulk.Recipients {
+		for key := range recipient.Variables {
+			tags[key] = "{{" + key + "}}"
+			variables[key] = tags[key]
+		}
+	}
+
+	subject, err := template.RenderTemplate(tmpl.Subject, variables)
+	if err == nil {
+		var body string
+		body, err = template.RenderTemplate(tmpl.Body, variables)
+		tmpl = &models.EmailTemplate{Subject: subject, Body: body}
+	}
+	if err != nil {
+		logger.Log.Warn("failed to render bulk template, sending individually", zap.Error(err))
+		return pending, nil
+	}
+
+	content := sender.NewEmail("", tmpl.Subject, tmpl.Body, "")
+	content.From = c.identities.Resolve(bulk)
+	content.ReplyTo = bulk.ReplyTo
+	if content.ReplyTo == "" {
+		content.ReplyTo = content.From.ReplyTo
+	}
+	content.Headers, _ = sender.FilterHeaders(bulk.Headers)
+	content.Tags = notificationTags(bulk)
+	content.Attachments, err = sender.LoadAttachments(bulk.Attachments, c.attachments)
+	if err != nil {
+		logger.Log.Warn("failed to load bulk attachments, sending individually", zap.Error(err))
+		return pending, nil
+	}
+
+	var individual, batch []models.EmailMessage
+	var recipients []sender.BulkRecipient
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := bulkSender.SendBulk(ctx, &sender.BulkEmail{Email: *content, Recipients: recipients})
+		if err != nil {
+			// The batch goes out per recipient, each taking its own slot
+			c.releaseThrottle(batch)
+			if ctx.Err() != nil {
+				return err
+			}
+			if !errors.Is(err, sender.ErrBulkUnsupported) {
+				logger.Log.Warn("bulk send failed, sending batch individually",
+					zap.Error(err),
+					zap.String("batch_id", bulk.NotificationID),
+					zap.Int("recipients", len(batch)),
+				)
+			}
+			individual = append(individual, batch...)
+		} else {
+			for i := range batch {
+				if err := c.idempotency.MarkProcessed(ctx, batch[i].NotificationID); err != nil {
+					logger.Log.Error("failed to mark as processed", zap.Error(err))
+				}
+				// The batch shares one provider ID, so index each recipient
+				if result.ProviderMessageID != "" {
+					ref := webhook.MessageRef{
+						NotificationID: batch[i].NotificationID,
+						UserID:         batch[i].UserID,
+						Provider:       result.Provider,
+					}
+					if err := c.messageIndex.RecordRecipient(ctx, result.ProviderMessageID, batch[i].Recipient, ref); err != nil {
+						logger.Log.Error("failed to record provider message id", zap.Error(err))
+					}
+				}
+				c.publishResult(&batch[i], models.StatusMessage{
+					NotificationID:    batch[i].NotificationID,
+					UserID:            batch[i].UserID,
+					Status:            "sent",
+					Provider:          result.Provider,
+					ProviderMessageID: result.ProviderMessageID,
+				})
+			}
+		}
+		batch, recipients = nil, nil
+		return nil
+	}
+
+	for _, msg := range pending {
+		recipient, ok := c.bulkRecipient(ctx, &msg, tmpl, tags)
+		if !ok {
+			individual = append(individual, msg)
+			continue
+		}
+		batch = append(batch, msg)
+		recipients = append(recipients, recipient)
+		if len(batch) == sender.MaxBulkRecipients {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return individual, nil
+}
+
+// bulkRecipient prepares a recipient's personalization, returning false when
+// the recipient must go through the individual send path instead
+func (c *Consumer) bulkRecipient(ctx context.Context, msg *models.EmailMessage, tmpl *models.EmailTemplate, tags map[string]string) (sender.BulkRecipient, bool) {
+	if err := (&sender.Email{To: msg.Recipient}).Validate(); err != nil {
+		return sender.BulkRecipient{}, false
+	}
+	if err := c.validator.Validate(ctx, msg.Recipient); err != nil {
+		return sender.BulkRecipient{}, false
+	}
+	if entry, err := c.suppressions.Get(ctx, msg.Recipient, msg.Category); err != nil || entry != nil {
+		return sender.BulkRecipient{}, false
+	}
+
+	recipient := sender.BulkRecipient{
+		To:             msg.Recipient,
+		NotificationID: msg.NotificationID,
+		Substitutions:  make(map[string]string, len(tags)),
+	}
+	for key, tag := range tags {
+		value, ok := msg.Variables[key]
+		if !ok && containsTag(tmpl, tag) {
+			return sender.BulkRecipient{}, false // missing variable, reported by the individual send
+		}
+		if ok {
+			recipient.Substitutions[tag] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	if c.unsubscribe != nil && !unsubscribe.IsTransactional(msg.Category) {
+		headers, err := c.unsubscribe.Headers(msg.UserID, msg.Category, msg.Recipient)
+		if err != nil {
+			return sender.BulkRecipient{}, false
+		}
+		recipient.Headers = headers
+	}
+
+	// Reserve the domain slot last, so rejected recipients do not use one up
+	if c.throttle != nil {
+		if domain, err := validation.CheckSyntax(msg.Recipient); err == nil {
+			if wait, err := c.throttle.Reserve(ctx, domain); err == nil && wait > 0 {
+				return sender.BulkRecipient{}, false
+			}
+		}
+	}
+
+	return recipient, true
+}
+
+// releaseThrottle gives back the domain slots a batch reserved
+func (c *Consumer) releaseThrottle(batch []models.EmailMessage) {
+	if c.throttle == nil {
+		return
+	}
+	ctx := context.WithoutCancel(c.ctx)
+	for i := range batch {
+		domain, err := validation.CheckSyntax(batch[i].Recipient)
+		if err != nil {
+			continue
+		}
+		if err := c.throttle.Release(ctx, domain); err != nil {
+			logger.Log.Warn("failed to release domain throttle slot", zap.Error(err), zap.String("domain", domain))
+		}
+	}
+}
+
+// expandBulk turns a bulk message into one message per recipient, each
+// tagged with the bulk message's ID as its batch ID
+func expandBulk(bulk *models.EmailMessage) []models.EmailMessage {
+	messages := make([]models.EmailMessage, 0, len(bulk.Recipients))
+	for i, recipient := range bulk.Recipients {
+		msg := *bulk
+		msg.Recipients = nil
+		msg.Cc, msg.Bcc = nil, nil
+		msg.Subject, msg.Body, msg.TextBody = "", "", "" // rendered per recipient from template_code
+		msg.BatchID = bulk.NotificationID
+		msg.Recipient = recipient.Email
+
+		msg.NotificationID = recipient.NotificationID
+		if msg.NotificationID == "" {
+			msg.NotificationID = fmt.Sprintf("%s-%d", bulk.NotificationID, i+1)
+		}
+		if recipient.UserID != "" {
+			msg.UserID = recipient.UserID
+		}
+
+		msg.Variables = make(map[string]interface{}, len(bulk.Variables)+len(recipient.Variables))
+		for key, value := range bulk.Variables {
+			msg.Variables[key] = value
+		}
+		for key, value := range recipient.Variables {
+			msg.Variables[key] = value
+		}
+
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func containsTag(tmpl *models.EmailTemplate, tag string) bool {
+	return strings.Contains(tmpl.Subject, tag) || strings.Contains(tmpl.Body, tag)
+}
@@ -0,0 +1,344 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/idempotency"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/throttle"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/validation"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestExpandBulk(t *testing.T) {
+	bulk := &models.EmailMessage{
+		NotificationID: "campaign-7",
+		UserID:         "marketing",
+		TemplateCode:   "spring_sale",
+		Subject:        "ignored",
+		Cc:             []string{"audit@example.com"},
+		Variables:      map[string]interface{}{"discount": "20%", "name": "there"},
+		Recipients: []models.BulkRecipient{
+			{Email: "ade@example.com", UserID: "u1", Variables: map[string]interface{}{"name": "Ade"}},
+			{Email: "bisi@example.com", NotificationID: "n-bisi"},
+		},
+	}
+
+	messages := expandBulk(bulk)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages", len(messages))
+	}
+
+	first, second := messages[0], messages[1]
+	if first.NotificationID != "campaign-7-1" || first.UserID != "u1" || first.Recipient != "ade@example.com" {
+		t.Errorf("first = %+v", first)
+	}
+	if first.Variables["name"] != "Ade" || first.Variables["discount"] != "20%" {
+		t.Errorf("first variables = %v", first.Variables)
+	}
+	if second.NotificationID != "n-bisi" || second.UserID != "marketing" || second.Variables["name"] != "there" {
+		t.Errorf("second = %+v", second)
+	}
+	for _, msg := range messages {
+		if msg.BatchID != "campaign-7" || msg.Recipients != nil || msg.Cc != nil || msg.Subject != "" {
+			t.Errorf("message = %+v", msg)
+		}
+	}
+	if bulk.Variables["name"] != "there" {
+		t.Error("expandBulk modified the bulk message's variables")
+	}
+}
+
+type fakeBulkSender struct {
+	mu          sync.Mutex
+	batches     [][]sender.BulkRecipient
+	calls       int
+	err         error // returned by SendBulk when set
+	unsupported bool  // reported by SupportsBulk
+}
+
+func (s *fakeBulkSender) Send(ctx context.Context, email *sender.Email) (*sender.SendResult, error) {
+	return nil, errors.New("unexpected individual send")
+}
+
+func (s *fakeBulkSender) SendBulk(ctx context.Context, email *sender.BulkEmail) (*sender.SendResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.batches = append(s.batches, email.Recipients)
+	return &sender.SendResult{Provider: "sendgrid", ProviderMessageID: fmt.Sprintf("batch-%d", len(s.batches))}, nil
+}
+
+func (s *fakeBulkSender) SupportsBulk() bool {
+	return !s.unsupported
+}
+
+func (s *fakeBulkSender) GetProviderName() string {
+	return "sendgrid"
+}
+
+// fakeDelay records released messages instead of publishing them
+type fakeDelay struct {
+	mu       sync.Mutex
+	released []models.EmailMessage
+}
+
+func (d *fakeDelay) Defer(ctx context.Context, delivery amqp.Delivery, delay time.Duration) (time.Duration, error) {
+	return delay, nil
+}
+
+func (d *fakeDelay) Release(ctx context.Context, body []byte) error {
+	var msg models.EmailMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.released = append(d.released, msg)
+	return nil
+}
+
+func (d *fakeDelay) Reopen(conn *amqp.Connection) error { return nil }
+func (d *fakeDelay) Close()                             {}
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	statuses []models.StatusMessage
+}
+
+func (p *recordingPublisher) PublishStatus(ctx context.Context, status models.StatusMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses = append(p.statuses, status)
+	return nil
+}
+
+// ackRecorder records how a delivery was settled
+type ackRecorder struct {
+	acks, nacks, requeues int
+}
+
+func (a *ackRecorder) Ack(tag uint64, multiple bool) error {
+	a.acks++
+	return nil
+}
+
+func (a *ackRecorder) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacks++
+	if requeue {
+		a.requeues++
+	}
+	return nil
+}
+
+func (a *ackRecorder) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+type bulkTest struct {
+	consumer  *Consumer
+	sender    *fakeBulkSender
+	delay     *fakeDelay
+	publisher *recordingPublisher
+	mr        *miniredis.Miniredis
+}
+
+func newBulkTest(t *testing.T) *bulkTest {
+	t.Helper()
+	logger.Log = zap.NewNop()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	// The template client reads from its Redis cache before the service
+	cached, _ := json.Marshal(models.EmailTemplate{Subject: "Hi {{name}}", Body: "<p>{{discount}} off, {{name}}</p>"})
+	mr.Set("template:spring_sale", string(cached))
+
+	identities, err := sender.NewIdentityResolver(config.IdentityConfig{FromAddress: "noreply@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	bt := &bulkTest{sender: &fakeBulkSender{}, delay: &fakeDelay{}, publisher: &recordingPublisher{}, mr: mr}
+	bt.consumer = &Consumer{
+		templateClient: template.NewClient("http://template-service.invalid", rdb),
+		emailSender:    bt.sender,
+		publisher:      bt.publisher,
+		idempotency:    idempotency.NewChecker(rdb, time.Hour),
+		identities:     identities,
+		messageIndex:   webhook.NewMessageIndex(rdb, time.Hour),
+		suppressions:   suppression.NewMemoryStore(),
+		validator:      validation.NewValidator(false, nil, nil, 0),
+		throttle:       throttle.NewDomainThrottle(rdb, map[string]throttle.Limit{"example.com": {Count: 10000, Window: time.Hour}}, throttle.Limit{}),
+		deferrer:       bt.delay,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	return bt
+}
+
+func (bt *bulkTest) process(t *testing.T, bulk *models.EmailMessage) *ackRecorder {
+	t.Helper()
+	body, err := json.Marshal(bulk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack := &ackRecorder{}
+	bt.consumer.processBulk(amqp.Delivery{Acknowledger: ack, Body: body}, bulk)
+	return ack
+}
+
+// throttleSlots is the example.com slots taken in the current window
+func (bt *bulkTest) throttleSlots() int {
+	value, err := bt.mr.Get("email:throttle:example.com")
+	if err != nil {
+		return 0
+	}
+	slots, _ := strconv.Atoi(value)
+	return slots
+}
+
+func newBulkMessage(recipients int) *models.EmailMessage {
+	bulk := &models.EmailMessage{
+		NotificationID: "campaign-1",
+		UserID:         "marketing",
+		TemplateCode:   "spring_sale",
+		Category:       "transactional",
+		Variables:      map[string]interface{}{"discount": "20%", "name": "there"},
+	}
+	for i := 0; i < recipients; i++ {
+		bulk.Recipients = append(bulk.Recipients, models.BulkRecipient{
+			Email:     fmt.Sprintf("user%d@example.com", i+1),
+			Variables: map[string]interface{}{"name": fmt.Sprintf("User %d", i+1)},
+		})
+	}
+	return bulk
+}
+
+func TestProcessBulkSendsInBatches(t *testing.T) {
+	bt := newBulkTest(t)
+	ctx := context.Background()
+
+	bulk := newBulkMessage(sender.MaxBulkRecipients + 2)
+	suppressed := bulk.Recipients[1].Email
+	if err := bt.consumer.suppressions.Add(ctx, suppression.Entry{Address: suppressed, Reason: "bounce"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ack := bt.process(t, bulk)
+	if ack.acks != 1 || ack.nacks != 0 {
+		t.Fatalf("acks %d, nacks %d", ack.acks, ack.nacks)
+	}
+
+	// The suppressed recipient leaves one more than the limit for the batches
+	if len(bt.sender.batches) != 2 || len(bt.sender.batches[0]) != sender.MaxBulkRecipients || len(bt.sender.batches[1]) != 1 {
+		t.Fatalf("batches = %d", len(bt.sender.batches))
+	}
+	first := bt.sender.batches[0][0]
+	if first.To != "user1@example.com" || first.NotificationID != "campaign-1-1" || first.Substitutions["{{name}}"] != "User 1" {
+		t.Errorf("first recipient = %+v", first)
+	}
+
+	// The suppressed recipient goes through the individual path
+	if len(bt.delay.released) != 1 || bt.delay.released[0].Recipient != suppressed || bt.delay.released[0].BatchID != "campaign-1" {
+		t.Errorf("released = %+v", bt.delay.released)
+	}
+
+	if len(bt.publisher.statuses) != sender.MaxBulkRecipients+1 {
+		t.Fatalf("published %d statuses", len(bt.publisher.statuses))
+	}
+	for _, status := range bt.publisher.statuses {
+		if status.Status != "sent" || status.BatchID != "campaign-1" || status.Provider != "sendgrid" {
+			t.Fatalf("status = %+v", status)
+		}
+	}
+	if got := bt.throttleSlots(); got != sender.MaxBulkRecipients+1 {
+		t.Errorf("throttle slots = %d, want one per recipient sent", got)
+	}
+
+	// Each recipient is indexed under its batch's provider ID
+	ref, err := bt.consumer.messageIndex.Lookup(ctx, "batch-2", "user1002@example.com")
+	if err != nil || ref == nil || ref.NotificationID != "campaign-1-1002" {
+		t.Errorf("Lookup = %+v, %v", ref, err)
+	}
+	for _, id := range []string{"campaign-1", "campaign-1-1", "campaign-1-1002"} {
+		if processed, _ := bt.consumer.idempotency.IsProcessed(ctx, id); !processed {
+			t.Errorf("%s not marked processed", id)
+		}
+	}
+}
+
+func TestProcessBulkSkipsProcessedRecipients(t *testing.T) {
+	bt := newBulkTest(t)
+	if err := bt.consumer.idempotency.MarkProcessed(context.Background(), "campaign-1-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	bt.process(t, newBulkMessage(2))
+
+	if len(bt.sender.batches) != 1 || len(bt.sender.batches[0]) != 1 || bt.sender.batches[0][0].To != "user2@example.com" {
+		t.Fatalf("batches = %+v", bt.sender.batches)
+	}
+	if len(bt.publisher.statuses) != 1 || bt.publisher.statuses[0].NotificationID != "campaign-1-2" {
+		t.Errorf("statuses = %+v", bt.publisher.statuses)
+	}
+}
+
+func TestProcessBulkFansOutWithoutBulkSupport(t *testing.T) {
+	bt := newBulkTest(t)
+	bt.sender.unsupported = true
+
+	ack := bt.process(t, newBulkMessage(3))
+
+	if ack.acks != 1 || bt.sender.calls != 0 {
+		t.Fatalf("acks %d, bulk calls %d", ack.acks, bt.sender.calls)
+	}
+	if len(bt.delay.released) != 3 {
+		t.Errorf("released %d messages, want 3", len(bt.delay.released))
+	}
+	if got := bt.throttleSlots(); got != 0 {
+		t.Errorf("throttle slots = %d, want none before the individual sends", got)
+	}
+}
+
+func TestProcessBulkReleasesSlotsOfRefusedBatch(t *testing.T) {
+	for _, err := range []error{sender.ErrBulkUnsupported, errors.New("service unavailable")} {
+		bt := newBulkTest(t)
+		bt.sender.err = err
+
+		ack := bt.process(t, newBulkMessage(3))
+
+		if ack.acks != 1 || bt.sender.calls != 1 {
+			t.Fatalf("%v: acks %d, bulk calls %d", err, ack.acks, bt.sender.calls)
+		}
+		if len(bt.delay.released) != 3 || len(bt.publisher.statuses) != 0 {
+			t.Errorf("%v: released %d, statuses %d", err, len(bt.delay.released), len(bt.publisher.statuses))
+		}
+		if got := bt.throttleSlots(); got != 0 {
+			t.Errorf("%v: throttle slots = %d, want them released", err, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// confirmTimeout bounds how long a publish waits for the broker's confirm
+const confirmTimeout = 10 * time.Second
+
+// ErrPublishNotConfirmed is returned when the broker nacks a publish or the
+// channel closes before confirming it
+var ErrPublishNotConfirmed = errors.New("publish not confirmed by broker")
+
+// openConfirmChannel opens a channel in publisher confirm mode
+func openConfirmChannel(conn *amqp.Connection) (*amqp.Channel, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return channel, nil
+}
+
+// publishConfirmed publishes on a channel in confirm mode and waits until the
+// broker has taken the message, so the caller can safely ack the original
+func publishConfirmed(ctx context.Context, channel *amqp.Channel, exchange, key string, msg amqp.Publishing) error {
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+	if confirmation == nil {
+		return fmt.Errorf("channel is not in confirm mode")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for publish confirm: %w", err)
+	}
+	if !acked {
+		return ErrPublishNotConfirmed
+	}
+	return nil
+}
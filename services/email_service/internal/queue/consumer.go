@@ -3,32 +3,59 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/digest"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/idempotency"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/retry"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/scheduler"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/template"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/throttle"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/tracking"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/unsubscribe"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/validation"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/webhook"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
 	amqp "github.com/rabbitmq/amqp091-go"
-	"github.com/sony/gobreaker"
 	"go.uber.org/zap"
 )
 
 type Consumer struct {
+	url            string
+	mu             sync.RWMutex // guards conn, channel, router and status
 	conn           *amqp.Connection
 	channel        *amqp.Channel
+	router         *amqp.Channel
+	status         ConsumerStatus
 	queueName      string
+	dlx            string
+	dlxRoutingKey  string
 	workerCount    int
+	reserved       int    // workers that only take critical messages
+	schedule       []Lane // lane order shared workers prefer
 	templateClient *template.Client
 	emailSender    sender.EmailSender
-	publisher      *Publisher
+	publisher      webhook.StatusPublisher
 	idempotency    *idempotency.Checker
 	retryHandler   *retry.Handler
-	circuitBreaker *gobreaker.CircuitBreaker
+	attachments    sender.AttachmentPolicy
+	identities     *sender.IdentityResolver
+	messageIndex   *webhook.MessageIndex
+	suppressions   suppression.Store
+	unsubscribe    *unsubscribe.Signer
+	tracker        *tracking.Tracker
+	validator      *validation.Validator
+	throttle       *throttle.DomainThrottle
+	deferrer       delayQueue
+	maxDeferrals   int
+	scheduler      *scheduler.Scheduler
+	digester       *digest.Digester
 	ctx            context.Context
 	cancel         context.CancelFunc
 	wg             sync.WaitGroup
@@ -38,109 +65,115 @@ type ConsumerConfig struct {
 	URL            string
 	QueueName      string
 	WorkerCount    int
+	Lanes          LaneConfig
 	TemplateClient *template.Client
 	EmailSender    sender.EmailSender
-	Publisher      *Publisher
+	Publisher      webhook.StatusPublisher
 	Idempotency    *idempotency.Checker
 	RetryHandler   *retry.Handler
-	CircuitBreaker *gobreaker.CircuitBreaker
+	Attachments    sender.AttachmentPolicy
+	Identities     *sender.IdentityResolver
+	MessageIndex   *webhook.MessageIndex
+	Suppressions   suppression.Store
+	Unsubscribe    *unsubscribe.Signer // nil disables List-Unsubscribe headers
+	Tracker        *tracking.Tracker   // nil disables open and click tracking
+	Validator      *validation.Validator
+	Throttle       *throttle.DomainThrottle // nil disables per-domain rate limits
+	Scheduler      *scheduler.Scheduler     // parks send_at and quiet-hours messages
+	Digester       *digest.Digester         // nil sends digestible messages individually
+	MaxDeferrals   int                      // delay-queue waits before a message fails; 0 is unlimited
 }
 
 func NewConsumer(cfg ConsumerConfig) (*Consumer, error) {
-	conn, err := amqp.Dial(cfg.URL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	weights := cfg.Lanes.Weights
+	if weights == nil {
+		weights = DefaultLaneWeights
 	}
-
-	// Don't defer conn.Close() here - connection needs to stay open for consumer lifetime
-	// It will be closed in Stop() method along with the channel
-
-	channel, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+	reserved := cfg.Lanes.CriticalWorkers
+	if reserved > cfg.WorkerCount-1 {
+		reserved = cfg.WorkerCount - 1
 	}
-
-	// Don't defer channel close either - both stay open for consumer lifetime
-	// Both will be closed in Stop() method
-
-	// Set QoS to process one message at a time per worker
-	err = channel.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to set QoS: %w", err)
-	}
-
-	// Use passive declaration to verify queue exists without modifying it
-	// Queue should already be created by rabbitmq-init with proper configuration
-	_, err = channel.QueueDeclarePassive(
-		cfg.QueueName,
-		true,  // durable
-		false, // delete when unused
-		false, // exclusive
-		false, // no-wait
-		nil,   // arguments
-	)
-	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to verify queue exists: %w (ensure rabbitmq-init has run)", err)
+	if reserved < 0 {
+		reserved = 0
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Consumer{
-		conn:           conn,
-		channel:        channel,
+	c := &Consumer{
+		url:            cfg.URL,
+		status:         ConsumerStatus{State: StateStarting, Since: time.Now().UTC()},
 		queueName:      cfg.QueueName,
+		dlx:            cfg.Lanes.DLX,
+		dlxRoutingKey:  cfg.Lanes.DLXRoutingKey,
 		workerCount:    cfg.WorkerCount,
+		reserved:       reserved,
+		schedule:       laneSchedule(weights),
 		templateClient: cfg.TemplateClient,
 		emailSender:    cfg.EmailSender,
 		publisher:      cfg.Publisher,
 		idempotency:    cfg.Idempotency,
 		retryHandler:   cfg.RetryHandler,
-		circuitBreaker: cfg.CircuitBreaker,
+		attachments:    cfg.Attachments,
+		identities:     cfg.Identities,
+		messageIndex:   cfg.MessageIndex,
+		suppressions:   cfg.Suppressions,
+		unsubscribe:    cfg.Unsubscribe,
+		tracker:        cfg.Tracker,
+		validator:      cfg.Validator,
+		throttle:       cfg.Throttle,
+		scheduler:      cfg.Scheduler,
+		digester:       cfg.Digester,
+		maxDeferrals:   cfg.MaxDeferrals,
 		ctx:            ctx,
 		cancel:         cancel,
-	}, nil
+	}
+
+	// The first connection fails fast so misconfiguration stops startup;
+	// later losses are handled by the supervisor started in Start
+	if err := c.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *Consumer) Start() error {
-	msgs, err := c.channel.Consume(
-		c.queueName,
-		"",    // consumer tag
-		false, // auto-ack (we want manual ack)
-		false, // exclusive
-		false, // no-local
-		false, // no-wait
-		nil,   // args
-	)
+	s, err := c.consume()
 	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
+		return err
 	}
+	c.setStatus(StateConsuming, nil)
 
 	logger.Log.Info("starting email consumer",
 		zap.String("queue", c.queueName),
 		zap.Int("workers", c.workerCount),
+		zap.Int("critical_workers", c.reserved),
 	)
 
-	// Start worker pool
-	for i := 0; i < c.workerCount; i++ {
+	// Run the workers, reconnecting whenever RabbitMQ goes away
+	c.wg.Add(1)
+	go c.supervise(s)
+
+	// Release scheduled messages back to the queue as they become due
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.scheduler.Run(c.ctx, time.Second, c.deferrer.Release)
+	}()
+
+	// Queue digests as their batching windows close
+	if c.digester != nil {
 		c.wg.Add(1)
-		go c.worker(i, msgs)
+		go func() {
+			defer c.wg.Done()
+			c.digester.Run(c.ctx, 5*time.Second, c.deferrer.Release)
+		}()
 	}
 
 	return nil
 }
 
 func (c *Consumer) worker(id int, msgs <-chan amqp.Delivery) {
-	defer c.wg.Done()
-
 	logger.Log.Info("worker started", zap.Int("worker_id", id))
 
 	for {
@@ -159,6 +192,22 @@ func (c *Consumer) worker(id int, msgs <-chan amqp.Delivery) {
 	}
 }
 
+// sharedWorker serves every lane, preferring them in weighted turns
+func (c *Consumer) sharedWorker(id int, msgs map[Lane]<-chan amqp.Delivery) {
+	logger.Log.Info("worker started", zap.Int("worker_id", id))
+
+	// Offset by id so workers don't all prefer the same lane at once
+	for turn := id; ; turn++ {
+		msg, lane, ok := nextDelivery(c.ctx, c.schedule[turn%len(c.schedule)], msgs)
+		if !ok {
+			logger.Log.Info("worker stopping", zap.Int("worker_id", id))
+			return
+		}
+		logger.Log.Info("worker received message", zap.Int("worker_id", id), zap.String("lane", string(lane)), zap.Int("body_size", len(msg.Body)))
+		c.processMessage(msg)
+	}
+}
+
 func (c *Consumer) processMessage(delivery amqp.Delivery) {
 	logger.Log.Info("processMessage called", zap.String("body", string(delivery.Body)))
 	
@@ -187,8 +236,79 @@ func (c *Consumer) processMessage(delivery amqp.Delivery) {
 		return
 	}
 
-	// Process with retry
-	err = c.processWithRetry(&emailMsg)
+	// Messages with a future send_at or inside quiet hours wait in the scheduler
+	if c.parkIfNotDue(delivery, &emailMsg) {
+		return
+	}
+
+	// Bulk messages are split into one send per recipient
+	if len(emailMsg.Recipients) > 0 {
+		c.processBulk(delivery, &emailMsg)
+		return
+	}
+
+	// Digestible notifications wait to be batched into one email per user
+	if emailMsg.Digest && c.digester != nil && c.addToDigest(delivery, &emailMsg) {
+		return
+	}
+
+	// Bursts to one domain trigger greylisting, so over-limit messages wait
+	// in a delay queue instead of holding a worker
+	if c.throttle != nil && c.deferIfThrottled(delivery, &emailMsg) {
+		return
+	}
+
+	// Process with retry. Shutdown cancels c.ctx, which aborts in-flight sends.
+	result, err := c.processWithRetry(c.ctx, &emailMsg)
+
+	var suppressed *suppression.SuppressedError
+	if errors.As(err, &suppressed) {
+		logger.Log.Info("recipient suppressed, not sending",
+			zap.String("notification_id", emailMsg.NotificationID),
+			zap.String("recipient", suppressed.Entry.Address),
+			zap.String("reason", suppressed.Entry.Reason),
+		)
+		c.publishResult(&emailMsg, models.StatusMessage{
+			NotificationID: emailMsg.NotificationID,
+			UserID:         emailMsg.UserID,
+			Status:         "suppressed",
+			Error:          suppressed.Error(),
+		})
+		if err := c.idempotency.MarkProcessed(c.ctx, emailMsg.NotificationID); err != nil {
+			logger.Log.Error("failed to mark as processed", zap.Error(err))
+		}
+		delivery.Ack(false)
+		return
+	}
+
+	if err != nil && c.ctx.Err() != nil {
+		// Interrupted by shutdown rather than failed, so hand it back to the queue
+		logger.Log.Warn("email send interrupted by shutdown, requeueing",
+			zap.Error(err),
+			zap.String("notification_id", emailMsg.NotificationID),
+		)
+		delivery.Nack(false, true)
+		return
+	}
+
+	// Every provider is out of daily quota, so wait in a delay queue for the
+	// quota to reset instead of failing the notification, up to the deferral
+	// limit
+	if sender.QuotaExhausted(err) {
+		delay, deferErr := c.deferrer.Defer(c.ctx, delivery, time.Hour)
+		if deferErr == nil {
+			delivery.Ack(false)
+			logger.Log.Warn("provider quota exhausted, message deferred",
+				zap.String("notification_id", emailMsg.NotificationID),
+				zap.Duration("delay", delay),
+			)
+			return
+		}
+		logger.Log.Error("failed to defer message", zap.Error(deferErr))
+		if errors.Is(deferErr, ErrTooManyDeferrals) {
+			err = fmt.Errorf("%w: %w", deferErr, err)
+		}
+	}
 
 	if err != nil {
 		logger.Log.Error("failed to process email after retries",
@@ -196,8 +316,18 @@ func (c *Consumer) processMessage(delivery amqp.Delivery) {
 			zap.String("notification_id", emailMsg.NotificationID),
 		)
 
-		// Publish failed status
-		c.publishStatus(emailMsg.NotificationID, emailMsg.UserID, "failed", err.Error())
+		// Publish failed status, naming the provider that failed last
+		provider := sender.FailedProvider(err)
+		if provider == "" {
+			provider = c.emailSender.GetProviderName()
+		}
+		c.publishResult(&emailMsg, models.StatusMessage{
+			NotificationID: emailMsg.NotificationID,
+			UserID:         emailMsg.UserID,
+			Status:         "failed",
+			Error:          err.Error(),
+			Provider:       provider,
+		})
 
 		// Don't requeue - message goes to DLQ or is discarded
 		delivery.Nack(false, false)
@@ -209,8 +339,27 @@ func (c *Consumer) processMessage(delivery amqp.Delivery) {
 		logger.Log.Error("failed to mark as processed", zap.Error(err))
 	}
 
+	// Remember the provider's ID so webhook events can be attributed later
+	if result.ProviderMessageID != "" {
+		ref := webhook.MessageRef{
+			NotificationID: emailMsg.NotificationID,
+			UserID:         emailMsg.UserID,
+			Provider:       result.Provider,
+		}
+		if err := c.messageIndex.Record(c.ctx, result.ProviderMessageID, ref); err != nil {
+			logger.Log.Error("failed to record provider message id", zap.Error(err))
+		}
+	}
+
 	// Publish success status
-	c.publishStatus(emailMsg.NotificationID, emailMsg.UserID, "sent", "")
+	c.publishResult(&emailMsg, models.StatusMessage{
+		NotificationID:    emailMsg.NotificationID,
+		UserID:            emailMsg.UserID,
+		Status:            "sent",
+		Provider:          result.Provider,
+		ProviderMessageID: result.ProviderMessageID,
+		MessageID:         result.MessageID,
+	})
 
 	// Acknowledge message
 	delivery.Ack(false)
@@ -218,26 +367,32 @@ func (c *Consumer) processMessage(delivery amqp.Delivery) {
 	logger.Log.Info("email sent successfully",
 		zap.String("notification_id", emailMsg.NotificationID),
 		zap.String("recipient", emailMsg.Recipient),
+		zap.String("provider", result.Provider),
+		zap.String("provider_message_id", result.ProviderMessageID),
+		zap.String("message_id", result.MessageID),
+		zap.Any("provider_response", result.Metadata),
 	)
 }
 
-func (c *Consumer) processWithRetry(emailMsg *models.EmailMessage) error {
+func (c *Consumer) processWithRetry(ctx context.Context, emailMsg *models.EmailMessage) (*sender.SendResult, error) {
 	var lastErr error
 	maxAttempts := 5
 
 	for attempt := 0; attempt <= maxAttempts; attempt++ {
 		if attempt > 0 {
-			c.retryHandler.Wait(attempt-1, emailMsg.NotificationID)
+			if err := c.retryHandler.Wait(ctx, attempt-1, emailMsg.NotificationID); err != nil {
+				return nil, fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
 		}
 
-		err := c.processEmail(emailMsg)
+		result, err := c.processEmail(ctx, emailMsg)
 		if err == nil {
-			return nil // Success
+			return result, nil // Success
 		}
 
 		lastErr = err
 
-		if !c.retryHandler.ShouldRetry(err, attempt) {
+		if !c.retryHandler.ShouldRetry(err, attempt) || sender.QuotaExhausted(err) {
 			logger.Log.Warn("not retrying",
 				zap.Error(err),
 				zap.Int("attempt", attempt),
@@ -253,39 +408,40 @@ func (c *Consumer) processWithRetry(emailMsg *models.EmailMessage) error {
 		)
 	}
 
-	return lastErr
+	return nil, lastErr
 }
 
-func (c *Consumer) processEmail(emailMsg *models.EmailMessage) error {
-	var subject, body string
+func (c *Consumer) processEmail(ctx context.Context, emailMsg *models.EmailMessage) (*sender.SendResult, error) {
+	var subject, body, textBody string
 
 	// Check if message already contains rendered content (from API Gateway)
 	if emailMsg.Subject != "" && emailMsg.Body != "" {
 		// Use pre-rendered content
 		subject = emailMsg.Subject
 		body = emailMsg.Body
+		textBody = emailMsg.TextBody
 
 		logger.Log.Info("using pre-rendered content from API Gateway",
 			zap.String("notification_id", emailMsg.NotificationID),
 		)
 	} else {
 		// Fallback: Fetch and render template
-		tmpl, err := c.templateClient.FetchTemplate(c.ctx, emailMsg.TemplateCode)
+		tmpl, err := c.templateClient.FetchTemplate(ctx, emailMsg.TemplateCode)
 		if err != nil {
-			return fmt.Errorf("failed to fetch template: %w", err)
+			return nil, fmt.Errorf("failed to fetch template: %w", err)
 		}
 
 		// Render subject
 		renderedSubject, err := template.RenderTemplate(tmpl.Subject, emailMsg.Variables)
 		if err != nil {
-			return fmt.Errorf("failed to render subject: %w", err)
+			return nil, fmt.Errorf("failed to render subject: %w", err)
 		}
 		subject = renderedSubject
 
 		// Render body
 		renderedBody, err := template.RenderTemplate(tmpl.Body, emailMsg.Variables)
 		if err != nil {
-			return fmt.Errorf("failed to render body: %w", err)
+			return nil, fmt.Errorf("failed to render body: %w", err)
 		}
 		body = renderedBody
 
@@ -295,28 +451,255 @@ func (c *Consumer) processEmail(emailMsg *models.EmailMessage) error {
 		)
 	}
 
-	// Send email with circuit breaker
-	_, err := c.circuitBreaker.Execute(func() (interface{}, error) {
-		return nil, c.emailSender.Send(emailMsg.Recipient, subject, body)
-	})
+	// The plain-text part is derived from the HTML body when not provided
+	email := sender.NewEmail(emailMsg.Recipient, subject, body, textBody)
+	email.Cc = emailMsg.Cc
+	email.Bcc = emailMsg.Bcc
+	email.From = c.identities.Resolve(emailMsg)
+	email.ReplyTo = emailMsg.ReplyTo
+	if email.ReplyTo == "" {
+		email.ReplyTo = email.From.ReplyTo
+	}
+	email.Tags = notificationTags(emailMsg)
+	if err := email.Validate(); err != nil {
+		return nil, err
+	}
+	// Undeliverable recipients fail permanently instead of using up retries
+	for _, address := range email.Recipients() {
+		if err := c.validator.Validate(ctx, address); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applySuppressions(ctx, emailMsg, email); err != nil {
+		return nil, err
+	}
+
+	headers, rejected := sender.FilterHeaders(emailMsg.Headers)
+	if len(rejected) > 0 {
+		logger.Log.Warn("dropping custom headers that are not allowed",
+			zap.String("notification_id", emailMsg.NotificationID),
+			zap.Strings("headers", rejected),
+		)
+	}
+	email.Headers = headers
+
+	// Bulk-sender rules require one-click unsubscribe on non-transactional mail
+	if c.unsubscribe != nil && !unsubscribe.IsTransactional(emailMsg.Category) {
+		listHeaders, err := c.unsubscribe.Headers(emailMsg.UserID, emailMsg.Category, email.To)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create unsubscribe headers: %w", err)
+		}
+		for key, value := range listHeaders {
+			email.Headers[key] = value
+		}
+	}
+
+	// Tracking only touches the HTML part; the text part keeps the original links
+	if c.tracker != nil && (emailMsg.Tracking.Opens || emailMsg.Tracking.Clicks) && email.HTMLBody != "" {
+		target := tracking.Target{NotificationID: emailMsg.NotificationID, UserID: emailMsg.UserID}
+		instrumented, err := c.tracker.Instrument(email.HTMLBody, target, emailMsg.Tracking.Opens, emailMsg.Tracking.Clicks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add tracking: %w", err)
+		}
+		email.HTMLBody = instrumented
+	}
+
+	attachments, err := sender.LoadAttachments(emailMsg.Attachments, c.attachments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attachments: %w", err)
+	}
+	email.Attachments = attachments
 
+	// Send email; circuit breaking and provider failover happen in the sender
+	result, err := c.emailSender.Send(ctx, email)
 	if err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+		return nil, fmt.Errorf("failed to send email: %w", err)
 	}
 
+	return result, nil
+}
+
+// applySuppressions fails with a *suppression.SuppressedError when the
+// primary recipient is suppressed for the message's category and silently
+// drops suppressed Cc and Bcc recipients
+func (c *Consumer) applySuppressions(ctx context.Context, emailMsg *models.EmailMessage, email *sender.Email) error {
+	notificationID := emailMsg.NotificationID
+
+	entry, err := c.suppressions.Get(ctx, email.To, emailMsg.Category)
+	if err != nil {
+		return fmt.Errorf("failed to check suppression list: %w", err)
+	}
+	if entry != nil {
+		return &suppression.SuppressedError{Entry: *entry}
+	}
+
+	keep := func(addresses []string) []string {
+		var kept []string
+		for _, address := range addresses {
+			entry, err := c.suppressions.Get(ctx, address, emailMsg.Category)
+			if err != nil {
+				logger.Log.Error("failed to check suppression list", zap.Error(err))
+			}
+			if entry != nil {
+				logger.Log.Info("dropping suppressed recipient",
+					zap.String("notification_id", notificationID),
+					zap.String("recipient", entry.Address),
+					zap.String("reason", entry.Reason),
+				)
+				continue
+			}
+			kept = append(kept, address)
+		}
+		return kept
+	}
+	email.Cc = keep(email.Cc)
+	email.Bcc = keep(email.Bcc)
+
 	return nil
 }
 
-func (c *Consumer) publishStatus(notificationID, userID, status, errorMsg string) {
-	statusMsg := models.StatusMessage{
-		NotificationID: notificationID,
-		UserID:         userID,
-		Status:         status,
-		Timestamp:      time.Now(),
-		Error:          errorMsg,
-		Provider:       c.emailSender.GetProviderName(),
+// parkIfNotDue hands messages that are not due yet to the scheduler, acks
+// them and returns true. Invalid schedules fail the message.
+func (c *Consumer) parkIfNotDue(delivery amqp.Delivery, emailMsg *models.EmailMessage) bool {
+	due, err := scheduler.DueAt(emailMsg, time.Now())
+	if err != nil {
+		logger.Log.Error("invalid schedule",
+			zap.Error(err),
+			zap.String("notification_id", emailMsg.NotificationID),
+		)
+		c.publishStatus(models.StatusMessage{
+			NotificationID: emailMsg.NotificationID,
+			UserID:         emailMsg.UserID,
+			Status:         "failed",
+			Error:          err.Error(),
+		})
+		delivery.Nack(false, false)
+		return true
+	}
+	if time.Until(due) < scheduler.Tolerance {
+		return false
+	}
+
+	if err := c.scheduler.Schedule(c.ctx, emailMsg.NotificationID, delivery.Body, due); err != nil {
+		// Try again shortly rather than sending early
+		logger.Log.Error("failed to schedule message", zap.Error(err), zap.String("notification_id", emailMsg.NotificationID))
+		if _, err := c.deferrer.Defer(c.ctx, delivery, time.Minute); err != nil {
+			if errors.Is(err, ErrTooManyDeferrals) {
+				c.publishStatus(models.StatusMessage{
+					NotificationID: emailMsg.NotificationID,
+					UserID:         emailMsg.UserID,
+					Status:         "failed",
+					Error:          fmt.Sprintf("failed to schedule message: %v", err),
+				})
+				delivery.Nack(false, false)
+				return true
+			}
+			delivery.Nack(false, true)
+			return true
+		}
+		delivery.Ack(false)
+		return true
+	}
+	delivery.Ack(false)
+
+	due = due.UTC()
+	c.publishStatus(models.StatusMessage{
+		NotificationID: emailMsg.NotificationID,
+		UserID:         emailMsg.UserID,
+		Status:         "scheduled",
+		ScheduledAt:    &due,
+		BatchID:        emailMsg.BatchID,
+	})
+	logger.Log.Info("message scheduled",
+		zap.String("notification_id", emailMsg.NotificationID),
+		zap.Time("send_at", due),
+	)
+	return true
+}
+
+// addToDigest adds the message to its user's digest batch and acks it,
+// returning false (send individually) when the batch cannot be written
+func (c *Consumer) addToDigest(delivery amqp.Delivery, emailMsg *models.EmailMessage) bool {
+	if err := c.digester.Add(c.ctx, emailMsg); err != nil {
+		logger.Log.Error("failed to add message to digest, sending individually",
+			zap.Error(err),
+			zap.String("notification_id", emailMsg.NotificationID),
+		)
+		return false
 	}
 
+	if err := c.idempotency.MarkProcessed(c.ctx, emailMsg.NotificationID); err != nil {
+		logger.Log.Error("failed to mark as processed", zap.Error(err))
+	}
+	delivery.Ack(false)
+
+	logger.Log.Info("message added to digest",
+		zap.String("notification_id", emailMsg.NotificationID),
+		zap.String("user_id", emailMsg.UserID),
+		zap.String("category", emailMsg.Category),
+	)
+	return true
+}
+
+// deferIfThrottled takes a send slot for the recipient's domain. When the
+// domain is over its limit the message is moved to a delay queue and acked,
+// and true is returned. Throttle or queue errors let the send go ahead.
+func (c *Consumer) deferIfThrottled(delivery amqp.Delivery, emailMsg *models.EmailMessage) bool {
+	domain, err := validation.CheckSyntax(emailMsg.Recipient)
+	if err != nil {
+		return false // fails validation when processed
+	}
+
+	wait, err := c.throttle.Reserve(c.ctx, domain)
+	if err != nil {
+		logger.Log.Error("failed to check domain throttle", zap.Error(err), zap.String("domain", domain))
+		return false
+	}
+	if wait == 0 {
+		return false
+	}
+
+	delay, err := c.deferrer.Defer(c.ctx, delivery, wait)
+	if err != nil {
+		logger.Log.Error("failed to defer throttled message, sending anyway",
+			zap.Error(err),
+			zap.String("notification_id", emailMsg.NotificationID),
+		)
+		return false
+	}
+	delivery.Ack(false)
+
+	logger.Log.Info("recipient domain throttled, message deferred",
+		zap.String("notification_id", emailMsg.NotificationID),
+		zap.String("domain", domain),
+		zap.Duration("window_resets_in", wait),
+		zap.Duration("delay", delay),
+	)
+	return true
+}
+
+// publishResult publishes the outcome of a send. A digest reports it for
+// every notification it covers, with "sent" reported as "digested".
+func (c *Consumer) publishResult(emailMsg *models.EmailMessage, statusMsg models.StatusMessage) {
+	statusMsg.BatchID = emailMsg.BatchID
+	if len(emailMsg.DigestOf) == 0 {
+		c.publishStatus(statusMsg)
+		return
+	}
+
+	if statusMsg.Status == "sent" {
+		statusMsg.Status = "digested"
+	}
+	for _, notificationID := range emailMsg.DigestOf {
+		statusMsg.NotificationID = notificationID
+		c.publishStatus(statusMsg)
+	}
+}
+
+// publishStatus stamps and publishes a status update
+func (c *Consumer) publishStatus(statusMsg models.StatusMessage) {
+	statusMsg.Timestamp = time.Now()
+
 	if err := c.publisher.PublishStatus(c.ctx, statusMsg); err != nil {
 		logger.Log.Error("failed to publish status", zap.Error(err))
 	}
@@ -327,12 +710,21 @@ func (c *Consumer) Stop() {
 	c.cancel()
 	c.wg.Wait()
 
-	if c.channel != nil {
-		c.channel.Close()
-	}
-	if c.conn != nil {
-		c.conn.Close()
-	}
+	c.deferrer.Close()
+	c.closeConnection()
+	c.setStatus(StateStopped, nil)
 
 	logger.Log.Info("consumer stopped")
 }
+
+// notificationTags tags outgoing mail with its notification type and category
+// for provider analytics
+func notificationTags(msg *models.EmailMessage) []string {
+	var tags []string
+	for _, tag := range []string{msg.NotificationType, msg.Category} {
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+)
+
+func TestPublishResultReportsEveryDigestItem(t *testing.T) {
+	bt := newBulkTest(t)
+	digest := &models.EmailMessage{NotificationID: "digest-n1", DigestOf: []string{"n1", "n2", "n3"}}
+
+	bt.consumer.publishResult(digest, models.StatusMessage{NotificationID: digest.NotificationID, UserID: "u1", Status: "sent", Provider: "smtp"})
+	bt.consumer.publishResult(digest, models.StatusMessage{NotificationID: digest.NotificationID, UserID: "u1", Status: "failed", Error: "mailbox full"})
+
+	statuses := bt.publisher.statuses
+	if len(statuses) != 6 {
+		t.Fatalf("published %d statuses, want 6", len(statuses))
+	}
+	for i, status := range statuses {
+		wantStatus := "digested"
+		if i >= 3 {
+			wantStatus = "failed"
+		}
+		if status.NotificationID != digest.DigestOf[i%3] || status.Status != wantStatus || status.UserID != "u1" {
+			t.Errorf("status %d = %+v", i, status)
+		}
+	}
+}
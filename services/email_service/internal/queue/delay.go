@@ -0,0 +1,246 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deferredCountHeader counts how often a message was put back for later
+const deferredCountHeader = "x-deferred-count"
+
+// ErrTooManyDeferrals is returned by Defer once a message has been deferred
+// the maximum number of times; the caller fails it instead
+var ErrTooManyDeferrals = errors.New("message deferred too many times")
+
+// delayBuckets are the delay queues messages can wait in. Each queue has a
+// single TTL, so messages never wait behind one with a longer delay.
+var delayBuckets = []time.Duration{
+	time.Second,
+	5 * time.Second,
+	15 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+}
+
+// delayQueue parks messages for later and puts messages back on the work
+// queue. The Deferrer is the RabbitMQ implementation.
+type delayQueue interface {
+	Defer(ctx context.Context, delivery amqp.Delivery, delay time.Duration) (time.Duration, error)
+	Release(ctx context.Context, body []byte) error
+	Reopen(conn *amqp.Connection) error
+	Close()
+}
+
+// Deferrer puts messages back on the work queue after a delay without
+// holding a worker. A message waits in a "<queue>.delay.<ttl>" queue until
+// its TTL expires and RabbitMQ dead-letters it back to the work queue.
+// Publishes are confirmed by the broker before Defer and Release return.
+// A delay channel closed by the broker, e.g. after a failed declare, is
+// reopened on the next use.
+type Deferrer struct {
+	queueName    string
+	maxDeferrals int // 0 defers without limit
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	declared map[time.Duration]string
+}
+
+func NewDeferrer(conn *amqp.Connection, queueName string, maxDeferrals int) (*Deferrer, error) {
+	channel, err := openConfirmChannel(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delay channel: %w", err)
+	}
+
+	return &Deferrer{
+		conn:         conn,
+		channel:      channel,
+		queueName:    queueName,
+		maxDeferrals: maxDeferrals,
+		declared:     make(map[time.Duration]string),
+	}, nil
+}
+
+// Reopen moves the deferrer onto a new connection after a reconnect. Delay
+// queues are declared again on first use in case the broker lost them.
+func (d *Deferrer) Reopen(conn *amqp.Connection) error {
+	channel, err := openConfirmChannel(conn)
+	if err != nil {
+		return fmt.Errorf("failed to open delay channel: %w", err)
+	}
+
+	d.mu.Lock()
+	old := d.channel
+	d.conn, d.channel = conn, channel
+	d.declared = make(map[time.Duration]string)
+	d.mu.Unlock()
+
+	old.Close()
+	return nil
+}
+
+// Defer republishes the delivery to come back after at least delay (rounded
+// up to the next bucket, capped at the largest). The caller acks the
+// original once Defer succeeds. A message already deferred maxDeferrals
+// times is refused with ErrTooManyDeferrals.
+func (d *Deferrer) Defer(ctx context.Context, delivery amqp.Delivery, delay time.Duration) (time.Duration, error) {
+	count := deferredCount(delivery.Headers)
+	if d.maxDeferrals > 0 && count >= d.maxDeferrals {
+		return 0, fmt.Errorf("%w (%d)", ErrTooManyDeferrals, count)
+	}
+
+	bucket := delayBucket(delay)
+	queue, err := d.declare(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	headers := amqp.Table{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+	headers[deferredCountHeader] = int32(count + 1)
+
+	channel, err := d.current()
+	if err != nil {
+		return 0, err
+	}
+	err = publishConfirmed(ctx, channel,
+		"",    // exchange
+		queue, // routing key
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   delivery.ContentType,
+			CorrelationId: delivery.CorrelationId,
+			MessageId:     delivery.MessageId,
+			Priority:      delivery.Priority,
+			DeliveryMode:  amqp.Persistent,
+			Body:          delivery.Body,
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish deferred message: %w", err)
+	}
+	return bucket, nil
+}
+
+// Release publishes a message body straight to the work queue, e.g. a
+// scheduled send that has become due
+func (d *Deferrer) Release(ctx context.Context, body []byte) error {
+	channel, err := d.current()
+	if err != nil {
+		return err
+	}
+	err = publishConfirmed(ctx, channel,
+		"",          // exchange
+		d.queueName, // routing key
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish released message: %w", err)
+	}
+	return nil
+}
+
+func (d *Deferrer) current() (*amqp.Channel, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.openChannel()
+}
+
+// openChannel returns the delay channel, replacing it when the broker has
+// closed it. The delay queues are declared again on the new channel. The
+// caller holds d.mu.
+func (d *Deferrer) openChannel() (*amqp.Channel, error) {
+	if !d.channel.IsClosed() {
+		return d.channel, nil
+	}
+
+	channel, err := openConfirmChannel(d.conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen delay channel: %w", err)
+	}
+	d.channel = channel
+	d.declared = make(map[time.Duration]string)
+	return channel, nil
+}
+
+// declare creates the delay queue for a bucket on first use
+func (d *Deferrer) declare(bucket time.Duration) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if name, ok := d.declared[bucket]; ok {
+		return name, nil
+	}
+
+	channel, err := d.openChannel()
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s.delay.%s", d.queueName, bucket)
+	_, err = channel.QueueDeclare(
+		name,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		amqp.Table{
+			"x-message-ttl":             bucket.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": d.queueName,
+		},
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to declare delay queue %s: %w", name, err)
+	}
+
+	d.declared[bucket] = name
+	return name, nil
+}
+
+// deferredCount reads the deferral count header, which comes back from the
+// broker as whichever integer type it was published with
+func deferredCount(headers amqp.Table) int {
+	switch count := headers[deferredCountHeader].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	case int:
+		return count
+	default:
+		return 0
+	}
+}
+
+// delayBucket returns the smallest bucket of at least delay
+func delayBucket(delay time.Duration) time.Duration {
+	for _, bucket := range delayBuckets {
+		if delay <= bucket {
+			return bucket
+		}
+	}
+	return delayBuckets[len(delayBuckets)-1]
+}
+
+func (d *Deferrer) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.channel != nil {
+		d.channel.Close()
+	}
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestDeferredCount(t *testing.T) {
+	tests := []struct {
+		headers amqp.Table
+		want    int
+	}{
+		{nil, 0},
+		{amqp.Table{deferredCountHeader: int32(3)}, 3},
+		{amqp.Table{deferredCountHeader: int64(7)}, 7},
+		{amqp.Table{deferredCountHeader: "5"}, 0},
+	}
+	for _, tt := range tests {
+		if got := deferredCount(tt.headers); got != tt.want {
+			t.Errorf("deferredCount(%v) = %d, want %d", tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestDeferRefusesPastLimit(t *testing.T) {
+	// No channel: a refused message never reaches the broker
+	d := &Deferrer{queueName: "email.queue", maxDeferrals: 3, declared: map[time.Duration]string{}}
+
+	delivery := amqp.Delivery{Headers: amqp.Table{deferredCountHeader: int32(3)}}
+	if _, err := d.Defer(context.Background(), delivery, time.Hour); !errors.Is(err, ErrTooManyDeferrals) {
+		t.Errorf("Defer() after 3 deferrals = %v, want ErrTooManyDeferrals", err)
+	}
+}
+
+func TestDelayBucket(t *testing.T) {
+	tests := []struct {
+		delay, want time.Duration
+	}{
+		{0, time.Second},
+		{time.Second, time.Second},
+		{2 * time.Second, 5 * time.Second},
+		{10 * time.Minute, 15 * time.Minute},
+		{24 * time.Hour, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := delayBucket(tt.delay); got != tt.want {
+			t.Errorf("delayBucket(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// Lane is a priority class with its own queue, so an OTP never waits behind
+// a marketing blast
+type Lane string
+
+const (
+	LaneCritical Lane = "critical"
+	LaneNormal   Lane = "normal"
+	LaneBulk     Lane = "bulk"
+)
+
+// lanes in the order idle workers drain them
+var lanes = []Lane{LaneCritical, LaneNormal, LaneBulk}
+
+// DefaultLaneWeights is how often shared workers prefer each lane while
+// every lane has work
+var DefaultLaneWeights = map[Lane]int{
+	LaneCritical: 6,
+	LaneNormal:   3,
+	LaneBulk:     1,
+}
+
+// LaneConfig sets how workers share the lanes
+type LaneConfig struct {
+	CriticalWorkers int          // reserved for the critical lane; at least one worker stays shared
+	Weights         map[Lane]int // nil uses DefaultLaneWeights
+	DLX             string       // dead-letter exchange for rejected lane messages
+	DLXRoutingKey   string
+}
+
+// LaneForPriority maps EmailMessage.Priority (1 high, 2 normal, 3 low) to a
+// lane. Unset or unknown priorities are normal.
+func LaneForPriority(priority int) Lane {
+	switch priority {
+	case 1:
+		return LaneCritical
+	case 3:
+		return LaneBulk
+	default:
+		return LaneNormal
+	}
+}
+
+// LaneQueue names a lane's queue after the work queue, e.g. "email.queue.bulk"
+func LaneQueue(queueName string, lane Lane) string {
+	return queueName + "." + string(lane)
+}
+
+// ParseLaneWeights parses "lane=weight" entries such as "critical=6".
+// Lanes left out keep their default weight. A zero weight means the lane is
+// only served when the others are empty.
+func ParseLaneWeights(entries []string) (map[Lane]int, error) {
+	weights := make(map[Lane]int, len(DefaultLaneWeights))
+	for lane, weight := range DefaultLaneWeights {
+		weights[lane] = weight
+	}
+
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, "=")
+		lane := Lane(strings.ToLower(strings.TrimSpace(name)))
+		if _, known := DefaultLaneWeights[lane]; !ok || !known {
+			return nil, fmt.Errorf("invalid lane weight %q: expected critical, normal or bulk=<weight>", entry)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid lane weight %q: weight must be a non-negative integer", entry)
+		}
+		weights[lane] = weight
+	}
+
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("lane weights must not all be zero")
+	}
+	return weights, nil
+}
+
+// laneSchedule spreads each lane over one cycle in proportion to its weight
+// (smooth weighted round-robin), so critical=6,normal=3,bulk=1 interleaves
+// rather than serving six criticals in a row
+func laneSchedule(weights map[Lane]int) []Lane {
+	total := 0
+	for _, lane := range lanes {
+		total += weights[lane]
+	}
+
+	current := make(map[Lane]int, len(lanes))
+	schedule := make([]Lane, 0, total)
+	for i := 0; i < total; i++ {
+		var best Lane
+		for _, lane := range lanes {
+			current[lane] += weights[lane]
+			if best == "" || current[lane] > current[best] {
+				best = lane
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, best)
+	}
+	return schedule
+}
+
+// nextDelivery takes a delivery from the preferred lane if one is waiting,
+// otherwise from the first lane with work, otherwise waits for any lane. It
+// reports false once ctx is cancelled or a lane's channel closes.
+func nextDelivery(ctx context.Context, preferred Lane, msgs map[Lane]<-chan amqp.Delivery) (amqp.Delivery, Lane, bool) {
+	for _, lane := range append([]Lane{preferred}, lanes...) {
+		select {
+		case msg, ok := <-msgs[lane]:
+			return msg, lane, ok
+		default:
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return amqp.Delivery{}, "", false
+	case msg, ok := <-msgs[LaneCritical]:
+		return msg, LaneCritical, ok
+	case msg, ok := <-msgs[LaneNormal]:
+		return msg, LaneNormal, ok
+	case msg, ok := <-msgs[LaneBulk]:
+		return msg, LaneBulk, ok
+	}
+}
+
+// LaneDepth is the backlog of one lane's queue
+type LaneDepth struct {
+	Lane      string `json:"lane"`
+	Queue     string `json:"queue"`
+	Messages  int    `json:"messages"`
+	Consumers int    `json:"consumers"`
+}
+
+// declareLanes creates the lane queues. Rejected messages dead-letter like
+// the work queue's do.
+func declareLanes(channel *amqp.Channel, queueName, deadLetterExchange, deadLetterRoutingKey string) error {
+	var args amqp.Table
+	if deadLetterExchange != "" {
+		args = amqp.Table{
+			"x-dead-letter-exchange":    deadLetterExchange,
+			"x-dead-letter-routing-key": deadLetterRoutingKey,
+		}
+	}
+
+	for _, lane := range lanes {
+		_, err := channel.QueueDeclare(
+			LaneQueue(queueName, lane),
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			args,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to declare %s lane queue: %w", lane, err)
+		}
+	}
+	return nil
+}
+
+// route moves messages from the work queue to their lane queues. Routing
+// only reads the priority, so a backlog drains in moments and the workers
+// then pick by lane.
+func (c *Consumer) route(router *amqp.Channel, msgs <-chan amqp.Delivery) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case delivery, ok := <-msgs:
+			if !ok {
+				logger.Log.Info("routing channel closed")
+				return
+			}
+			c.routeDelivery(router, delivery)
+		}
+	}
+}
+
+// routeDelivery copies a message to its lane queue and acks the original once
+// the broker confirms the copy; otherwise the original is requeued
+func (c *Consumer) routeDelivery(router *amqp.Channel, delivery amqp.Delivery) {
+	var envelope struct {
+		Priority int `json:"priority"`
+	}
+	if err := json.Unmarshal(delivery.Body, &envelope); err != nil {
+		logger.Log.Error("failed to unmarshal message", zap.Error(err), zap.String("raw_body", string(delivery.Body)))
+		delivery.Nack(false, false) // Don't requeue invalid messages
+		return
+	}
+
+	lane := LaneForPriority(envelope.Priority)
+	err := publishConfirmed(c.ctx, router,
+		"",                           // exchange
+		LaneQueue(c.queueName, lane), // routing key
+		amqp.Publishing{
+			Headers:       delivery.Headers,
+			ContentType:   delivery.ContentType,
+			CorrelationId: delivery.CorrelationId,
+			MessageId:     delivery.MessageId,
+			Priority:      delivery.Priority,
+			DeliveryMode:  amqp.Persistent,
+			Body:          delivery.Body,
+		},
+	)
+	if err != nil {
+		logger.Log.Error("failed to route message to lane", zap.Error(err), zap.String("lane", string(lane)))
+		delivery.Nack(false, true)
+		return
+	}
+	delivery.Ack(false)
+}
+
+// LaneDepths reports how many messages wait in the work queue and in each
+// lane
+func (c *Consumer) LaneDepths() ([]LaneDepth, error) {
+	c.mu.RLock()
+	conn := c.conn
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("not connected to RabbitMQ")
+	}
+
+	// A failed passive declare closes its channel, so use a throwaway one
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer channel.Close()
+
+	depths := []LaneDepth{{Lane: "ingress", Queue: c.queueName}}
+	for _, lane := range lanes {
+		depths = append(depths, LaneDepth{Lane: string(lane), Queue: LaneQueue(c.queueName, lane)})
+	}
+
+	for i := range depths {
+		q, err := channel.QueueDeclarePassive(depths[i].Queue, true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %s: %w", depths[i].Queue, err)
+		}
+		depths[i].Messages = q.Messages
+		depths[i].Consumers = q.Consumers
+	}
+	return depths, nil
+}
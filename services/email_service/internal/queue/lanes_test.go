@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestLaneForPriority(t *testing.T) {
+	cases := map[int]Lane{0: LaneNormal, 1: LaneCritical, 2: LaneNormal, 3: LaneBulk, 9: LaneNormal}
+	for priority, want := range cases {
+		if got := LaneForPriority(priority); got != want {
+			t.Errorf("LaneForPriority(%d) = %s, want %s", priority, got, want)
+		}
+	}
+}
+
+func TestParseLaneWeights(t *testing.T) {
+	weights, err := ParseLaneWeights([]string{"bulk=0", " Critical = 8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights[LaneCritical] != 8 || weights[LaneNormal] != 3 || weights[LaneBulk] != 0 {
+		t.Errorf("weights = %v", weights)
+	}
+
+	for _, entries := range [][]string{
+		{"urgent=5"},
+		{"bulk"},
+		{"bulk=-1"},
+		{"critical=0", "normal=0", "bulk=0"},
+	} {
+		if _, err := ParseLaneWeights(entries); err == nil {
+			t.Errorf("ParseLaneWeights(%v) succeeded", entries)
+		}
+	}
+}
+
+func TestLaneScheduleInterleaves(t *testing.T) {
+	schedule := laneSchedule(DefaultLaneWeights)
+
+	var got []string
+	for _, lane := range schedule {
+		got = append(got, string(lane[:1]))
+	}
+	if s := strings.Join(got, ""); s != "cnccncbcnc" {
+		t.Errorf("schedule = %s", s)
+	}
+
+	if s := laneSchedule(map[Lane]int{LaneNormal: 1}); len(s) != 1 || s[0] != LaneNormal {
+		t.Errorf("schedule = %v", s)
+	}
+}
+
+func TestNextDeliveryPrefersLaneThenFallsBack(t *testing.T) {
+	critical := make(chan amqp.Delivery, 1)
+	normal := make(chan amqp.Delivery, 1)
+	bulk := make(chan amqp.Delivery, 1)
+	msgs := map[Lane]<-chan amqp.Delivery{LaneCritical: critical, LaneNormal: normal, LaneBulk: bulk}
+
+	critical <- amqp.Delivery{MessageId: "c"}
+	bulk <- amqp.Delivery{MessageId: "b"}
+
+	ctx := context.Background()
+	if msg, lane, _ := nextDelivery(ctx, LaneBulk, msgs); lane != LaneBulk || msg.MessageId != "b" {
+		t.Errorf("got %s from %s, want the preferred bulk lane", msg.MessageId, lane)
+	}
+	// Normal is empty, so the worker takes critical work instead of idling
+	if msg, lane, _ := nextDelivery(ctx, LaneNormal, msgs); lane != LaneCritical || msg.MessageId != "c" {
+		t.Errorf("got %s from %s, want fallback to critical", msg.MessageId, lane)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		normal <- amqp.Delivery{MessageId: "n"}
+	}()
+	if msg, lane, ok := nextDelivery(ctx, LaneBulk, msgs); !ok || lane != LaneNormal || msg.MessageId != "n" {
+		t.Errorf("got %s from %s, want to wait for normal", msg.MessageId, lane)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, ok := nextDelivery(cancelled, LaneCritical, msgs); ok {
+		t.Error("nextDelivery returned a delivery after cancellation")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
@@ -11,27 +13,62 @@ import (
 	"go.uber.org/zap"
 )
 
+// publishWait is how long a status publish waits for a lost connection to
+// come back before failing
+const publishWait = 5 * time.Second
+
+// Publisher publishes status updates, reconnecting in the background when
+// RabbitMQ goes away
 type Publisher struct {
+	url   string
+	queue string
+
+	mu      sync.RWMutex
 	conn    *amqp.Connection
 	channel *amqp.Channel
-	queue   string
+	ready   chan struct{} // closed while connected
+
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewPublisher(url, queueName string) (*Publisher, error) {
-	conn, err := amqp.Dial(url)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		url:    url,
+		queue:  queueName,
+		ready:  make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	connClosed, channelClosed, err := p.connect()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		cancel()
+		return nil, err
+	}
+	go p.supervise(connClosed, channelClosed)
+
+	return p, nil
+}
+
+// connect dials RabbitMQ, declares the status queue and returns the
+// connection's and channel's close notifications
+func (p *Publisher) connect() (chan *amqp.Error, chan *amqp.Error, error) {
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
 	channel, err := conn.Channel()
 	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
 	}
 
 	// Declare queue
 	_, err = channel.QueueDeclare(
-		queueName,
+		p.queue,
 		true,  // durable
 		false, // delete when unused
 		false, // exclusive
@@ -39,16 +76,91 @@ func NewPublisher(url, queueName string) (*Publisher, error) {
 		nil,   // arguments
 	)
 	if err != nil {
-		channel.Close()
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return nil, nil, fmt.Errorf("failed to declare queue: %w", err)
 	}
 
-	return &Publisher{
-		conn:    conn,
-		channel: channel,
-		queue:   queueName,
-	}, nil
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+	p.mu.Lock()
+	p.conn, p.channel = conn, channel
+	close(p.ready)
+	p.mu.Unlock()
+
+	return connClosed, channelClosed, nil
+}
+
+// supervise reconnects with backoff whenever the connection or channel closes
+func (p *Publisher) supervise(connClosed, channelClosed chan *amqp.Error) {
+	for {
+		var reason error
+		select {
+		case <-p.ctx.Done():
+			return
+		case err := <-connClosed:
+			reason = closeReason("connection", err)
+		case err := <-channelClosed:
+			reason = closeReason("channel", err)
+		}
+
+		p.mu.Lock()
+		conn := p.conn
+		p.conn, p.channel = nil, nil
+		p.ready = make(chan struct{})
+		p.mu.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+		logger.Log.Error("status publisher lost RabbitMQ, reconnecting", zap.Error(reason))
+
+		for attempt := 0; ; attempt++ {
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(reconnectDelay(attempt)):
+			}
+
+			var err error
+			connClosed, channelClosed, err = p.connect()
+			if err == nil {
+				logger.Log.Info("status publisher reconnected to RabbitMQ", zap.Int("attempts", attempt+1))
+				break
+			}
+			logger.Log.Warn("failed to reconnect status publisher",
+				zap.Error(err),
+				zap.Int("attempt", attempt+1),
+			)
+		}
+	}
+}
+
+// awaitChannel returns the current channel, waiting up to publishWait for a
+// reconnect when the connection is down
+func (p *Publisher) awaitChannel(ctx context.Context) (*amqp.Channel, error) {
+	p.mu.RLock()
+	channel, ready := p.channel, p.ready
+	p.mu.RUnlock()
+	if channel != nil {
+		return channel, nil
+	}
+
+	timer := time.NewTimer(publishWait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("not connected to RabbitMQ")
+	case <-ready:
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		if p.channel == nil {
+			return nil, fmt.Errorf("not connected to RabbitMQ")
+		}
+		return p.channel, nil
+	}
 }
 
 func (p *Publisher) PublishStatus(ctx context.Context, status models.StatusMessage) error {
@@ -57,7 +169,12 @@ func (p *Publisher) PublishStatus(ctx context.Context, status models.StatusMessa
 		return fmt.Errorf("failed to marshal status: %w", err)
 	}
 
-	err = p.channel.PublishWithContext(
+	channel, err := p.awaitChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to publish status: %w", err)
+	}
+
+	err = channel.PublishWithContext(
 		ctx,
 		"",      // exchange
 		p.queue, // routing key
@@ -83,6 +200,10 @@ func (p *Publisher) PublishStatus(ctx context.Context, status models.StatusMessa
 }
 
 func (p *Publisher) Close() {
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if p.channel != nil {
 		p.channel.Close()
 	}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// reconnectDelay is the wait before reconnection attempt n (from 0),
+// doubling from one second up to 30 seconds
+func reconnectDelay(attempt int) time.Duration {
+	if attempt >= 5 {
+		return maxReconnectDelay
+	}
+	delay := minReconnectDelay << attempt
+	if delay > maxReconnectDelay {
+		return maxReconnectDelay
+	}
+	return delay
+}
+
+// closeReason describes a close notification. A nil error means the
+// connection or channel was closed on purpose.
+func closeReason(what string, err *amqp.Error) error {
+	if err == nil {
+		return fmt.Errorf("%s closed", what)
+	}
+	return fmt.Errorf("%s closed: %w", what, err)
+}
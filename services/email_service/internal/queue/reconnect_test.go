@@ -0,0 +1,21 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectDelay(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:   time.Second,
+		1:   2 * time.Second,
+		4:   16 * time.Second,
+		5:   30 * time.Second,
+		100: 30 * time.Second,
+	}
+	for attempt, want := range cases {
+		if got := reconnectDelay(attempt); got != want {
+			t.Errorf("reconnectDelay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
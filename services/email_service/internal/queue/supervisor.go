@@ -0,0 +1,310 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+// Consumer states reported by Status
+const (
+	StateStarting     = "starting"
+	StateConsuming    = "consuming"
+	StateReconnecting = "reconnecting"
+	StateStopped      = "stopped"
+)
+
+// ConsumerStatus describes the consumer's connection to RabbitMQ
+type ConsumerStatus struct {
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`                // when the consumer entered State
+	LastError  string    `json:"last_error,omitempty"` // why it is reconnecting
+	Reconnects int       `json:"reconnects"`
+}
+
+// session is one connection's consumers and the notifications that end it
+type session struct {
+	router   *amqp.Channel
+	incoming <-chan amqp.Delivery
+	lanes    map[Lane]<-chan amqp.Delivery
+
+	connClosed       chan *amqp.Error
+	channelClosed    chan *amqp.Error
+	routerClosed     chan *amqp.Error
+	channelCancelled chan string
+	routerCancelled  chan string
+}
+
+// Status reports the consumer's connection state
+func (c *Consumer) Status() ConsumerStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+func (c *Consumer) setStatus(state string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status.State != state {
+		c.status.State = state
+		c.status.Since = time.Now().UTC()
+	}
+	c.status.LastError = ""
+	if err != nil {
+		c.status.LastError = err.Error()
+	}
+}
+
+// connect dials RabbitMQ and prepares the channels: QoS, the work queue
+// check, the lane queues and the delay channel
+func (c *Consumer) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	// The connection and its channels stay open until Stop or a reconnect;
+	// closing the connection closes the channels with it
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	// Let each lane prefetch enough to keep every worker busy
+	err = channel.Qos(
+		c.workerCount, // prefetch count
+		0,             // prefetch size
+		false,         // global
+	)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	// Use passive declaration to verify queue exists without modifying it
+	// Queue should already be created by rabbitmq-init with proper configuration
+	_, err = channel.QueueDeclarePassive(
+		c.queueName,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to verify queue exists: %w (ensure rabbitmq-init has run)", err)
+	}
+
+	if err := declareLanes(channel, c.queueName, c.dlx, c.dlxRoutingKey); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// The router acks a message only once its lane copy is confirmed
+	router, err := openConfirmChannel(conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open routing channel: %w", err)
+	}
+	if err := router.Qos(100, 0, false); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set routing QoS: %w", err)
+	}
+
+	if c.deferrer == nil {
+		var deferrer *Deferrer
+		if deferrer, err = NewDeferrer(conn, c.queueName, c.maxDeferrals); err == nil {
+			c.deferrer = deferrer
+		}
+	} else {
+		err = c.deferrer.Reopen(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.mu.Lock()
+	c.conn, c.channel, c.router = conn, channel, router
+	c.mu.Unlock()
+	return nil
+}
+
+// consume starts the routing and lane consumers on the current connection
+func (c *Consumer) consume() (*session, error) {
+	c.mu.RLock()
+	conn, channel, router := c.conn, c.channel, c.router
+	c.mu.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("not connected to RabbitMQ")
+	}
+
+	// Notification channels are buffered so the client never blocks on them
+	s := &session{
+		router:           router,
+		lanes:            make(map[Lane]<-chan amqp.Delivery, len(lanes)),
+		connClosed:       conn.NotifyClose(make(chan *amqp.Error, 1)),
+		channelClosed:    channel.NotifyClose(make(chan *amqp.Error, 1)),
+		routerClosed:     router.NotifyClose(make(chan *amqp.Error, 1)),
+		channelCancelled: channel.NotifyCancel(make(chan string, len(lanes))),
+		routerCancelled:  router.NotifyCancel(make(chan string, 1)),
+	}
+
+	incoming, err := router.Consume(
+		c.queueName,
+		"",    // consumer tag
+		false, // auto-ack (we want manual ack)
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start consuming: %w", err)
+	}
+	s.incoming = incoming
+
+	for _, lane := range lanes {
+		msgs, err := channel.Consume(
+			LaneQueue(c.queueName, lane),
+			"",    // consumer tag
+			false, // auto-ack (we want manual ack)
+			false, // exclusive
+			false, // no-local
+			false, // no-wait
+			nil,   // args
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start consuming %s lane: %w", lane, err)
+		}
+		s.lanes[lane] = msgs
+	}
+
+	return s, nil
+}
+
+// supervise runs the workers on one connection after another. RabbitMQ
+// restarts, closed channels and consumers cancelled by the broker (e.g. a
+// deleted queue) all end the session and trigger a reconnect.
+func (c *Consumer) supervise(s *session) {
+	defer c.wg.Done()
+
+	for s != nil {
+		reason := c.run(s)
+		if reason == nil {
+			return // shutting down
+		}
+		logger.Log.Error("lost RabbitMQ consumer, reconnecting", zap.Error(reason))
+		s = c.reconnect(reason)
+	}
+}
+
+// run serves a session until shutdown (returning nil) or until it ends,
+// returning why
+func (c *Consumer) run(s *session) error {
+	var workers sync.WaitGroup
+
+	// Sort incoming messages into their lanes
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		c.route(s.router, s.incoming)
+	}()
+
+	// Start worker pool: reserved workers keep critical mail moving while the
+	// shared ones are busy with a bulk backlog
+	for i := 0; i < c.workerCount; i++ {
+		workers.Add(1)
+		go func(id int) {
+			defer workers.Done()
+			if id < c.reserved {
+				c.worker(id, s.lanes[LaneCritical])
+			} else {
+				c.sharedWorker(id, s.lanes)
+			}
+		}(i)
+	}
+
+	var reason error
+	select {
+	case <-c.ctx.Done():
+	case err := <-s.connClosed:
+		reason = closeReason("connection", err)
+	case err := <-s.channelClosed:
+		reason = closeReason("consumer channel", err)
+	case err := <-s.routerClosed:
+		reason = closeReason("routing channel", err)
+	case tag := <-s.channelCancelled:
+		reason = fmt.Errorf("lane consumer %s cancelled by broker", tag)
+	case tag := <-s.routerCancelled:
+		reason = fmt.Errorf("routing consumer %s cancelled by broker", tag)
+	}
+
+	// Closing the connection closes every delivery channel, which stops the
+	// workers; RabbitMQ redelivers whatever they had not acked
+	if reason != nil {
+		c.setStatus(StateReconnecting, reason)
+		c.closeConnection()
+	}
+	workers.Wait()
+	return reason
+}
+
+// reconnect dials again with backoff until consuming resumes, returning nil
+// if the consumer is stopped first
+func (c *Consumer) reconnect(reason error) *session {
+	for attempt := 0; ; attempt++ {
+		delay := reconnectDelay(attempt)
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		err := c.connect()
+		var s *session
+		if err == nil {
+			if s, err = c.consume(); err != nil {
+				c.closeConnection()
+			}
+		}
+		if err != nil {
+			c.setStatus(StateReconnecting, err)
+			logger.Log.Warn("failed to reconnect to RabbitMQ",
+				zap.Error(err),
+				zap.Int("attempt", attempt+1),
+				zap.Duration("next_delay", reconnectDelay(attempt+1)),
+			)
+			continue
+		}
+
+		c.mu.Lock()
+		c.status.Reconnects++
+		c.mu.Unlock()
+		c.setStatus(StateConsuming, nil)
+		logger.Log.Info("reconnected to RabbitMQ",
+			zap.Int("attempts", attempt+1),
+			zap.NamedError("after", reason),
+		)
+		return s
+	}
+}
+
+// closeConnection closes the current connection and its channels
+func (c *Consumer) closeConnection() {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn, c.channel, c.router = nil, nil, nil
+	c.mu.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+}
@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrQuotaExhausted is returned by Acquire once a provider's daily quota is
+// used up. It lasts until the next UTC day.
+var ErrQuotaExhausted = errors.New("provider daily quota exhausted")
+
+// Limit is a provider's sending quota. PerSecond is a token bucket refilled
+// continuously with a burst of one second's worth; Daily counts sends per
+// UTC day. Zero leaves either unlimited.
+type Limit struct {
+	PerSecond int
+	Daily     int
+}
+
+// Quota reports what is left of a provider's limit
+type Quota struct {
+	Provider        string    `json:"provider"`
+	PerSecond       int       `json:"per_second,omitempty"`
+	TokensAvailable float64   `json:"tokens_available,omitempty"`
+	Daily           int       `json:"daily,omitempty"`
+	DailyRemaining  int       `json:"daily_remaining,omitempty"`
+	DailyResetsAt   time.Time `json:"daily_resets_at,omitempty"`
+}
+
+// Limiter hands out provider send slots across every replica and worker
+type Limiter interface {
+	// Acquire blocks until the provider may send one message, returning
+	// ErrQuotaExhausted when its daily quota is used up. Providers without
+	// a limit never wait.
+	Acquire(ctx context.Context, provider string) error
+
+	// AcquireN takes n slots at once for a batch. The per-second bucket may
+	// go into debt so batches larger than the burst still go through, with
+	// later sends waiting for it to refill. ErrQuotaExhausted is returned
+	// when fewer than n sends are left today.
+	AcquireN(ctx context.Context, provider string, n int) error
+
+	// Release gives back n slots a provider did not use, e.g. a batch it
+	// rejected
+	Release(ctx context.Context, provider string, n int) error
+
+	// Remaining reports the quota left for every limited provider
+	Remaining(ctx context.Context) ([]Quota, error)
+}
+
+// NewLimiter returns the limiter backend selected by name
+func NewLimiter(name string, redis *redis.Client, limits map[string]Limit) (Limiter, error) {
+	switch strings.ToLower(name) {
+	case "", "redis":
+		return NewRedisLimiter(redis, limits), nil
+	case "memory":
+		return NewMemoryLimiter(limits), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter: %s", name)
+	}
+}
+
+// ParseLimits builds provider limits from "<provider>=<count>" items for the
+// per-second rates and the daily quotas
+func ParseLimits(perSecond, daily []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+
+	parse := func(items []string, set func(*Limit, int)) error {
+		for _, item := range items {
+			provider, value, ok := strings.Cut(item, "=")
+			provider = strings.ToLower(strings.TrimSpace(provider))
+			count, err := strconv.Atoi(strings.TrimSpace(value))
+			if !ok || provider == "" || err != nil || count <= 0 {
+				return fmt.Errorf("invalid provider limit %q: want <provider>=<positive count>", item)
+			}
+			limit := limits[provider]
+			set(&limit, count)
+			limits[provider] = limit
+		}
+		return nil
+	}
+
+	if err := parse(perSecond, func(l *Limit, n int) { l.PerSecond = n }); err != nil {
+		return nil, err
+	}
+	if err := parse(daily, func(l *Limit, n int) { l.Daily = n }); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// acquire calls reserve until it grants a slot, sleeping for the wait it
+// reports in between
+func acquire(ctx context.Context, reserve func() (time.Duration, error)) error {
+	for {
+		wait, err := reserve()
+		if err != nil || wait <= 0 {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// nextDay returns the start of the UTC day after t
+func nextDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+func sortedProviders(limits map[string]Limit) []string {
+	providers := make([]string, 0, len(limits))
+	for provider := range limits {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
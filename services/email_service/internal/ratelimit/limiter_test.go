@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testBatches checks AcquireN and Release against a limiter configured with
+// sendgrid at 10 per second and 100 per day. advance moves its clock.
+func testBatches(t *testing.T, limiter Limiter, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+
+	// A batch larger than the burst goes through, leaving the bucket in debt
+	if err := limiter.AcquireN(ctx, "sendgrid", 30); err != nil {
+		t.Fatalf("AcquireN(30) = %v", err)
+	}
+	expired, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(expired, "sendgrid"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire in debt = %v, want deadline exceeded", err)
+	}
+
+	// A batch the daily quota cannot cover takes nothing
+	advance(3 * time.Second)
+	if err := limiter.AcquireN(ctx, "sendgrid", 71); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("AcquireN(71) with 70 left = %v, want ErrQuotaExhausted", err)
+	}
+	if got := dailyRemaining(t, limiter); got != 70 {
+		t.Fatalf("DailyRemaining = %d, want 70", got)
+	}
+
+	// Released slots can be used again
+	if err := limiter.Release(ctx, "sendgrid", 30); err != nil {
+		t.Fatal(err)
+	}
+	if got := dailyRemaining(t, limiter); got != 100 {
+		t.Errorf("DailyRemaining after release = %d, want 100", got)
+	}
+	if err := limiter.AcquireN(ctx, "sendgrid", 10); err != nil {
+		t.Errorf("AcquireN after release = %v", err)
+	}
+
+	// Unlimited providers are never charged
+	if err := limiter.AcquireN(ctx, "smtp", 1000); err != nil {
+		t.Errorf("unlimited provider: %v", err)
+	}
+	if err := limiter.Release(ctx, "smtp", 1000); err != nil {
+		t.Errorf("unlimited provider: %v", err)
+	}
+}
+
+func dailyRemaining(t *testing.T, limiter Limiter) int {
+	t.Helper()
+	quotas, err := limiter.Remaining(context.Background())
+	if err != nil || len(quotas) != 1 {
+		t.Fatalf("Remaining = %+v, %v", quotas, err)
+	}
+	return quotas[0].DailyRemaining
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter is an in-process Limiter for development and tests. Limits
+// only hold within one replica.
+type MemoryLimiter struct {
+	limits map[string]Limit
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	day       time.Time // start of the UTC day dailyUsed counts
+	dailyUsed int
+}
+
+func NewMemoryLimiter(limits map[string]Limit) *MemoryLimiter {
+	return &MemoryLimiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[string]*memoryBucket),
+	}
+}
+
+func (l *MemoryLimiter) Acquire(ctx context.Context, provider string) error {
+	return l.AcquireN(ctx, provider, 1)
+}
+
+func (l *MemoryLimiter) AcquireN(ctx context.Context, provider string, n int) error {
+	provider = strings.ToLower(provider)
+	limit, ok := l.limits[provider]
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	return acquire(ctx, func() (time.Duration, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		bucket := l.bucket(provider, limit)
+		if limit.Daily > 0 && bucket.dailyUsed+n > limit.Daily {
+			return 0, ErrQuotaExhausted
+		}
+		if limit.PerSecond > 0 {
+			// A batch waits for a full burst at most, then runs the bucket
+			// into debt
+			need := math.Min(float64(n), float64(limit.PerSecond))
+			if bucket.tokens < need {
+				wait := (need - bucket.tokens) / float64(limit.PerSecond) * float64(time.Second)
+				return time.Duration(math.Ceil(wait)), nil
+			}
+			bucket.tokens -= float64(n)
+		}
+		bucket.dailyUsed += n
+		return 0, nil
+	})
+}
+
+func (l *MemoryLimiter) Release(ctx context.Context, provider string, n int) error {
+	provider = strings.ToLower(provider)
+	limit, ok := l.limits[provider]
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := l.bucket(provider, limit)
+	bucket.tokens = math.Min(float64(limit.PerSecond), bucket.tokens+float64(n))
+	bucket.dailyUsed = max(bucket.dailyUsed-n, 0)
+	return nil
+}
+
+func (l *MemoryLimiter) Remaining(ctx context.Context) ([]Quota, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	quotas := make([]Quota, 0, len(l.limits))
+	for _, provider := range sortedProviders(l.limits) {
+		limit := l.limits[provider]
+		bucket := l.bucket(provider, limit)
+
+		quota := Quota{Provider: provider, PerSecond: limit.PerSecond, Daily: limit.Daily}
+		if limit.PerSecond > 0 {
+			quota.TokensAvailable = bucket.tokens
+		}
+		if limit.Daily > 0 {
+			quota.DailyRemaining = max(limit.Daily-bucket.dailyUsed, 0)
+			quota.DailyResetsAt = bucket.day.Add(24 * time.Hour)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+// bucket returns the provider's state refilled up to now. Callers hold l.mu.
+func (l *MemoryLimiter) bucket(provider string, limit Limit) *memoryBucket {
+	now := l.now()
+	bucket, ok := l.buckets[provider]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.PerSecond), updatedAt: now}
+		l.buckets[provider] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(float64(limit.PerSecond), bucket.tokens+elapsed*float64(limit.PerSecond))
+		bucket.updatedAt = now
+	}
+	if day := nextDay(now).Add(-24 * time.Hour); !day.Equal(bucket.day) {
+		bucket.day = day
+		bucket.dailyUsed = 0
+	}
+	return bucket
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(limits map[string]Limit) (*MemoryLimiter, *time.Time) {
+	now := time.Date(2025, 1, 20, 23, 59, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter(limits)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestMemoryLimiterTokenBucket(t *testing.T) {
+	limiter, now := newTestLimiter(map[string]Limit{"sendgrid": {PerSecond: 2}})
+	ctx := context.Background()
+
+	// The burst is one second's worth of tokens
+	for i := 0; i < 2; i++ {
+		if err := limiter.Acquire(ctx, "sendgrid"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An empty bucket makes Acquire wait for the clock to refill it
+	expired, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(expired, "sendgrid"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire on empty bucket = %v, want deadline exceeded", err)
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if err := limiter.Acquire(ctx, "SendGrid"); err != nil {
+		t.Fatalf("Acquire after refill = %v", err)
+	}
+
+	// Providers without a limit never wait
+	if err := limiter.Acquire(expired, "smtp"); err != nil {
+		t.Errorf("unlimited provider: %v", err)
+	}
+}
+
+func TestMemoryLimiterDailyQuota(t *testing.T) {
+	limiter, now := newTestLimiter(map[string]Limit{"sendgrid": {Daily: 3}})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := limiter.Acquire(ctx, "sendgrid"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := limiter.Acquire(ctx, "sendgrid"); !errors.Is(err, ErrQuotaExhausted) {
+		t.Fatalf("Acquire over quota = %v, want ErrQuotaExhausted", err)
+	}
+
+	quotas, _ := limiter.Remaining(ctx)
+	if len(quotas) != 1 || quotas[0].DailyRemaining != 0 || !quotas[0].DailyResetsAt.Equal(time.Date(2025, 1, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Remaining = %+v", quotas)
+	}
+
+	// The quota resets with the UTC day
+	*now = now.Add(2 * time.Minute)
+	if err := limiter.Acquire(ctx, "sendgrid"); err != nil {
+		t.Fatalf("Acquire on a new day = %v", err)
+	}
+	if quotas, _ := limiter.Remaining(ctx); quotas[0].DailyRemaining != 2 {
+		t.Errorf("DailyRemaining = %d, want 2", quotas[0].DailyRemaining)
+	}
+}
+
+func TestMemoryLimiterBatches(t *testing.T) {
+	limiter, now := newTestLimiter(map[string]Limit{"sendgrid": {PerSecond: 10, Daily: 100}})
+	testBatches(t, limiter, func(d time.Duration) { *now = now.Add(d) })
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits([]string{"SendGrid=100", "mailgun=10"}, []string{"sendgrid=100000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits["sendgrid"] != (Limit{PerSecond: 100, Daily: 100000}) || limits["mailgun"] != (Limit{PerSecond: 10}) {
+		t.Errorf("limits = %+v", limits)
+	}
+
+	for _, bad := range []string{"sendgrid", "sendgrid=0", "=5", "sendgrid=ten"} {
+		if _, err := ParseLimits([]string{bad}, nil); err == nil {
+			t.Errorf("ParseLimits(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The scripts read the clock from Redis, so replicas with skewed clocks
+// still share one bucket. KEYS[1] is the token bucket hash and KEYS[2] the
+// prefix of the per-day counters; ARGV holds the per-second rate and the
+// daily quota. A saved bucket expires once it has refilled, which after a
+// batch that ran it into debt can take well over a minute.
+const loadBucket = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local dayKey = KEYS[2] .. ':' .. math.floor(now / 86400000)
+local used = tonumber(redis.call('GET', dayKey) or '0')
+local tokens = rate
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+if bucket[1] then
+	tokens = math.min(rate, tonumber(bucket[1]) + (now - tonumber(bucket[2])) * rate / 1000)
+end
+local function saveBucket(left)
+	redis.call('HSET', KEYS[1], 'tokens', tostring(left), 'ts', now)
+	redis.call('PEXPIRE', KEYS[1], math.ceil((rate - left) * 1000 / rate) + 1000)
+end
+`
+
+// acquireScript takes ARGV[3] slots, returning 0 when they were taken, -1
+// when the daily quota cannot cover them, or the milliseconds to wait. A
+// batch waits for a full burst at most, then runs the bucket into debt.
+var acquireScript = redis.NewScript(loadBucket + `
+local daily = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+if daily > 0 and used + n > daily then
+	return -1
+end
+if rate > 0 then
+	local need = math.min(n, rate)
+	if tokens < need then
+		return math.ceil((need - tokens) * 1000 / rate)
+	end
+	saveBucket(tokens - n)
+end
+if daily > 0 then
+	if redis.call('INCRBY', dayKey, n) == n then
+		redis.call('PEXPIRE', dayKey, 2 * 86400000)
+	end
+end
+return 0
+`)
+
+// releaseScript gives back ARGV[3] slots
+var releaseScript = redis.NewScript(loadBucket + `
+local n = tonumber(ARGV[3])
+if rate > 0 then
+	saveBucket(math.min(rate, tokens + n))
+end
+if tonumber(ARGV[2]) > 0 and used > 0 then
+	redis.call('DECRBY', dayKey, math.min(n, used))
+end
+return 0
+`)
+
+// peekScript returns the available tokens, the sends used today and now (ms)
+var peekScript = redis.NewScript(loadBucket + `
+return {tostring(tokens), used, now}
+`)
+
+// RedisLimiter shares provider quotas between every replica through Redis
+type RedisLimiter struct {
+	redis  *redis.Client
+	limits map[string]Limit
+}
+
+func NewRedisLimiter(redis *redis.Client, limits map[string]Limit) *RedisLimiter {
+	return &RedisLimiter{
+		redis:  redis,
+		limits: limits,
+	}
+}
+
+func (l *RedisLimiter) Acquire(ctx context.Context, provider string) error {
+	return l.AcquireN(ctx, provider, 1)
+}
+
+func (l *RedisLimiter) AcquireN(ctx context.Context, provider string, n int) error {
+	provider = strings.ToLower(provider)
+	limit, ok := l.limits[provider]
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	return acquire(ctx, func() (time.Duration, error) {
+		wait, err := acquireScript.Run(ctx, l.redis, keys(provider), limit.PerSecond, limit.Daily, n).Int64()
+		if err != nil {
+			return 0, fmt.Errorf("failed to acquire provider quota: %w", err)
+		}
+		if wait < 0 {
+			return 0, ErrQuotaExhausted
+		}
+		return time.Duration(wait) * time.Millisecond, nil
+	})
+}
+
+func (l *RedisLimiter) Release(ctx context.Context, provider string, n int) error {
+	provider = strings.ToLower(provider)
+	limit, ok := l.limits[provider]
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	if err := releaseScript.Run(ctx, l.redis, keys(provider), limit.PerSecond, limit.Daily, n).Err(); err != nil {
+		return fmt.Errorf("failed to release provider quota: %w", err)
+	}
+	return nil
+}
+
+func (l *RedisLimiter) Remaining(ctx context.Context) ([]Quota, error) {
+	quotas := make([]Quota, 0, len(l.limits))
+	for _, provider := range sortedProviders(l.limits) {
+		limit := l.limits[provider]
+
+		values, err := peekScript.Run(ctx, l.redis, keys(provider), limit.PerSecond, limit.Daily).Slice()
+		if err != nil || len(values) != 3 {
+			return nil, fmt.Errorf("failed to read provider quota: %v", err)
+		}
+		tokens, _ := strconv.ParseFloat(fmt.Sprint(values[0]), 64)
+		used, _ := values[1].(int64)
+		now, _ := values[2].(int64)
+
+		quota := Quota{Provider: provider, PerSecond: limit.PerSecond, Daily: limit.Daily}
+		if limit.PerSecond > 0 {
+			quota.TokensAvailable = tokens
+		}
+		if limit.Daily > 0 {
+			quota.DailyRemaining = max(limit.Daily-int(used), 0)
+			quota.DailyResetsAt = nextDay(time.UnixMilli(now))
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, nil
+}
+
+func keys(provider string) []string {
+	return []string{"email:quota:" + provider + ":bucket", "email:quota:" + provider + ":day"}
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisLimiterBatches(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		map[string]Limit{"sendgrid": {PerSecond: 10, Daily: 100}})
+	testBatches(t, limiter, func(d time.Duration) {
+		now = now.Add(d)
+		mr.SetTime(now)
+	})
+}
+
+func TestRedisLimiterKeepsDebtUntilRepaid(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	mr.SetTime(now)
+	ctx := context.Background()
+
+	limiter := NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		map[string]Limit{"sendgrid": {PerSecond: 10}})
+
+	// 1000 recipients at 10/s leave 990 tokens of debt, 100s to pay off
+	// and one more to refill
+	if err := limiter.AcquireN(ctx, "sendgrid", 1000); err != nil {
+		t.Fatal(err)
+	}
+	bucket := "email:quota:sendgrid:bucket"
+	if ttl := mr.TTL(bucket); ttl < 100*time.Second || ttl > 102*time.Second {
+		t.Errorf("bucket TTL = %v, want about 101s", ttl)
+	}
+
+	// A minute later the debt still holds back the next send
+	now = now.Add(time.Minute)
+	mr.SetTime(now)
+	mr.FastForward(time.Minute)
+	if !mr.Exists(bucket) {
+		t.Fatal("bucket expired while in debt")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(waitCtx, "sendgrid"); err == nil {
+		t.Error("Acquire succeeded while the bucket was in debt")
+	}
+
+	// A bucket with tokens left expires once refilled
+	mr.FlushAll()
+	if err := limiter.Acquire(ctx, "sendgrid"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(bucket); ttl > 2*time.Second {
+		t.Errorf("bucket TTL = %v, want at most 1.1s", ttl)
+	}
+}
@@ -1,6 +1,8 @@
 package retry
 
 import (
+	"context"
+	"errors"
 	"math"
 	"time"
 
@@ -26,12 +28,34 @@ func (h *Handler) ShouldRetry(err error, attempt int) bool {
 		return false
 	}
 
-	// Check if error is retryable
+	return h.IsRetryable(err)
+}
+
+// PermanentError is implemented by typed errors that know whether they are
+// worth retrying, such as validation.InvalidAddressError,
+// sender.ProviderError and sender.SMTPReplyError
+type PermanentError interface {
+	error
+	Permanent() bool
+}
+
+// IsRetryable reports whether an error is temporary, regardless of attempts.
+// Typed errors decide for themselves; others are matched by message.
+func (h *Handler) IsRetryable(err error) bool {
+	var permanent PermanentError
+	if errors.As(err, &permanent) {
+		return !permanent.Permanent()
+	}
+
 	errMsg := err.Error()
 
 	// Don't retry on these permanent errors
 	permanentErrors := []string{
 		"invalid email",
+		"address is suppressed",
+		"invalid attachment",
+		"attachment too large",
+		"too many attachments",
 		"template not found",
 		"authentication failed",
 		"unauthorized",
@@ -59,15 +83,24 @@ func (h *Handler) CalculateBackoff(attempt int) time.Duration {
 	return time.Duration(backoff) * time.Second
 }
 
-// Wait waits for the backoff duration
-func (h *Handler) Wait(attempt int, correlationID string) {
+// Wait waits for the backoff duration, returning early with the context's
+// error if ctx is cancelled first
+func (h *Handler) Wait(ctx context.Context, attempt int, correlationID string) error {
 	backoff := h.CalculateBackoff(attempt)
 	logger.Log.Info("retry backoff",
 		zap.Int("attempt", attempt),
 		zap.Duration("backoff", backoff),
 		zap.String("correlation_id", correlationID),
 	)
-	time.Sleep(backoff)
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func contains(str, substr string) bool {
@@ -0,0 +1,46 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/sender"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/validation"
+)
+
+func TestIsRetryable(t *testing.T) {
+	h := NewHandler(5, 1)
+	providerErr := func(status int) error {
+		return fmt.Errorf("failed to send email: %w", &sender.ProviderError{Provider: "sendgrid", StatusCode: status, Body: "{}"})
+	}
+
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", providerErr(400), false},
+		{"unauthorized", providerErr(401), false},
+		{"forbidden", providerErr(403), false},
+		{"payload too large", providerErr(413), false},
+		{"request timeout", providerErr(408), true},
+		{"rate limited", providerErr(429), true},
+		{"server error", providerErr(500), true},
+		{"unavailable", providerErr(503), true},
+		{"joined by failover", errors.Join(errors.New("smtp: quota exhausted"), providerErr(400)), false},
+		{"invalid address", &validation.InvalidAddressError{Address: "a@b", Reason: validation.ReasonSyntax}, false},
+		{"untyped permanent", errors.New("template not found: welcome"), false},
+		{"network", errors.New("dial tcp: connection refused"), true},
+		{"deadline", context.DeadlineExceeded, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := h.IsRetryable(tc.err); got != tc.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
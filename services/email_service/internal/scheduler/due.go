@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+)
+
+// Tolerance is how early a message may be processed; anything due within it
+// is sent rather than parked, which absorbs clock skew between replicas
+const Tolerance = time.Second
+
+// DueAt returns when a message may be sent: its send_at, pushed to the end
+// of the recipient's quiet hours when it falls inside them. Messages without
+// either are due now.
+func DueAt(msg *models.EmailMessage, now time.Time) (time.Time, error) {
+	due := now
+	if msg.SendAt != nil && msg.SendAt.After(now) {
+		due = *msg.SendAt
+	}
+	if msg.QuietHours == nil {
+		return due, nil
+	}
+
+	loc := time.UTC
+	if msg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(msg.Timezone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone %q: %w", msg.Timezone, err)
+		}
+	}
+	start, err := parseClock(msg.QuietHours.Start)
+	if err != nil {
+		return time.Time{}, err
+	}
+	end, err := parseClock(msg.QuietHours.End)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if start == end {
+		return time.Time{}, fmt.Errorf("quiet hours start and end must differ")
+	}
+
+	local := due.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	quiet := start <= minute && minute < end
+	if start > end { // overnight, e.g. 22:00-07:00
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return due, nil
+	}
+
+	// The next time the clock reads the end of the window
+	resume := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !resume.After(local) {
+		resume = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+	return resume, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quiet hours time %q: want HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
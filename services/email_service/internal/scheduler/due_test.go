@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+)
+
+func TestDueAt(t *testing.T) {
+	// 21:30 UTC is 22:30 in Lagos (UTC+1)
+	now := time.Date(2025, 1, 20, 21, 30, 0, 0, time.UTC)
+	later := now.Add(2 * time.Hour)
+	earlier := now.Add(-time.Hour)
+	overnight := &models.QuietHours{Start: "22:00", End: "07:00"}
+
+	cases := []struct {
+		name string
+		msg  models.EmailMessage
+		want time.Time
+	}{
+		{"immediate", models.EmailMessage{}, now},
+		{"send_at in the future", models.EmailMessage{SendAt: &later}, later},
+		{"send_at in the past", models.EmailMessage{SendAt: &earlier}, now},
+		{
+			"inside overnight quiet hours",
+			models.EmailMessage{Timezone: "Africa/Lagos", QuietHours: overnight},
+			time.Date(2025, 1, 21, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			"outside quiet hours in UTC",
+			models.EmailMessage{QuietHours: overnight},
+			now,
+		},
+		{
+			"send_at lands in quiet hours",
+			models.EmailMessage{SendAt: &later, Timezone: "America/New_York", QuietHours: &models.QuietHours{Start: "18:00", End: "20:00"}},
+			time.Date(2025, 1, 21, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			"same-day window after midnight",
+			models.EmailMessage{SendAt: &later, QuietHours: &models.QuietHours{Start: "23:00", End: "23:45"}},
+			time.Date(2025, 1, 20, 23, 45, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DueAt(&tc.msg, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("DueAt = %s, want %s", got.UTC(), tc.want)
+			}
+		})
+	}
+}
+
+func TestDueAtRejectsInvalidSchedules(t *testing.T) {
+	now := time.Now()
+	for _, msg := range []models.EmailMessage{
+		{Timezone: "Mars/Olympus", QuietHours: &models.QuietHours{Start: "22:00", End: "07:00"}},
+		{QuietHours: &models.QuietHours{Start: "10pm", End: "07:00"}},
+		{QuietHours: &models.QuietHours{Start: "07:00", End: "07:00"}},
+	} {
+		if _, err := DueAt(&msg, now); err == nil {
+			t.Errorf("DueAt(%+v) succeeded", msg)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ErrNotFound is returned when cancelling a message that is not scheduled,
+// either because it was never parked or because it was already released
+var ErrNotFound = errors.New("scheduled message not found")
+
+const (
+	dueKey      = "email:scheduled"          // sorted set of notification IDs by due time (ms)
+	messagesKey = "email:scheduled:messages" // hash of notification ID to queue message
+
+	// releaseLease is how long a claimed message stays hidden from other
+	// replicas; if its publish never completes it is released again
+	releaseLease = 30 * time.Second
+	releaseBatch = 100
+)
+
+// claimScript hides due messages behind a lease and returns them as
+// alternating IDs and bodies
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+local out = {}
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[2], id)
+	table.insert(out, id)
+	table.insert(out, redis.call('HGET', KEYS[2], id) or '')
+end
+return out
+`)
+
+// completeScript removes a released message unless it was scheduled again
+// (and so given a new score) in the meantime
+var completeScript = redis.NewScript(`
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) == ARGV[2] then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	redis.call('HDEL', KEYS[2], ARGV[1])
+end
+return 0
+`)
+
+// cancelScript removes a message and returns its body, or nil when absent
+var cancelScript = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return false
+end
+local body = redis.call('HGET', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+return body
+`)
+
+// Scheduled is a parked message
+type Scheduled struct {
+	NotificationID string    `json:"notification_id"`
+	SendAt         time.Time `json:"send_at"`
+}
+
+// Scheduler parks messages that are not due yet in a Redis sorted set and
+// releases them back to the work queue on time. Every replica runs the
+// release loop; the claim lease keeps them from releasing a message twice.
+type Scheduler struct {
+	redis *redis.Client
+}
+
+func NewScheduler(redis *redis.Client) *Scheduler {
+	return &Scheduler{redis: redis}
+}
+
+// Schedule parks a queue message until at, replacing any earlier schedule
+// for the same notification
+func (s *Scheduler) Schedule(ctx context.Context, notificationID string, body []byte, at time.Time) error {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, messagesKey, notificationID, body)
+		pipe.ZAdd(ctx, dueKey, redis.Z{Score: float64(at.UnixMilli()), Member: notificationID})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule message: %w", err)
+	}
+	return nil
+}
+
+// Cancel removes a scheduled message that has not been released yet and
+// returns its queue message
+func (s *Scheduler) Cancel(ctx context.Context, notificationID string) ([]byte, error) {
+	body, err := cancelScript.Run(ctx, s.redis, []string{dueKey, messagesKey}, notificationID).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled message: %w", err)
+	}
+	return []byte(body), nil
+}
+
+// List returns up to limit scheduled messages, soonest first
+func (s *Scheduler) List(ctx context.Context, limit int64) ([]Scheduled, error) {
+	entries, err := s.redis.ZRangeWithScores(ctx, dueKey, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled messages: %w", err)
+	}
+
+	scheduled := make([]Scheduled, 0, len(entries))
+	for _, entry := range entries {
+		scheduled = append(scheduled, Scheduled{
+			NotificationID: fmt.Sprint(entry.Member),
+			SendAt:         time.UnixMilli(int64(entry.Score)).UTC(),
+		})
+	}
+	return scheduled, nil
+}
+
+// Run releases due messages every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration, release func(ctx context.Context, body []byte) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.releaseDue(ctx, release); err != nil && ctx.Err() == nil {
+				logger.Log.Error("failed to release scheduled messages", zap.Error(err))
+			}
+		}
+	}
+}
+
+// releaseDue claims due messages and hands each to release, removing it
+// from the schedule once release succeeds
+func (s *Scheduler) releaseDue(ctx context.Context, release func(ctx context.Context, body []byte) error) error {
+	now := time.Now()
+	lease := now.Add(releaseLease).UnixMilli()
+	claimed, err := claimScript.Run(ctx, s.redis, []string{dueKey, messagesKey},
+		now.UnixMilli(), lease, releaseBatch,
+	).StringSlice()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(claimed); i += 2 {
+		id, body := claimed[i], claimed[i+1]
+		if body != "" {
+			if err := release(ctx, []byte(body)); err != nil {
+				logger.Log.Error("failed to release scheduled message", zap.Error(err), zap.String("notification_id", id))
+				continue
+			}
+		}
+
+		err := completeScript.Run(ctx, s.redis, []string{dueKey, messagesKey}, id, strconv.FormatInt(lease, 10)).Err()
+		if err != nil {
+			return err
+		}
+		logger.Log.Info("scheduled message released", zap.String("notification_id", id))
+	}
+	return nil
+}
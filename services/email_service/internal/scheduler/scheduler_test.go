@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *miniredis.Miniredis) {
+	t.Helper()
+	logger.Log = zap.NewNop()
+
+	mr := miniredis.RunT(t)
+	return NewScheduler(redis.NewClient(&redis.Options{Addr: mr.Addr()})), mr
+}
+
+func mustSchedule(t *testing.T, s *Scheduler, id string, body []byte, at time.Time) {
+	t.Helper()
+	if err := s.Schedule(context.Background(), id, body, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// releaser records released bodies and fails while err is set
+type releaser struct {
+	released []string
+	err      error
+}
+
+func (r *releaser) release(ctx context.Context, body []byte) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.released = append(r.released, string(body))
+	return nil
+}
+
+func TestScheduleAndList(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	if err := s.Schedule(ctx, "later", []byte(`{"n":"later"}`), now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Schedule(ctx, "sooner", []byte(`{"n":"sooner"}`), now.Add(3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Scheduling again replaces the earlier due time
+	if err := s.Schedule(ctx, "sooner", []byte(`{"n":"sooner"}`), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := s.List(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 2 || scheduled[0].NotificationID != "sooner" || scheduled[1].NotificationID != "later" {
+		t.Fatalf("List() = %+v, want sooner then later", scheduled)
+	}
+	if !scheduled[0].SendAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("SendAt = %s, want %s", scheduled[0].SendAt, now.Add(time.Hour))
+	}
+	if limited, _ := s.List(ctx, 1); len(limited) != 1 {
+		t.Errorf("List(1) returned %d entries", len(limited))
+	}
+}
+
+func TestReleaseDue(t *testing.T) {
+	s, mr := newTestScheduler(t)
+	ctx := context.Background()
+
+	mustSchedule(t, s, "due", []byte("due-body"), time.Now().Add(-time.Second))
+	mustSchedule(t, s, "future", []byte("future-body"), time.Now().Add(time.Hour))
+
+	r := &releaser{}
+	if err := s.releaseDue(ctx, r.release); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.released) != 1 || r.released[0] != "due-body" {
+		t.Fatalf("released %q, want [due-body]", r.released)
+	}
+
+	// The released message is gone, the future one untouched
+	scheduled, _ := s.List(ctx, 10)
+	if len(scheduled) != 1 || scheduled[0].NotificationID != "future" {
+		t.Errorf("List() after release = %+v", scheduled)
+	}
+	if mr.HGet(messagesKey, "due") != "" {
+		t.Error("released message body was not removed")
+	}
+}
+
+func TestReleaseFailureKeepsClaimUntilLeaseExpires(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	ctx := context.Background()
+	mustSchedule(t, s, "due", []byte("due-body"), time.Now().Add(-time.Second))
+
+	r := &releaser{err: errors.New("channel closed")}
+	if err := s.releaseDue(ctx, r.release); err != nil {
+		t.Fatal(err)
+	}
+
+	// The claim pushed the message out by the lease, hiding it from other
+	// replicas until it expires, and kept it for the next attempt
+	scheduled, _ := s.List(ctx, 10)
+	if len(scheduled) != 1 {
+		t.Fatalf("List() = %+v, want the unreleased message kept", scheduled)
+	}
+	if until := time.Until(scheduled[0].SendAt); until < releaseLease-5*time.Second || until > releaseLease {
+		t.Errorf("lease expires in %s, want about %s", until, releaseLease)
+	}
+
+	r.err = nil
+	if err := s.releaseDue(ctx, r.release); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.released) != 0 {
+		t.Errorf("claimed message released again before its lease expired")
+	}
+}
+
+func TestRescheduledDuringReleaseIsKept(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	ctx := context.Background()
+	mustSchedule(t, s, "due", []byte("v1"), time.Now().Add(-time.Second))
+
+	// The message is scheduled again while its old version is being released
+	next := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	err := s.releaseDue(ctx, func(ctx context.Context, body []byte) error {
+		return s.Schedule(ctx, "due", []byte("v2"), next)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, _ := s.List(ctx, 10)
+	if len(scheduled) != 1 || !scheduled[0].SendAt.Equal(next) {
+		t.Errorf("List() = %+v, want the new schedule kept", scheduled)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	ctx := context.Background()
+	mustSchedule(t, s, "n-1", []byte(`{"user_id":"u-1"}`), time.Now().Add(time.Hour))
+
+	body, err := s.Cancel(ctx, "n-1")
+	if err != nil || string(body) != `{"user_id":"u-1"}` {
+		t.Fatalf("Cancel() = %q, %v", body, err)
+	}
+	if scheduled, _ := s.List(ctx, 10); len(scheduled) != 0 {
+		t.Errorf("List() after cancel = %+v", scheduled)
+	}
+
+	// Already cancelled, released or never scheduled
+	for _, id := range []string{"n-1", "unknown"} {
+		if _, err := s.Cancel(ctx, id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Cancel(%q) = %v, want ErrNotFound", id, err)
+		}
+	}
+}
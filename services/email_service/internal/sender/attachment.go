@@ -0,0 +1,126 @@
+package sender
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+)
+
+// Attachment is a decoded file attached to an outgoing email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+	Inline      bool
+	ContentID   string
+}
+
+// AttachmentPolicy limits what queue messages may attach
+type AttachmentPolicy struct {
+	MaxSize      int64  // bytes per attachment
+	MaxTotalSize int64  // bytes across all attachments of a message
+	MaxCount     int    // attachments per message
+	BaseDir      string // directory local file references are resolved against; empty disables them
+}
+
+// LoadAttachments decodes the attachments of a queue message and enforces the
+// size and count limits. Oversized or malformed attachments are permanent
+// failures.
+func LoadAttachments(specs []models.Attachment, policy AttachmentPolicy) ([]Attachment, error) {
+	if policy.MaxCount > 0 && len(specs) > policy.MaxCount {
+		return nil, fmt.Errorf("too many attachments: %d (limit %d)", len(specs), policy.MaxCount)
+	}
+
+	attachments := make([]Attachment, 0, len(specs))
+	var total int64
+
+	for i, spec := range specs {
+		if spec.Filename == "" {
+			return nil, fmt.Errorf("invalid attachment %d: filename is required", i)
+		}
+		if spec.Inline && spec.ContentID == "" {
+			return nil, fmt.Errorf("invalid attachment %s: content_id is required for inline attachments", spec.Filename)
+		}
+
+		data, err := readAttachment(spec, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		size := int64(len(data))
+		if policy.MaxSize > 0 && size > policy.MaxSize {
+			return nil, fmt.Errorf("attachment too large: %s is %d bytes (limit %d)", spec.Filename, size, policy.MaxSize)
+		}
+		total += size
+		if policy.MaxTotalSize > 0 && total > policy.MaxTotalSize {
+			return nil, fmt.Errorf("attachment too large: total size exceeds %d bytes", policy.MaxTotalSize)
+		}
+
+		contentType := spec.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(spec.Filename))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		attachments = append(attachments, Attachment{
+			Filename:    spec.Filename,
+			ContentType: contentType,
+			Data:        data,
+			Inline:      spec.Inline,
+			ContentID:   strings.Trim(spec.ContentID, "<>"),
+		})
+	}
+
+	return attachments, nil
+}
+
+func readAttachment(spec models.Attachment, policy AttachmentPolicy) ([]byte, error) {
+	switch {
+	case spec.Content != "" && spec.Path != "":
+		return nil, fmt.Errorf("invalid attachment %s: content and path are mutually exclusive", spec.Filename)
+
+	case spec.Content != "":
+		// Reject before decoding so a huge payload is never held twice in memory
+		if policy.MaxSize > 0 && int64(base64.StdEncoding.DecodedLen(len(spec.Content))) > policy.MaxSize+2 {
+			return nil, fmt.Errorf("attachment too large: %s exceeds %d bytes", spec.Filename, policy.MaxSize)
+		}
+		data, err := base64.StdEncoding.DecodeString(spec.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attachment %s: %w", spec.Filename, err)
+		}
+		return data, nil
+
+	case spec.Path != "":
+		if policy.BaseDir == "" {
+			return nil, fmt.Errorf("invalid attachment %s: local file references are disabled", spec.Filename)
+		}
+		if filepath.IsAbs(spec.Path) || !filepath.IsLocal(spec.Path) {
+			return nil, fmt.Errorf("invalid attachment %s: path must be relative to the attachment directory", spec.Filename)
+		}
+
+		path := filepath.Join(policy.BaseDir, spec.Path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attachment %s: %w", spec.Filename, err)
+		}
+		if policy.MaxSize > 0 && info.Size() > policy.MaxSize {
+			return nil, fmt.Errorf("attachment too large: %s is %d bytes (limit %d)", spec.Filename, info.Size(), policy.MaxSize)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment %s: %w", spec.Filename, err)
+		}
+		return data, nil
+
+	default:
+		return nil, fmt.Errorf("invalid attachment %s: content or path is required", spec.Filename)
+	}
+}
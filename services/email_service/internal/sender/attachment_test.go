@@ -0,0 +1,167 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+)
+
+func encoded(data string) string {
+	return base64.StdEncoding.EncodeToString([]byte(data))
+}
+
+func TestLoadAttachments(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "invoice.pdf"), []byte("%PDF-1.7"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy := AttachmentPolicy{MaxSize: 16, MaxTotalSize: 24, MaxCount: 3, BaseDir: dir}
+
+	attachments, err := LoadAttachments([]models.Attachment{
+		{Filename: "notes.txt", Content: encoded("hello")},
+		{Filename: "invoice.pdf", Path: "invoice.pdf"},
+		{Filename: "logo.png", Content: encoded("png"), Inline: true, ContentID: "<logo>"},
+	}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Attachment{
+		{Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", Data: []byte("hello")},
+		{Filename: "invoice.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.7")},
+		{Filename: "logo.png", ContentType: "image/png", Data: []byte("png"), Inline: true, ContentID: "logo"},
+	}
+	for i, got := range attachments {
+		if got.Filename != want[i].Filename || got.ContentType != want[i].ContentType || !bytes.Equal(got.Data, want[i].Data) ||
+			got.Inline != want[i].Inline || got.ContentID != want[i].ContentID {
+			t.Errorf("attachment %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestLoadAttachmentsRejects(t *testing.T) {
+	policy := AttachmentPolicy{MaxSize: 16, MaxTotalSize: 24, MaxCount: 3, BaseDir: t.TempDir()}
+	ten := encoded(strings.Repeat("x", 10))
+
+	cases := []struct {
+		name  string
+		specs []models.Attachment
+		want  string
+	}{
+		{"file over the size limit", []models.Attachment{{Filename: "big.bin", Content: encoded(strings.Repeat("x", 17))}}, "attachment too large"},
+		{"total over the limit", []models.Attachment{{Filename: "a", Content: ten}, {Filename: "b", Content: ten}, {Filename: "c", Content: ten}}, "attachment too large"},
+		{"too many", []models.Attachment{{Filename: "a", Content: "YQ=="}, {Filename: "b", Content: "YQ=="}, {Filename: "c", Content: "YQ=="}, {Filename: "d", Content: "YQ=="}}, "too many attachments"},
+		{"bad base64", []models.Attachment{{Filename: "a.txt", Content: "not base64!"}}, "invalid attachment"},
+		{"missing filename", []models.Attachment{{Content: "YQ=="}}, "invalid attachment"},
+		{"inline without content id", []models.Attachment{{Filename: "logo.png", Content: "YQ==", Inline: true}}, "invalid attachment"},
+		{"content and path", []models.Attachment{{Filename: "a", Content: "YQ==", Path: "a"}}, "invalid attachment"},
+		{"path outside the directory", []models.Attachment{{Filename: "passwd", Path: "../../etc/passwd"}}, "invalid attachment"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadAttachments(tc.specs, policy)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("LoadAttachments() = %v, want %q", err, tc.want)
+			}
+		})
+	}
+
+	// Local files are only read when a base directory is configured
+	_, err := LoadAttachments([]models.Attachment{{Filename: "a", Path: "a"}}, AttachmentPolicy{})
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("path without BaseDir = %v", err)
+	}
+}
+
+func TestNewAttachmentPartEncodesBase64(t *testing.T) {
+	data := bytes.Repeat([]byte{0x00, 0xff, 0x10}, 100)
+	part := newAttachmentPart(Attachment{Filename: "report.bin", ContentType: "application/octet-stream", Data: data})
+
+	if got := part.header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("Content-Transfer-Encoding = %s", got)
+	}
+	if got := part.header.Get("Content-Disposition"); got != `attachment; filename=report.bin` {
+		t.Errorf("Content-Disposition = %s", got)
+	}
+	if part.header.Get("Content-ID") != "" {
+		t.Error("regular attachment has a Content-ID")
+	}
+
+	for _, line := range strings.Split(string(part.body), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 line longer than 76: %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(part.body), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Errorf("decoded attachment differs (%v)", err)
+	}
+}
+
+func TestBuildBodyWithAttachments(t *testing.T) {
+	email := NewEmail("ada@example.com", "Receipt", `<p><img src="cid:logo"> Thanks</p>`, "")
+	email.Attachments = []Attachment{
+		{Filename: "logo.png", ContentType: "image/png", Data: []byte("png"), Inline: true, ContentID: "logo"},
+		{Filename: "receipt.pdf", ContentType: "application/pdf", Data: []byte("%PDF")},
+	}
+
+	body, err := buildBody(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// multipart/mixed [ multipart/related [ alternative, logo ], receipt ]
+	subtype, mixed := splitMultipart(t, body)
+	if subtype != "mixed" || len(mixed) != 2 {
+		t.Fatalf("top level = multipart/%s with %d parts", subtype, len(mixed))
+	}
+	if got := mixed[1].header.Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") {
+		t.Errorf("receipt Content-Disposition = %s", got)
+	}
+
+	subtype, related := splitMultipart(t, mixed[0])
+	if subtype != "related" || len(related) != 2 {
+		t.Fatalf("first part = multipart/%s with %d parts", subtype, len(related))
+	}
+	if subtype, _ := splitMultipart(t, related[0]); subtype != "alternative" {
+		t.Errorf("related root = multipart/%s, want alternative", subtype)
+	}
+	logo := related[1]
+	if got := logo.header.Get("Content-ID"); got != "<logo>" {
+		t.Errorf("Content-ID = %q, want <logo>", got)
+	}
+	if got := logo.header.Get("Content-Disposition"); !strings.HasPrefix(got, "inline") {
+		t.Errorf("logo Content-Disposition = %s", got)
+	}
+	if got := mediaType(logo); got != "image/png" {
+		t.Errorf("logo Content-Type = %s", got)
+	}
+}
+
+func TestBuildBodyInlineWithoutHTML(t *testing.T) {
+	email := NewEmail("ada@example.com", "Receipt", "", "Thanks")
+	email.Attachments = []Attachment{{Filename: "logo.png", ContentType: "image/png", Data: []byte("png"), Inline: true, ContentID: "logo"}}
+
+	body, err := buildBody(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A text-only body cannot reference cid: images, so they become attachments
+	subtype, parts := splitMultipart(t, body)
+	if subtype != "mixed" || len(parts) != 2 {
+		t.Fatalf("got multipart/%s with %d parts, want mixed with 2", subtype, len(parts))
+	}
+	if mediaType(parts[0]) != "text/plain" {
+		t.Errorf("first part = %s", mediaType(parts[0]))
+	}
+	if got := parts[1].header.Get("Content-Disposition"); !strings.HasPrefix(got, "attachment") || parts[1].header.Get("Content-ID") != "" {
+		t.Errorf("inline image without HTML = %s", got)
+	}
+}
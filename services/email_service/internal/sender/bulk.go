@@ -0,0 +1,48 @@
+package sender
+
+import (
+	"context"
+	"errors"
+)
+
+// MaxBulkRecipients is the most recipients one bulk send may carry, the
+// SendGrid personalizations limit
+const MaxBulkRecipients = 1000
+
+// ErrBulkUnsupported is returned when the active provider cannot send bulk
+// mail; callers fall back to one send per recipient
+var ErrBulkUnsupported = errors.New("active email provider does not support bulk sends")
+
+// BulkSender sends one message to many recipients in a single provider call
+type BulkSender interface {
+	SendBulk(ctx context.Context, email *BulkEmail) (*SendResult, error)
+}
+
+// SupportsBulk reports whether s can send bulk mail right now. A sender that
+// picks between providers, like FailoverSender, answers for the provider it
+// would use.
+func SupportsBulk(s EmailSender) bool {
+	if _, ok := s.(BulkSender); !ok {
+		return false
+	}
+	if picker, ok := s.(interface{ SupportsBulk() bool }); ok {
+		return picker.SupportsBulk()
+	}
+	return true
+}
+
+// BulkEmail is a message shared by many recipients. The provider replaces
+// each recipient's substitution tags in the subject, body and headers. To,
+// Cc and Bcc of the embedded Email are ignored.
+type BulkEmail struct {
+	Email
+	Recipients []BulkRecipient
+}
+
+// BulkRecipient is one recipient of a BulkEmail
+type BulkRecipient struct {
+	To             string
+	NotificationID string            // echoed back in provider events (SendGrid custom_args)
+	Substitutions  map[string]string // tag, e.g. "{{user_name}}", to value
+	Headers        map[string]string // per-recipient headers such as List-Unsubscribe
+}
@@ -0,0 +1,303 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// ErrCapturedMessageNotFound is returned when a captured message does not exist
+var ErrCapturedMessageNotFound = errors.New("captured message not found")
+
+const envelopeHeader = "X-Envelope-To"
+
+// CapturedMessage is an email stored by the CaptureSender
+type CapturedMessage struct {
+	ID         string    `json:"id"`
+	From       string    `json:"from"`
+	To         []string  `json:"to"` // envelope recipients, including Bcc
+	Subject    string    `json:"subject"`
+	CapturedAt time.Time `json:"captured_at"`
+	Size       int       `json:"size"`
+	TextBody   string    `json:"text_body,omitempty"`
+	HTMLBody   string    `json:"html_body,omitempty"`
+	Raw        []byte    `json:"-"`
+}
+
+// CaptureSender is a development sender that keeps sent messages instead of
+// delivering them, either in memory or as .eml files in a directory
+type CaptureSender struct {
+	dir         string
+	maxMessages int
+
+	mu       sync.RWMutex
+	messages []*CapturedMessage // memory mode, oldest first
+}
+
+func NewCaptureSender(cfg config.CaptureConfig) (*CaptureSender, error) {
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create capture directory: %w", err)
+		}
+	}
+
+	return &CaptureSender{
+		dir:         cfg.Dir,
+		maxMessages: cfg.MaxMessages,
+	}, nil
+}
+
+func (s *CaptureSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	from := email.From
+	if from.Address == "" {
+		from = Identity{Address: "noreply@localhost", Name: "Notification System"}
+	}
+
+	raw, messageID, err := buildMessage(from.String(), email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email message: %w", err)
+	}
+
+	msg := &CapturedMessage{
+		ID:         fmt.Sprintf("%d-%s", time.Now().UnixNano(), randomHex(4)),
+		From:       from.String(),
+		To:         email.Recipients(),
+		Subject:    email.Subject,
+		CapturedAt: time.Now().UTC(),
+		Size:       len(raw),
+		TextBody:   email.TextBody,
+		HTMLBody:   email.HTMLBody,
+		Raw:        raw,
+	}
+
+	if s.dir != "" {
+		// Record the envelope so Bcc recipients are visible in the .eml file
+		envelope := fmt.Sprintf("%s: %s\r\n", envelopeHeader, strings.Join(msg.To, ", "))
+		data := append([]byte(envelope), raw...)
+		if err := os.WriteFile(s.path(msg.ID), data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write captured message: %w", err)
+		}
+		return s.result(msg.ID, messageID), nil
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	if s.maxMessages > 0 && len(s.messages) > s.maxMessages {
+		s.messages = s.messages[len(s.messages)-s.maxMessages:]
+	}
+	s.mu.Unlock()
+
+	return s.result(msg.ID, messageID), nil
+}
+
+func (s *CaptureSender) result(id, messageID string) *SendResult {
+	return &SendResult{
+		Provider:          s.GetProviderName(),
+		ProviderMessageID: id,
+		MessageID:         messageID,
+	}
+}
+
+func (s *CaptureSender) GetProviderName() string {
+	return "capture"
+}
+
+// List returns captured messages, newest first, without their bodies
+func (s *CaptureSender) List() ([]CapturedMessage, error) {
+	var messages []CapturedMessage
+
+	if s.dir != "" {
+		files, err := filepath.Glob(filepath.Join(s.dir, "*.eml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			msg, err := s.read(strings.TrimSuffix(filepath.Base(file), ".eml"))
+			if err != nil {
+				continue
+			}
+			messages = append(messages, *msg)
+		}
+	} else {
+		s.mu.RLock()
+		for _, msg := range s.messages {
+			messages = append(messages, *msg)
+		}
+		s.mu.RUnlock()
+	}
+
+	// IDs start with the capture time in nanoseconds, so they sort chronologically
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID > messages[j].ID
+	})
+	for i := range messages {
+		messages[i].TextBody = ""
+		messages[i].HTMLBody = ""
+	}
+
+	return messages, nil
+}
+
+// Get returns a single captured message including its bodies and raw source
+func (s *CaptureSender) Get(id string) (*CapturedMessage, error) {
+	if s.dir != "" {
+		return s.read(id)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			copied := *msg
+			return &copied, nil
+		}
+	}
+	return nil, ErrCapturedMessageNotFound
+}
+
+// Clear deletes every captured message
+func (s *CaptureSender) Clear() error {
+	if s.dir != "" {
+		files, err := filepath.Glob(filepath.Join(s.dir, "*.eml"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *CaptureSender) path(id string) string {
+	return filepath.Join(s.dir, id+".eml")
+}
+
+// read loads and parses a captured .eml file
+func (s *CaptureSender) read(id string) (*CapturedMessage, error) {
+	if id == "" || id != filepath.Base(id) {
+		return nil, ErrCapturedMessageNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrCapturedMessageNotFound
+		}
+		return nil, err
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse captured message: %w", err)
+	}
+
+	msg := &CapturedMessage{
+		ID:      id,
+		From:    decodeHeader(parsed.Header.Get("From")),
+		Subject: decodeHeader(parsed.Header.Get("Subject")),
+		Size:    len(data),
+		Raw:     data,
+	}
+	for _, rcpt := range strings.Split(parsed.Header.Get(envelopeHeader), ",") {
+		if rcpt = strings.TrimSpace(rcpt); rcpt != "" {
+			msg.To = append(msg.To, rcpt)
+		}
+	}
+	if info, err := os.Stat(s.path(id)); err == nil {
+		msg.CapturedAt = info.ModTime().UTC()
+	}
+
+	msg.TextBody, msg.HTMLBody = extractBodies(parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), parsed.Body)
+
+	return msg, nil
+}
+
+// extractBodies walks a MIME entity and returns its first text/plain and
+// text/html parts, decoding their transfer encoding
+func extractBodies(contentType, encoding string, body io.Reader) (text, html string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				break
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			t, h := extractBodies(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if text == "" {
+				text = t
+			}
+			if html == "" {
+				html = h
+			}
+		}
+		return text, html
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", ""
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	content, _ := io.ReadAll(body)
+
+	if mediaType == "text/html" {
+		return "", string(content)
+	}
+	return string(content), ""
+}
+
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
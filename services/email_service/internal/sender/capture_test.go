@@ -0,0 +1,140 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+func captureEmail(subject string) *Email {
+	email := NewEmail("Ada <ada@example.com>", subject, "<p>Hello <b>Ada</b></p>", "")
+	email.From = Identity{Address: "team@example.com", Name: "Team"}
+	email.Bcc = []string{"audit@example.com"}
+	return email
+}
+
+func TestCaptureSenderRecordsRenderedMessage(t *testing.T) {
+	for _, mode := range []string{"memory", "dir"} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := config.CaptureConfig{}
+			if mode == "dir" {
+				cfg.Dir = t.TempDir()
+			}
+			s, err := NewCaptureSender(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subject := "Bienvenue à l'équipe"
+			result, err := s.Send(context.Background(), captureEmail(subject))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Provider != "capture" || result.ProviderMessageID == "" || result.MessageID == "" {
+				t.Fatalf("result = %+v", result)
+			}
+			if mode == "dir" {
+				if _, err := os.Stat(filepath.Join(cfg.Dir, result.ProviderMessageID+".eml")); err != nil {
+					t.Fatalf("no .eml file: %v", err)
+				}
+			}
+
+			msg, err := s.Get(result.ProviderMessageID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.From != `"Team" <team@example.com>` || msg.Subject != subject {
+				t.Errorf("From = %q, Subject = %q", msg.From, msg.Subject)
+			}
+			if want := []string{"Ada <ada@example.com>", "audit@example.com"}; !reflect.DeepEqual(msg.To, want) {
+				t.Errorf("To = %q, want the envelope %q", msg.To, want)
+			}
+			if msg.TextBody != "Hello Ada" || msg.HTMLBody != "<p>Hello <b>Ada</b></p>" {
+				t.Errorf("bodies = %q, %q", msg.TextBody, msg.HTMLBody)
+			}
+
+			// The raw source is the message as it would have been sent
+			parsed, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header.Get("Message-ID") != result.MessageID || decodeHeader(parsed.Header.Get("Subject")) != subject {
+				t.Errorf("raw headers = %v", parsed.Header)
+			}
+			if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "multipart/alternative") {
+				t.Errorf("Content-Type = %s", parsed.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestCaptureSenderListAndClear(t *testing.T) {
+	for _, mode := range []string{"memory", "dir"} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := config.CaptureConfig{MaxMessages: 2}
+			if mode == "dir" {
+				cfg.Dir = t.TempDir()
+			}
+			s, err := NewCaptureSender(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, subject := range []string{"first", "second", "third"} {
+				if _, err := s.Send(context.Background(), captureEmail(subject)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			messages, err := s.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var subjects []string
+			for _, msg := range messages {
+				subjects = append(subjects, msg.Subject)
+				if msg.TextBody != "" || msg.HTMLBody != "" {
+					t.Errorf("List() returned the bodies of %s", msg.Subject)
+				}
+			}
+			// Memory mode keeps only the newest MaxMessages
+			want := []string{"third", "second", "first"}
+			if mode == "memory" {
+				want = want[:2]
+			}
+			if !reflect.DeepEqual(subjects, want) {
+				t.Errorf("List() = %v, want newest first %v", subjects, want)
+			}
+
+			if err := s.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			if messages, _ := s.List(); len(messages) != 0 {
+				t.Errorf("List() after Clear = %d messages", len(messages))
+			}
+			if _, err := s.Get(messages[0].ID); !errors.Is(err, ErrCapturedMessageNotFound) {
+				t.Errorf("Get() after Clear = %v", err)
+			}
+		})
+	}
+}
+
+func TestCaptureSenderRejectsPathIDs(t *testing.T) {
+	s, err := NewCaptureSender(config.CaptureConfig{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"", "../secret", "a/b"} {
+		if _, err := s.Get(id); !errors.Is(err, ErrCapturedMessageNotFound) {
+			t.Errorf("Get(%q) = %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package sender
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// defaultDKIMHeaders are signed when no header list is configured. Headers
+// missing from a message are skipped.
+var defaultDKIMHeaders = []string{
+	"From", "To", "Cc", "Reply-To", "Subject", "Date", "Message-ID",
+	"MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+var wspRunRe = regexp.MustCompile(`[ \t]+`)
+
+// DKIMSigner adds a DKIM-Signature (RFC 6376) to fully built messages using
+// relaxed/relaxed canonicalization
+type DKIMSigner struct {
+	domain    string
+	selector  string
+	headers   []string
+	key       crypto.Signer
+	algorithm string
+	now       func() time.Time
+}
+
+// NewDKIMSigner loads the private key and returns a signer, or nil when DKIM
+// is not configured. RSA keys sign with rsa-sha256 and Ed25519 keys with
+// ed25519-sha256 (RFC 8463).
+func NewDKIMSigner(cfg config.DKIMConfig) (*DKIMSigner, error) {
+	if cfg.Domain == "" && cfg.Selector == "" && cfg.PrivateKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.Domain == "" || cfg.Selector == "" || cfg.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("DKIM domain, selector and private key file are all required")
+	}
+
+	keyPEM, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM private key: %w", err)
+	}
+	key, err := parseDKIMKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := cfg.Headers
+	if len(headers) == 0 {
+		headers = defaultDKIMHeaders
+	}
+
+	return newDKIMSigner(cfg.Domain, cfg.Selector, headers, key)
+}
+
+func newDKIMSigner(domain, selector string, headers []string, key crypto.Signer) (*DKIMSigner, error) {
+	var algorithm string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+	}
+
+	return &DKIMSigner{
+		domain:    domain,
+		selector:  selector,
+		headers:   headers,
+		key:       key,
+		algorithm: algorithm,
+		now:       time.Now,
+	}, nil
+}
+
+func parseDKIMKey(keyPEM []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("DKIM private key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DKIM private key: %w", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported DKIM key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("unsupported DKIM PEM block %q", block.Type)
+	}
+}
+
+// Sign returns the message with a DKIM-Signature header prepended. Line
+// endings are normalized to CRLF first so the signed bytes match what is
+// transmitted over SMTP.
+func (d *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	message = normalizeCRLF(message)
+
+	headerEnd := bytes.Index(message, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		return nil, fmt.Errorf("failed to sign message: no header/body separator")
+	}
+	headerBlock := message[:headerEnd+2]
+	body := message[headerEnd+4:]
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+
+	fields := splitHeaderFields(headerBlock)
+	var signedNames []string
+	var signedData bytes.Buffer
+	used := make(map[int]bool)
+	for _, name := range d.headers {
+		// Sign the bottom-most instance of each header not signed yet
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fieldName(fields[i]), name) {
+				continue
+			}
+			used[i] = true
+			signedNames = append(signedNames, strings.ToLower(name))
+			signedData.WriteString(canonicalizeHeaderRelaxed(fields[i]))
+			break
+		}
+	}
+
+	tags := []string{
+		"v=1",
+		"a=" + d.algorithm,
+		"c=relaxed/relaxed",
+		"d=" + d.domain,
+		"s=" + d.selector,
+		"t=" + strconv.FormatInt(d.now().Unix(), 10),
+		"h=" + strings.Join(signedNames, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+	}
+	signatureField := "DKIM-Signature: " + strings.Join(tags, "; ") + ";\r\n\tb="
+
+	// The signature header itself is signed with an empty b= and no trailing CRLF
+	signedData.WriteString(strings.TrimSuffix(canonicalizeHeaderRelaxed(signatureField), "\r\n"))
+	dataHash := sha256.Sum256(signedData.Bytes())
+
+	var signature []byte
+	var err error
+	switch key := d.key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, dataHash[:])
+	default:
+		signature, err = d.key.Sign(rand.Reader, dataHash[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	signed := make([]byte, 0, len(signatureField)+len(message)+512)
+	signed = append(signed, signatureField...)
+	signed = append(signed, base64.StdEncoding.EncodeToString(signature)...)
+	signed = append(signed, "\r\n"...)
+	signed = append(signed, message...)
+
+	return signed, nil
+}
+
+// splitHeaderFields splits a CRLF header block into fields, keeping folded
+// continuation lines with their field
+func splitHeaderFields(headerBlock []byte) []string {
+	var fields []string
+	for _, line := range strings.SplitAfter(string(headerBlock), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+func fieldName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.TrimSpace(name)
+}
+
+// canonicalizeHeaderRelaxed implements the relaxed header canonicalization
+// of RFC 6376 section 3.4.2
+func canonicalizeHeaderRelaxed(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	name = strings.ToLower(strings.TrimRight(name, " \t"))
+
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = wspRunRe.ReplaceAllString(value, " ")
+	value = strings.Trim(value, " ")
+
+	return name + ":" + value + "\r\n"
+}
+
+// canonicalizeBodyRelaxed implements the relaxed body canonicalization of
+// RFC 6376 section 3.4.4
+func canonicalizeBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(wspRunRe.ReplaceAllString(line, " "), " ")
+	}
+
+	// Ignore all empty lines at the end of the body
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// normalizeCRLF converts bare LF and CR line endings to CRLF
+func normalizeCRLF(message []byte) []byte {
+	message = bytes.ReplaceAll(message, []byte("\r\n"), []byte("\n"))
+	message = bytes.ReplaceAll(message, []byte("\r"), []byte("\n"))
+	return bytes.ReplaceAll(message, []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,246 @@
+package sender
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// Ed25519 key pair from RFC 8463 appendix A
+const (
+	rfc8463PrivateSeed = "nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A="
+	rfc8463PublicKey   = "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+)
+
+const dkimTestMessage = "From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game.  Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe.\r\n"
+
+func TestCanonicalizeHeaderRelaxed(t *testing.T) {
+	// RFC 6376 section 3.4.5
+	cases := map[string]string{
+		"A: X\r\n":                 "a:X\r\n",
+		"B : Y\t\r\n\tZ  \r\n":     "b:Y Z\r\n",
+		"Subject:  Hello   World ": "subject:Hello World\r\n",
+	}
+
+	for in, want := range cases {
+		if got := canonicalizeHeaderRelaxed(in); got != want {
+			t.Errorf("canonicalizeHeaderRelaxed(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCanonicalizeBodyRelaxed(t *testing.T) {
+	// RFC 6376 section 3.4.5
+	got := string(canonicalizeBodyRelaxed([]byte(" C \r\nD \t E\r\n\r\n\r\n")))
+	if want := " C\r\nD E\r\n"; got != want {
+		t.Errorf("canonicalizeBodyRelaxed = %q, want %q", got, want)
+	}
+
+	if got := canonicalizeBodyRelaxed([]byte("\r\n\r\n")); len(got) != 0 {
+		t.Errorf("empty body canonicalized to %q, want empty", got)
+	}
+}
+
+func TestDKIMSignEd25519(t *testing.T) {
+	seed, _ := base64.StdEncoding.DecodeString(rfc8463PrivateSeed)
+	pub, _ := base64.StdEncoding.DecodeString(rfc8463PublicKey)
+
+	signer, err := newDKIMSigner("football.example.com", "brisbane", defaultDKIMHeaders, ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := signer.Sign([]byte(dkimTestMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := verifyDKIM(t, signed, ed25519.PublicKey(pub))
+	if tags["a"] != "ed25519-sha256" {
+		t.Errorf("a = %q, want ed25519-sha256", tags["a"])
+	}
+	if tags["h"] != "from:to:subject:date:message-id" {
+		t.Errorf("h = %q", tags["h"])
+	}
+}
+
+func TestDKIMSignRSAFromKeyFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewDKIMSigner(config.DKIMConfig{
+		Domain:         "example.com",
+		Selector:       "mail",
+		PrivateKeyFile: keyFile,
+		Headers:        []string{"From", "Subject"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	// Bare LF line endings are signed as they will be transmitted: CRLF
+	message := strings.ReplaceAll(dkimTestMessage, "\r\n", "\n")
+	signed, err := signer.Sign([]byte(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := verifyDKIM(t, signed, &key.PublicKey)
+	if tags["a"] != "rsa-sha256" || tags["d"] != "example.com" || tags["s"] != "mail" || tags["t"] != "1700000000" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if tags["h"] != "from:subject" {
+		t.Errorf("h = %q, want from:subject", tags["h"])
+	}
+}
+
+func TestDKIMSignatureDetectsTampering(t *testing.T) {
+	seed, _ := base64.StdEncoding.DecodeString(rfc8463PrivateSeed)
+	pub, _ := base64.StdEncoding.DecodeString(rfc8463PublicKey)
+
+	signer, err := newDKIMSigner("football.example.com", "brisbane", defaultDKIMHeaders, ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign([]byte(dkimTestMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(signed, []byte("Is dinner ready?"), []byte("Is lunch ready?"), 1)
+	if err := checkDKIM(tampered, ed25519.PublicKey(pub)); err == "" {
+		t.Error("expected tampered subject to fail verification")
+	}
+
+	tampered = bytes.Replace(signed, []byte("We lost the game."), []byte("We won the game."), 1)
+	if err := checkDKIM(tampered, ed25519.PublicKey(pub)); err == "" {
+		t.Error("expected tampered body to fail verification")
+	}
+}
+
+func TestNewDKIMSignerDisabled(t *testing.T) {
+	signer, err := NewDKIMSigner(config.DKIMConfig{})
+	if err != nil || signer != nil {
+		t.Fatalf("NewDKIMSigner(empty) = %v, %v; want nil, nil", signer, err)
+	}
+
+	if _, err := NewDKIMSigner(config.DKIMConfig{Domain: "example.com"}); err == nil {
+		t.Error("expected error for partial DKIM config")
+	}
+}
+
+func verifyDKIM(t *testing.T, signed []byte, pub crypto.PublicKey) map[string]string {
+	t.Helper()
+
+	if err := checkDKIM(signed, pub); err != "" {
+		t.Fatalf("DKIM verification failed: %s", err)
+	}
+	return parseDKIMTags(signatureField(signed))
+}
+
+// checkDKIM verifies the first DKIM-Signature of a message, returning a
+// description of the failure or "" when the signature is valid
+func checkDKIM(signed []byte, pub crypto.PublicKey) string {
+	field := signatureField(signed)
+	if field == "" {
+		return "no DKIM-Signature header"
+	}
+	tags := parseDKIMTags(field)
+
+	headerEnd := bytes.Index(signed, []byte("\r\n\r\n"))
+	fields := splitHeaderFields(signed[:headerEnd+2])
+	body := signed[headerEnd+4:]
+
+	bodyHash := sha256.Sum256(canonicalizeBodyRelaxed(body))
+	if base64.StdEncoding.EncodeToString(bodyHash[:]) != tags["bh"] {
+		return "body hash mismatch"
+	}
+
+	var data bytes.Buffer
+	used := map[int]bool{0: true}
+	for _, name := range strings.Split(tags["h"], ":") {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fieldName(fields[i]), name) {
+				used[i] = true
+				data.WriteString(canonicalizeHeaderRelaxed(fields[i]))
+				break
+			}
+		}
+	}
+	unsigned := regexp.MustCompile(`b=[^;]*$`).ReplaceAllString(strings.TrimRight(field, "\r\n"), "b=")
+	data.WriteString(strings.TrimSuffix(canonicalizeHeaderRelaxed(unsigned), "\r\n"))
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return "invalid signature encoding"
+	}
+	hash := sha256.Sum256(data.Bytes())
+
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, hash[:], signature) {
+			return "ed25519 signature mismatch"
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return "rsa signature mismatch"
+		}
+	}
+	return ""
+}
+
+func signatureField(signed []byte) string {
+	headerEnd := bytes.Index(signed, []byte("\r\n\r\n"))
+	for _, field := range splitHeaderFields(signed[:headerEnd+2]) {
+		if strings.EqualFold(fieldName(field), "DKIM-Signature") {
+			return field
+		}
+	}
+	return ""
+}
+
+func parseDKIMTags(field string) map[string]string {
+	_, value, _ := strings.Cut(field, ":")
+	value = regexp.MustCompile(`\s+`).ReplaceAllString(value, "")
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		if name, val, ok := strings.Cut(tag, "="); ok {
+			tags[name] = val
+		}
+	}
+	return tags
+}
@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// NewProviders creates the senders listed in EMAIL_PROVIDERS, in failover
+// order. Without a list, the single provider selected by EMAIL_PROVIDER is
+// used; when that is empty too, SendGrid is picked if an API key is present
+// and SMTP otherwise.
+func NewProviders(cfg config.EmailConfig) ([]EmailSender, error) {
+	names := cfg.Providers
+	if len(names) == 0 {
+		provider := cfg.Provider
+		if provider == "" {
+			provider = "smtp"
+			if cfg.SendGrid.APIKey != "" {
+				provider = "sendgrid"
+			}
+		}
+		names = []string{provider}
+	}
+
+	providers := make([]EmailSender, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		provider, err := newProvider(name, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s sender: %w", name, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+func newProvider(name string, cfg config.EmailConfig) (EmailSender, error) {
+	switch name {
+	case "smtp":
+		return NewSMTPSender(cfg.SMTP)
+	case "sendgrid":
+		return NewSendGridSender(cfg.SendGrid)
+	case "mailgun":
+		return NewMailgunSender(cfg.Mailgun)
+	case "capture":
+		return NewCaptureSender(cfg.Capture)
+	default:
+		return nil, fmt.Errorf("unknown email provider: %s", name)
+	}
+}
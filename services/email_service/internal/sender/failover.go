@@ -0,0 +1,298 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/circuit"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/ratelimit"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// FailoverSender tries an ordered list of providers, each behind its own
+// circuit breaker, moving on to the next provider when one fails with a
+// retryable error or has its breaker open
+type FailoverSender struct {
+	providers   []guardedSender
+	sendTimeout time.Duration
+	isRetryable func(error) bool
+	quota       QuotaLimiter
+}
+
+// QuotaLimiter hands out provider send slots, see ratelimit.Limiter
+type QuotaLimiter interface {
+	Acquire(ctx context.Context, provider string) error
+	AcquireN(ctx context.Context, provider string, n int) error
+	Release(ctx context.Context, provider string, n int) error
+}
+
+type guardedSender struct {
+	sender  EmailSender
+	breaker *gobreaker.CircuitBreaker
+}
+
+// NewFailoverSender wraps providers in per-provider circuit breakers.
+// sendTimeout bounds each provider attempt separately, so a hanging provider
+// still leaves time for the next one; zero disables it. isRetryable decides
+// whether an error should fail over to the next provider; any other error is
+// returned immediately.
+func NewFailoverSender(providers []EmailSender, breakerTimeout, sendTimeout time.Duration, isRetryable func(error) bool) (*FailoverSender, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("at least one email provider is required")
+	}
+
+	// Only provider trouble counts against a breaker: a message rejected on
+	// its own merits or a send cancelled by shutdown says nothing about the
+	// provider's health
+	isSuccessful := func(err error) bool {
+		return err == nil || errors.Is(err, context.Canceled) || !isRetryable(err)
+	}
+
+	guarded := make([]guardedSender, 0, len(providers))
+	for _, p := range providers {
+		guarded = append(guarded, guardedSender{
+			sender:  p,
+			breaker: circuit.NewBreaker("email-sender-"+p.GetProviderName(), 1, 0, breakerTimeout, isSuccessful),
+		})
+	}
+
+	return &FailoverSender{
+		providers:   guarded,
+		sendTimeout: sendTimeout,
+		isRetryable: isRetryable,
+	}, nil
+}
+
+// SetQuotaLimiter makes every provider attempt wait for a slot in that
+// provider's quota first
+func (f *FailoverSender) SetQuotaLimiter(quota QuotaLimiter) {
+	f.quota = quota
+}
+
+func (f *FailoverSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	var errs []error
+
+	for i, p := range f.providers {
+		// Cancellation by the caller ends the whole chain, not just one provider
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		name := p.sender.GetProviderName()
+
+		// A provider out of quota is skipped without counting against its breaker
+		if f.quota != nil {
+			if err := f.quota.Acquire(ctx, name); err != nil {
+				errs = append(errs, &AttemptError{Provider: name, Err: err})
+				if ctx.Err() != nil {
+					break
+				}
+				if i < len(f.providers)-1 {
+					logger.Log.Warn("email provider quota unavailable, failing over",
+						zap.String("provider", name),
+						zap.String("next_provider", f.providers[i+1].sender.GetProviderName()),
+						zap.Error(err),
+					)
+				}
+				continue
+			}
+		}
+
+		res, err := p.breaker.Execute(func() (interface{}, error) {
+			return f.sendWithTimeout(ctx, p.sender, email)
+		})
+		if err == nil {
+			if i > 0 {
+				logger.Log.Info("email delivered by fallback provider", zap.String("provider", name))
+			}
+			return res.(*SendResult), nil
+		}
+
+		errs = append(errs, &AttemptError{Provider: name, Err: err})
+
+		// An open breaker means the provider was never tried, so always move on
+		breakerRejected := errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+		if !breakerRejected && !f.isRetryable(err) {
+			break
+		}
+
+		if i < len(f.providers)-1 {
+			logger.Log.Warn("email provider failed, failing over",
+				zap.String("provider", name),
+				zap.String("next_provider", f.providers[i+1].sender.GetProviderName()),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// SendBulk hands a bulk email to the first provider whose breaker is closed.
+// Bulk sends do not fail over: when that provider cannot send bulk mail it
+// returns ErrBulkUnsupported, and the caller sends per recipient instead.
+func (f *FailoverSender) SendBulk(ctx context.Context, email *BulkEmail) (*SendResult, error) {
+	if p, ok := f.activeProvider(); ok {
+		bulk, ok := p.sender.(BulkSender)
+		if !ok {
+			return nil, ErrBulkUnsupported
+		}
+
+		// Providers count every recipient against their quota
+		name := p.sender.GetProviderName()
+		if f.quota != nil {
+			if err := f.quota.AcquireN(ctx, name, len(email.Recipients)); err != nil {
+				return nil, &AttemptError{Provider: name, Err: err}
+			}
+		}
+
+		res, err := p.breaker.Execute(func() (interface{}, error) {
+			ctx, cancel := f.attemptContext(ctx)
+			defer cancel()
+			return bulk.SendBulk(ctx, email)
+		})
+		if err != nil {
+			// The caller resends the batch per recipient, which takes quota
+			// again, so give back what the failed batch took
+			if f.quota != nil {
+				if releaseErr := f.quota.Release(context.WithoutCancel(ctx), name, len(email.Recipients)); releaseErr != nil {
+					logger.Log.Warn("failed to release provider quota", zap.String("provider", name), zap.Error(releaseErr))
+				}
+			}
+			return nil, &AttemptError{Provider: name, Err: err}
+		}
+		return res.(*SendResult), nil
+	}
+	return nil, ErrBulkUnsupported
+}
+
+// SupportsBulk reports whether SendBulk would reach a provider that can send
+// bulk mail, so callers can skip preparing a batch that would be refused
+func (f *FailoverSender) SupportsBulk() bool {
+	p, ok := f.activeProvider()
+	if !ok {
+		return false
+	}
+	_, ok = p.sender.(BulkSender)
+	return ok
+}
+
+// activeProvider returns the first provider whose breaker is not open
+func (f *FailoverSender) activeProvider() (guardedSender, bool) {
+	for _, p := range f.providers {
+		if p.breaker.State() != gobreaker.StateOpen {
+			return p, true
+		}
+	}
+	return guardedSender{}, false
+}
+
+// AttemptError is the error of one provider in the failover chain
+type AttemptError struct {
+	Provider string
+	Err      error
+}
+
+func (e *AttemptError) Error() string {
+	return e.Provider + ": " + e.Err.Error()
+}
+
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// FailedProvider returns the provider whose attempt ended a failed send, the
+// last one tried when several failed, or "" when err names no provider
+func FailedProvider(err error) string {
+	switch e := err.(type) {
+	case nil:
+		return ""
+	case *AttemptError:
+		return e.Provider
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for i := len(errs) - 1; i >= 0; i-- {
+			if provider := FailedProvider(errs[i]); provider != "" {
+				return provider
+			}
+		}
+		return ""
+	default:
+		return FailedProvider(errors.Unwrap(err))
+	}
+}
+
+// QuotaExhausted reports whether a send failed only for lack of daily quota,
+// i.e. every provider attempt in err ran into ratelimit.ErrQuotaExhausted. A
+// fallback provider that failed for another reason makes it false.
+func QuotaExhausted(err error) bool {
+	attempts := attemptErrors(err, nil)
+	if len(attempts) == 0 {
+		return errors.Is(err, ratelimit.ErrQuotaExhausted)
+	}
+	for _, attempt := range attempts {
+		if !errors.Is(attempt.Err, ratelimit.ErrQuotaExhausted) {
+			return false
+		}
+	}
+	return true
+}
+
+// attemptErrors collects the provider attempts in err, in order
+func attemptErrors(err error, found []*AttemptError) []*AttemptError {
+	switch e := err.(type) {
+	case nil:
+		return found
+	case *AttemptError:
+		return append(found, e)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			found = attemptErrors(err, found)
+		}
+		return found
+	default:
+		return attemptErrors(errors.Unwrap(err), found)
+	}
+}
+
+// sendWithTimeout runs a single provider attempt under the per-send timeout
+func (f *FailoverSender) sendWithTimeout(ctx context.Context, sender EmailSender, email *Email) (*SendResult, error) {
+	ctx, cancel := f.attemptContext(ctx)
+	defer cancel()
+	return sender.Send(ctx, email)
+}
+
+// attemptContext bounds one provider attempt by the per-send timeout
+func (f *FailoverSender) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.sendTimeout > 0 {
+		return context.WithTimeout(ctx, f.sendTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Close releases resources held by providers, such as pooled SMTP sessions
+func (f *FailoverSender) Close() error {
+	var errs []error
+	for _, p := range f.providers {
+		if closer, ok := p.sender.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetProviderName returns the configured providers in failover order
+func (f *FailoverSender) GetProviderName() string {
+	names := make([]string, 0, len(f.providers))
+	for _, p := range f.providers {
+		names = append(names, p.sender.GetProviderName())
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,307 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/ratelimit"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type stubSender struct {
+	name  string
+	err   error // returned by every send when set
+	sends int
+}
+
+func (s *stubSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	s.sends++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &SendResult{Provider: s.name}, nil
+}
+
+var (
+	errUnavailable = errors.New("service unavailable")
+	errRejected    = errors.New("recipient rejected")
+)
+
+// testRetryable treats errRejected as the only permanent error
+func testRetryable(err error) bool {
+	return !errors.Is(err, errRejected)
+}
+
+func newTestFailover(t *testing.T, providers ...EmailSender) *FailoverSender {
+	t.Helper()
+	logger.Log = zap.NewNop()
+
+	failover, err := NewFailoverSender(providers, time.Minute, 0, testRetryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return failover
+}
+
+func TestFailoverFallsBackOnRetryableError(t *testing.T) {
+	primary, secondary := &stubSender{name: "sendgrid", err: errUnavailable}, &stubSender{name: "smtp"}
+	failover := newTestFailover(t, primary, secondary)
+
+	result, err := failover.Send(context.Background(), testEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Provider != "smtp" || primary.sends != 1 || secondary.sends != 1 {
+		t.Errorf("sent by %s after %d/%d sends, want smtp after one try each", result.Provider, primary.sends, secondary.sends)
+	}
+}
+
+func TestFailoverStopsOnPermanentError(t *testing.T) {
+	primary, secondary := &stubSender{name: "sendgrid", err: errRejected}, &stubSender{name: "smtp"}
+	failover := newTestFailover(t, primary, secondary)
+
+	_, err := failover.Send(context.Background(), testEmail())
+	if !errors.Is(err, errRejected) || secondary.sends != 0 {
+		t.Fatalf("Send = %v after %d fallback sends, want the rejection without failover", err, secondary.sends)
+	}
+	if got := FailedProvider(err); got != "sendgrid" {
+		t.Errorf("FailedProvider = %q, want sendgrid", got)
+	}
+}
+
+func TestFailoverReportsLastFailedProvider(t *testing.T) {
+	failover := newTestFailover(t,
+		&stubSender{name: "sendgrid", err: errUnavailable},
+		&stubSender{name: "mailgun", err: errUnavailable},
+		&stubSender{name: "smtp", err: errRejected},
+	)
+
+	_, err := failover.Send(context.Background(), testEmail())
+	if got := FailedProvider(fmt.Errorf("failed to send email: %w", err)); got != "smtp" {
+		t.Errorf("FailedProvider = %q, want smtp", got)
+	}
+	if got := FailedProvider(errors.New("template not found")); got != "" {
+		t.Errorf("FailedProvider without a provider = %q", got)
+	}
+	if got := failover.GetProviderName(); got != "sendgrid,mailgun,smtp" {
+		t.Errorf("GetProviderName = %q", got)
+	}
+}
+
+func TestFailoverBreakerSkipsFailingProvider(t *testing.T) {
+	primary, secondary := &stubSender{name: "sendgrid", err: errUnavailable}, &stubSender{name: "smtp"}
+	failover := newTestFailover(t, primary, secondary)
+
+	// Three straight failures open the primary's breaker
+	for i := 0; i < 5; i++ {
+		if _, err := failover.Send(context.Background(), testEmail()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if primary.sends != 3 || secondary.sends != 5 {
+		t.Errorf("primary sends = %d, fallback sends = %d, want 3 and 5", primary.sends, secondary.sends)
+	}
+}
+
+func TestFailoverBreakerIgnoresRejectionsAndCancellation(t *testing.T) {
+	primary := &stubSender{name: "sendgrid", err: errRejected}
+	failover := newTestFailover(t, primary, &stubSender{name: "smtp"})
+
+	// Bad recipients and shutdown say nothing about the provider's health
+	for i := 0; i < 5; i++ {
+		failover.Send(context.Background(), testEmail())
+	}
+	primary.err = fmt.Errorf("failed to send email via SendGrid: %w", context.Canceled)
+	for i := 0; i < 5; i++ {
+		failover.Send(context.Background(), testEmail())
+	}
+	if primary.sends != 10 {
+		t.Fatalf("primary sends = %d, want 10", primary.sends)
+	}
+
+	primary.err = nil
+	result, err := failover.Send(context.Background(), testEmail())
+	if err != nil || result.Provider != "sendgrid" {
+		t.Errorf("Send = %+v, %v, want the primary with its breaker still closed", result, err)
+	}
+}
+
+func (s *stubSender) GetProviderName() string {
+	return s.name
+}
+
+type stubQuota map[string]error
+
+func (q stubQuota) Acquire(ctx context.Context, provider string) error {
+	return q[provider]
+}
+
+func (q stubQuota) AcquireN(ctx context.Context, provider string, n int) error {
+	return q[provider]
+}
+
+func (q stubQuota) Release(ctx context.Context, provider string, n int) error {
+	return nil
+}
+
+func TestFailoverSkipsProviderOutOfQuota(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	primary, secondary := &stubSender{name: "sendgrid"}, &stubSender{name: "smtp"}
+	failover, err := NewFailoverSender([]EmailSender{primary, secondary}, time.Minute, 0, func(error) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	errExhausted := errors.New("quota exhausted")
+	failover.SetQuotaLimiter(stubQuota{"sendgrid": errExhausted})
+
+	result, err := failover.Send(context.Background(), NewEmail("user@example.com", "Hi", "<p>Hi</p>", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Provider != "smtp" || primary.sends != 0 {
+		t.Errorf("sent by %s after %d primary sends, want smtp only", result.Provider, primary.sends)
+	}
+
+	// With every provider out of quota the error says so
+	failover.SetQuotaLimiter(stubQuota{"sendgrid": errExhausted, "smtp": errExhausted})
+	if _, err := failover.Send(context.Background(), NewEmail("user@example.com", "Hi", "<p>Hi</p>", "")); !errors.Is(err, errExhausted) {
+		t.Errorf("Send = %v, want quota error", err)
+	}
+}
+
+func TestQuotaExhausted(t *testing.T) {
+	logger.Log = zap.NewNop()
+	email := NewEmail("user@example.com", "Hi", "<p>Hi</p>", "")
+
+	primary, secondary := &stubSender{name: "sendgrid"}, &stubSender{name: "smtp"}
+	failover, err := NewFailoverSender([]EmailSender{primary, secondary}, time.Minute, 0, testRetryable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every provider out of quota
+	failover.SetQuotaLimiter(stubQuota{"sendgrid": ratelimit.ErrQuotaExhausted, "smtp": ratelimit.ErrQuotaExhausted})
+	_, err = failover.Send(context.Background(), email)
+	if !QuotaExhausted(fmt.Errorf("failed to send email: %w", err)) {
+		t.Errorf("QuotaExhausted(%v) = false with every provider out of quota", err)
+	}
+
+	// The fallback provider rejected the message for its own reasons
+	failover.SetQuotaLimiter(stubQuota{"sendgrid": ratelimit.ErrQuotaExhausted})
+	secondary.err = errRejected
+	_, err = failover.Send(context.Background(), email)
+	if !errors.Is(err, ratelimit.ErrQuotaExhausted) {
+		t.Fatalf("Send = %v, want the quota error joined in", err)
+	}
+	if QuotaExhausted(err) {
+		t.Errorf("QuotaExhausted(%v) = true with a rejection from the fallback", err)
+	}
+
+	// The fallback provider is down
+	secondary.err = errUnavailable
+	if _, err = failover.Send(context.Background(), email); QuotaExhausted(err) {
+		t.Errorf("QuotaExhausted(%v) = true with the fallback unavailable", err)
+	}
+
+	if !QuotaExhausted(ratelimit.ErrQuotaExhausted) || QuotaExhausted(errUnavailable) || QuotaExhausted(nil) {
+		t.Error("QuotaExhausted misreports errors without provider attempts")
+	}
+}
+
+type stubBulkSender struct {
+	stubSender
+	recipients int
+	bulkErr    error
+}
+
+func (s *stubBulkSender) SendBulk(ctx context.Context, email *BulkEmail) (*SendResult, error) {
+	if s.bulkErr != nil {
+		return nil, s.bulkErr
+	}
+	s.recipients += len(email.Recipients)
+	return &SendResult{Provider: s.name}, nil
+}
+
+func TestFailoverSendBulkUsesActiveProvider(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	bulk := &BulkEmail{Recipients: []BulkRecipient{{To: "a@example.com"}, {To: "b@example.com"}}}
+
+	primary := &stubBulkSender{stubSender: stubSender{name: "sendgrid"}}
+	failover, err := NewFailoverSender([]EmailSender{primary, &stubSender{name: "smtp"}}, time.Minute, 0, func(error) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := failover.SendBulk(context.Background(), bulk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Provider != "sendgrid" || primary.recipients != 2 {
+		t.Errorf("sent by %s to %d recipients", result.Provider, primary.recipients)
+	}
+	if !SupportsBulk(failover) {
+		t.Error("SupportsBulk = false with a bulk provider first")
+	}
+
+	// A provider without bulk support in front means per-recipient sends
+	failover, err = NewFailoverSender([]EmailSender{&stubSender{name: "smtp"}, primary}, time.Minute, 0, func(error) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := failover.SendBulk(context.Background(), bulk); !errors.Is(err, ErrBulkUnsupported) {
+		t.Errorf("SendBulk = %v, want ErrBulkUnsupported", err)
+	}
+	if SupportsBulk(failover) || SupportsBulk(&stubSender{name: "smtp"}) {
+		t.Error("SupportsBulk = true without bulk support")
+	}
+}
+
+func TestFailoverSendBulkQuota(t *testing.T) {
+	logger.Log = zap.NewNop()
+	ctx := context.Background()
+
+	primary := &stubBulkSender{stubSender: stubSender{name: "sendgrid"}}
+	failover, err := NewFailoverSender([]EmailSender{primary}, time.Minute, 0, func(error) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota := ratelimit.NewMemoryLimiter(map[string]ratelimit.Limit{"sendgrid": {Daily: 5}})
+	failover.SetQuotaLimiter(quota)
+
+	bulk := &BulkEmail{Recipients: []BulkRecipient{{To: "a@example.com"}, {To: "b@example.com"}, {To: "c@example.com"}}}
+	remaining := func() int {
+		quotas, _ := quota.Remaining(ctx)
+		return quotas[0].DailyRemaining
+	}
+
+	// The whole batch is charged at once
+	if _, err := failover.SendBulk(ctx, bulk); err != nil {
+		t.Fatal(err)
+	}
+	if got := remaining(); got != 2 {
+		t.Fatalf("DailyRemaining = %d, want 2", got)
+	}
+
+	// A batch larger than what is left takes nothing
+	if _, err := failover.SendBulk(ctx, bulk); !errors.Is(err, ratelimit.ErrQuotaExhausted) {
+		t.Fatalf("SendBulk over quota = %v, want ErrQuotaExhausted", err)
+	}
+	if got := remaining(); got != 2 {
+		t.Errorf("DailyRemaining after refusal = %d, want 2", got)
+	}
+
+	// A batch the provider rejects is refunded
+	primary.bulkErr = &ProviderError{Provider: "sendgrid", StatusCode: 500}
+	bulk.Recipients = bulk.Recipients[:2]
+	if _, err := failover.SendBulk(ctx, bulk); err == nil {
+		t.Fatal("expected the provider error")
+	}
+	if got := remaining(); got != 2 {
+		t.Errorf("DailyRemaining after failed batch = %d, want 2", got)
+	}
+}
@@ -0,0 +1,57 @@
+package sender
+
+import (
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// allowedHeaders lists the custom headers a queue message may set. Any X-
+// header is also allowed except the ones reserved for providers below.
+var allowedHeaders = map[string]bool{
+	"List-Id":        true,
+	"Precedence":     true,
+	"Auto-Submitted": true,
+	"In-Reply-To":    true,
+	"References":     true,
+	"Keywords":       true,
+}
+
+var reservedXHeaders = map[string]bool{
+	"X-Sg-Eid":            true,
+	"X-Sg-Id":             true,
+	"X-Smtpapi":           true,
+	"X-Mailgun-Variables": true,
+}
+
+// FilterHeaders returns the custom headers that are allowed on outgoing mail
+// in canonical form, along with the names of the rejected ones. Headers with
+// line breaks in their value are always rejected to prevent header injection.
+func FilterHeaders(headers map[string]string) (map[string]string, []string) {
+	allowed := make(map[string]string, len(headers))
+	var rejected []string
+
+	for name, value := range headers {
+		key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		if !isAllowedHeader(key) || strings.ContainsAny(key+value, "\r\n") {
+			rejected = append(rejected, name)
+			continue
+		}
+		allowed[key] = value
+	}
+
+	sort.Strings(rejected)
+	return allowed, rejected
+}
+
+func isAllowedHeader(key string) bool {
+	for _, r := range key {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	if allowedHeaders[key] {
+		return true
+	}
+	return strings.HasPrefix(key, "X-") && len(key) > 2 && !reservedXHeaders[key]
+}
@@ -0,0 +1,41 @@
+package sender
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterHeaders(t *testing.T) {
+	allowed, rejected := FilterHeaders(map[string]string{
+		"x-campaign":          "spring",
+		"List-Id":             "<news.example.com>",
+		" references ":        "<a@example.com>",
+		"Bcc":                 "spy@example.com",
+		"From":                "ceo@example.com",
+		"Content-Type":        "text/plain",
+		"X-Smtpapi":           "{}",
+		"X-Mailgun-Variables": "{}",
+		"X-":                  "empty",
+		"X Bad":               "space in name",
+		"X-Injected":          "ok\r\nBcc: spy@example.com",
+		"X-Newline":           "ok\nBcc: spy@example.com",
+		"X-Bad\r\nBcc":        "spy@example.com",
+	})
+
+	wantAllowed := map[string]string{
+		"X-Campaign": "spring",
+		"List-Id":    "<news.example.com>",
+		"References": "<a@example.com>",
+	}
+	if !reflect.DeepEqual(allowed, wantAllowed) {
+		t.Errorf("allowed = %v, want %v", allowed, wantAllowed)
+	}
+
+	wantRejected := []string{
+		"Bcc", "Content-Type", "From", "X Bad", "X-", "X-Bad\r\nBcc",
+		"X-Injected", "X-Mailgun-Variables", "X-Newline", "X-Smtpapi",
+	}
+	if !reflect.DeepEqual(rejected, wantRejected) {
+		t.Errorf("rejected = %q, want %q", rejected, wantRejected)
+	}
+}
@@ -0,0 +1,59 @@
+package sender
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	skipBlockRe  = regexp.MustCompile(`(?is)<(script|style|head|title)[^>]*>.*?</(script|style|head|title)>`)
+	commentRe    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	anchorRe     = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	lineBreakRe  = regexp.MustCompile(`(?i)<br\s*/?>`)
+	blockEndRe   = regexp.MustCompile(`(?i)</(p|div|h[1-6]|tr|table|ul|ol|blockquote|section|article|header|footer)>`)
+	listItemRe   = regexp.MustCompile(`(?i)<li[^>]*>`)
+	tagRe        = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRunRe   = regexp.MustCompile(`[ \t\f\v]+`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// HTMLToText derives a readable plain-text version of an HTML body.
+// Links keep their target in parentheses and block elements become line breaks.
+func HTMLToText(htmlBody string) string {
+	text := strings.ReplaceAll(htmlBody, "\r\n", "\n")
+	text = skipBlockRe.ReplaceAllString(text, "")
+	text = commentRe.ReplaceAllString(text, "")
+
+	// Block-level whitespace in the source is not significant once tags are gone
+	text = strings.ReplaceAll(text, "\n", " ")
+
+	text = anchorRe.ReplaceAllStringFunc(text, func(match string) string {
+		parts := anchorRe.FindStringSubmatch(match)
+		href := strings.TrimSpace(parts[1])
+		label := strings.TrimSpace(tagRe.ReplaceAllString(parts[2], ""))
+		if href == "" || strings.HasPrefix(href, "#") || href == label {
+			return label
+		}
+		if label == "" {
+			return href
+		}
+		return label + " (" + href + ")"
+	})
+
+	text = lineBreakRe.ReplaceAllString(text, "\n")
+	text = blockEndRe.ReplaceAllString(text, "\n\n")
+	text = listItemRe.ReplaceAllString(text, "\n- ")
+	text = tagRe.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRunRe.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,73 @@
+package sender
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	cases := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			"paragraphs and line breaks",
+			"<p>Hello Ada,</p>\n<p>Your code is<br>123456</p>",
+			"Hello Ada,\n\nYour code is\n123456",
+		},
+		{
+			"links keep their target",
+			`<p>Visit <a href="https://example.com/account">your account</a> today</p>`,
+			"Visit your account (https://example.com/account) today",
+		},
+		{
+			"link text that is the target",
+			`<a href="https://example.com">https://example.com</a>`,
+			"https://example.com",
+		},
+		{
+			"empty and fragment links",
+			`<a href="https://example.com/x"></a> <a href="#top">Back to top</a>`,
+			"https://example.com/x Back to top",
+		},
+		{
+			"lists",
+			"<ul><li>First</li><li>Second</li></ul>",
+			"- First\n- Second",
+		},
+		{
+			"entities",
+			"<p>Fish &amp; chips &lt;3&nbsp;&eacute;t&eacute; &#8364;5</p>",
+			"Fish & chips <3 été €5",
+		},
+		{
+			"script, style, head and comments are dropped",
+			"<html><head><title>Welcome</title><style>p { color: red; }</style></head>" +
+				"<body><!-- tracking --><script>alert('x')</script><p>Body</p></body></html>",
+			"Body",
+		},
+		{
+			"whitespace is collapsed",
+			"<div>  lots\n   of\t\tspace  </div>\n\n\n\n<div>next</div>",
+			"lots of space\n\nnext",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HTMLToText(tc.html); got != tc.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewEmailDerivesTextBody(t *testing.T) {
+	email := NewEmail("ada@example.com", "Hi", "<p>Hello <b>Ada</b></p>", "")
+	if email.TextBody != "Hello Ada" {
+		t.Errorf("TextBody = %q, want derived text", email.TextBody)
+	}
+
+	email = NewEmail("ada@example.com", "Hi", "<p>Hello</p>", "Custom text")
+	if email.TextBody != "Custom text" {
+		t.Errorf("TextBody = %q, want the given text body", email.TextBody)
+	}
+}
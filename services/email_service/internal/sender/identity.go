@@ -0,0 +1,140 @@
+package sender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Identity is the sender an email goes out as
+type Identity struct {
+	Address string `json:"from"`
+	Name    string `json:"name"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// String formats the identity as an RFC 5322 address
+func (i Identity) String() string {
+	if i.Address == "" {
+		return ""
+	}
+	return (&mail.Address{Name: i.Name, Address: i.Address}).String()
+}
+
+// identityFile is the JSON layout of SENDER_IDENTITIES_FILE
+type identityFile struct {
+	Identities map[string]Identity `json:"identities"`
+	Default    string              `json:"default"`
+	Rules      struct {
+		TemplateCode     map[string]string `json:"template_code"`
+		NotificationType map[string]string `json:"notification_type"`
+		Tenant           map[string]string `json:"tenant"`
+	} `json:"rules"`
+}
+
+// IdentityResolver picks the sender identity for a message. In order of
+// precedence it uses the identity named on the message, a template_code
+// rule, a notification_type rule, a tenant rule and finally the default.
+type IdentityResolver struct {
+	identities         map[string]Identity
+	fallback           Identity
+	byTemplateCode     map[string]string
+	byNotificationType map[string]string
+	byTenant           map[string]string
+}
+
+// NewIdentityResolver builds a resolver from the configured default identity
+// and the optional identities file
+func NewIdentityResolver(cfg config.IdentityConfig) (*IdentityResolver, error) {
+	r := &IdentityResolver{
+		identities: make(map[string]Identity),
+		fallback: Identity{
+			Address: cfg.FromAddress,
+			Name:    cfg.FromName,
+			ReplyTo: cfg.ReplyTo,
+		},
+	}
+
+	if cfg.FromAddress != "" {
+		if _, err := mail.ParseAddress(cfg.FromAddress); err != nil {
+			return nil, fmt.Errorf("invalid default from address: %w", err)
+		}
+	}
+
+	if cfg.File == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sender identities: %w", err)
+	}
+	var file identityFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse sender identities: %w", err)
+	}
+
+	for name, identity := range file.Identities {
+		if _, err := mail.ParseAddress(identity.Address); err != nil {
+			return nil, fmt.Errorf("invalid from address for sender identity %s: %w", name, err)
+		}
+		r.identities[name] = identity
+	}
+
+	if file.Default != "" {
+		identity, ok := r.identities[file.Default]
+		if !ok {
+			return nil, fmt.Errorf("default sender identity %s is not defined", file.Default)
+		}
+		r.fallback = identity
+	}
+
+	for _, rules := range []map[string]string{file.Rules.TemplateCode, file.Rules.NotificationType, file.Rules.Tenant} {
+		for key, name := range rules {
+			if _, ok := r.identities[name]; !ok {
+				return nil, fmt.Errorf("sender identity %s used by rule %s is not defined", name, key)
+			}
+		}
+	}
+	r.byTemplateCode = file.Rules.TemplateCode
+	r.byNotificationType = file.Rules.NotificationType
+	r.byTenant = file.Rules.Tenant
+
+	return r, nil
+}
+
+// Resolve returns the identity for a message. An empty Address means no
+// identity is configured and the provider's own default applies.
+func (r *IdentityResolver) Resolve(msg *models.EmailMessage) Identity {
+	if msg.SenderIdentity != "" {
+		if identity, ok := r.identities[msg.SenderIdentity]; ok {
+			return identity
+		}
+		logger.Log.Warn("unknown sender identity, using default",
+			zap.String("notification_id", msg.NotificationID),
+			zap.String("sender_identity", msg.SenderIdentity),
+		)
+	}
+
+	rules := []struct {
+		byKey map[string]string
+		key   string
+	}{
+		{r.byTemplateCode, msg.TemplateCode},
+		{r.byNotificationType, msg.NotificationType},
+		{r.byTenant, msg.TenantID},
+	}
+	for _, rule := range rules {
+		if name, ok := rule.byKey[rule.key]; ok && rule.key != "" {
+			return r.identities[name]
+		}
+	}
+
+	return r.fallback
+}
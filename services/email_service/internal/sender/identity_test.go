@@ -0,0 +1,128 @@
+package sender
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func writeIdentities(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "identities.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testIdentities = `{
+	"identities": {
+		"billing":  {"from": "billing@example.com", "name": "Billing", "reply_to": "accounts@example.com"},
+		"security": {"from": "security@example.com", "name": "Security"},
+		"acme":     {"from": "hello@acme.example", "name": "Acme"},
+		"news":     {"from": "news@example.com", "name": "Newsletter"}
+	},
+	"rules": {
+		"template_code":     {"invoice": "billing", "password_reset": "security"},
+		"notification_type": {"security_alert": "security", "newsletter": "news"},
+		"tenant":            {"acme": "acme"}
+	}
+}`
+
+func TestIdentityResolverPrecedence(t *testing.T) {
+	logger.Log = zap.NewNop()
+
+	resolver, err := NewIdentityResolver(config.IdentityConfig{
+		FromAddress: "noreply@example.com",
+		FromName:    "Notifications",
+		File:        writeIdentities(t, testIdentities),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		msg  models.EmailMessage
+		want string
+	}{
+		{"message identity beats every rule", models.EmailMessage{SenderIdentity: "news", TemplateCode: "invoice", NotificationType: "security_alert", TenantID: "acme"}, "news@example.com"},
+		{"template rule beats type and tenant", models.EmailMessage{TemplateCode: "invoice", NotificationType: "security_alert", TenantID: "acme"}, "billing@example.com"},
+		{"type rule beats tenant", models.EmailMessage{TemplateCode: "welcome", NotificationType: "security_alert", TenantID: "acme"}, "security@example.com"},
+		{"tenant rule", models.EmailMessage{TemplateCode: "welcome", NotificationType: "promo", TenantID: "acme"}, "hello@acme.example"},
+		{"no rule matches", models.EmailMessage{TemplateCode: "welcome", NotificationType: "promo", TenantID: "globex"}, "noreply@example.com"},
+		{"unknown message identity falls back to the rules", models.EmailMessage{SenderIdentity: "marketing", TemplateCode: "invoice"}, "billing@example.com"},
+		{"unknown message identity without rules", models.EmailMessage{SenderIdentity: "marketing"}, "noreply@example.com"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := resolver.Resolve(&tc.msg); got.Address != tc.want {
+				t.Errorf("Resolve() = %s, want %s", got.Address, tc.want)
+			}
+		})
+	}
+
+	billing := resolver.Resolve(&models.EmailMessage{TemplateCode: "invoice"})
+	if billing.String() != `"Billing" <billing@example.com>` || billing.ReplyTo != "accounts@example.com" {
+		t.Errorf("billing identity = %+v", billing)
+	}
+}
+
+func TestIdentityResolverDefaults(t *testing.T) {
+	// Without any configuration the provider's own default applies
+	resolver, err := NewIdentityResolver(config.IdentityConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolver.Resolve(&models.EmailMessage{TemplateCode: "invoice"}); got != (Identity{}) || got.String() != "" {
+		t.Errorf("Resolve() = %+v, want no identity", got)
+	}
+
+	// A default named in the file replaces the configured address
+	resolver, err = NewIdentityResolver(config.IdentityConfig{
+		FromAddress: "noreply@example.com",
+		File:        writeIdentities(t, `{"identities": {"team": {"from": "team@example.com"}}, "default": "team"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resolver.Resolve(&models.EmailMessage{}); got.Address != "team@example.com" {
+		t.Errorf("Resolve() = %s, want team@example.com", got.Address)
+	}
+}
+
+func TestNewIdentityResolverErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  config.IdentityConfig
+		file string
+		want string
+	}{
+		{"invalid default address", config.IdentityConfig{FromAddress: "not an address"}, "", "invalid default from address"},
+		{"unknown default identity", config.IdentityConfig{}, `{"identities": {}, "default": "team"}`, "default sender identity team is not defined"},
+		{"unknown identity in a rule", config.IdentityConfig{}, `{"identities": {}, "rules": {"tenant": {"acme": "acme"}}}`, "sender identity acme used by rule acme is not defined"},
+		{"invalid identity address", config.IdentityConfig{}, `{"identities": {"team": {"from": "team"}}}`, "invalid from address for sender identity team"},
+		{"malformed file", config.IdentityConfig{}, `{"identities": [`, "failed to parse sender identities"},
+		{"missing file", config.IdentityConfig{File: "/nonexistent/identities.json"}, "", "failed to read sender identities"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.file != "" {
+				tc.cfg.File = writeIdentities(t, tc.file)
+			}
+			_, err := NewIdentityResolver(tc.cfg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("NewIdentityResolver() = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,40 @@
 package sender
 
-// EmailSender interface for different email providers
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// EmailSender interface for different email providers. Send must stop and
+// return the context's error once ctx is cancelled or its deadline passes.
 type EmailSender interface {
-	Send(to, subject, body string) error
+	Send(ctx context.Context, email *Email) (*SendResult, error)
 	GetProviderName() string
 }
+
+// SendResult describes a successful delivery to a provider
+type SendResult struct {
+	Provider          string            // provider that accepted the message
+	ProviderMessageID string            // provider's ID for the message, e.g. SMTP queue ID or SendGrid X-Message-Id
+	MessageID         string            // RFC 5322 Message-ID header, when the service generated it
+	Metadata          map[string]string // raw response details such as the SMTP reply or HTTP status
+}
+
+// ProviderError is an error response from a provider's HTTP API
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s error: status %d, body: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Permanent reports whether the provider rejected the message itself: any
+// 4xx except request timeouts and rate limiting, which are worth retrying
+func (e *ProviderError) Permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,184 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// mailgunMaxTags is how many o:tag values Mailgun accepts per message
+const mailgunMaxTags = 3
+
+var mailgunRegionURLs = map[string]string{
+	"us": "https://api.mailgun.net",
+	"eu": "https://api.eu.mailgun.net",
+}
+
+type MailgunSender struct {
+	config     config.MailgunConfig
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewMailgunSender(cfg config.MailgunConfig) (*MailgunSender, error) {
+	if cfg.APIKey == "" || cfg.Domain == "" {
+		return nil, fmt.Errorf("Mailgun API key and domain are required")
+	}
+
+	// An explicit base URL (e.g. a local stand-in) takes precedence over the region
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		region := strings.ToLower(cfg.Region)
+		if region == "" {
+			region = "us"
+		}
+		var ok bool
+		baseURL, ok = mailgunRegionURLs[region]
+		if !ok {
+			return nil, fmt.Errorf("unknown Mailgun region: %s", cfg.Region)
+		}
+	}
+
+	return &MailgunSender{
+		config:  cfg,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+func (s *MailgunSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	body, contentType, err := s.buildForm(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Mailgun request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v3/%s/messages", s.baseURL, s.config.Domain)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Mailgun request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("api", s.config.APIKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send email via Mailgun: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &ProviderError{Provider: s.GetProviderName(), StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	// A decode failure only loses the ID; the message was already accepted
+	var accepted struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&accepted)
+
+	return &SendResult{
+		Provider:          s.GetProviderName(),
+		ProviderMessageID: strings.Trim(accepted.ID, "<>"),
+		Metadata: map[string]string{
+			"status_code": strconv.Itoa(resp.StatusCode),
+			"message":     accepted.Message,
+		},
+	}, nil
+}
+
+func (s *MailgunSender) GetProviderName() string {
+	return "mailgun"
+}
+
+// buildForm encodes the email as the multipart/form-data body expected by the
+// Mailgun messages API
+func (s *MailgunSender) buildForm(email *Email) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	from := email.From.String()
+	if from == "" {
+		from = fmt.Sprintf("Notification System <noreply@%s>", s.config.Domain)
+	}
+
+	fields := [][2]string{
+		{"from", from},
+		{"to", email.To},
+		{"subject", email.Subject},
+		{"text", email.TextBody},
+	}
+	if email.HTMLBody != "" {
+		fields = append(fields, [2]string{"html", email.HTMLBody})
+	}
+	for _, cc := range email.Cc {
+		fields = append(fields, [2]string{"cc", cc})
+	}
+	for _, bcc := range email.Bcc {
+		fields = append(fields, [2]string{"bcc", bcc})
+	}
+	if email.ReplyTo != "" {
+		fields = append(fields, [2]string{"h:Reply-To", email.ReplyTo})
+	}
+	for key, value := range email.Headers {
+		fields = append(fields, [2]string{"h:" + key, value})
+	}
+	for i, tag := range email.Tags {
+		if i == mailgunMaxTags {
+			break
+		}
+		fields = append(fields, [2]string{"o:tag", tag})
+	}
+
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, att := range email.Attachments {
+		// Mailgun exposes inline files to the HTML body as cid:<filename>
+		field, filename := "attachment", att.Filename
+		if att.Inline && email.HTMLBody != "" {
+			field, filename = "inline", att.ContentID
+		}
+
+		part, err := writer.CreatePart(newFormFileHeader(field, filename, att.ContentType))
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(att.Data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &buf, writer.FormDataContentType(), nil
+}
+
+func newFormFileHeader(field, filename, contentType string) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{
+		"name":     field,
+		"filename": filename,
+	}))
+	header.Set("Content-Type", contentType)
+	return header
+}
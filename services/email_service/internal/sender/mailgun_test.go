@@ -0,0 +1,204 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// newMailgunStandIn serves the Mailgun messages API, handing each request to
+// handle
+func newMailgunStandIn(t *testing.T, handle http.HandlerFunc) *MailgunSender {
+	t.Helper()
+
+	srv := httptest.NewServer(handle)
+	t.Cleanup(srv.Close)
+
+	s, err := NewMailgunSender(config.MailgunConfig{APIKey: "key-123", Domain: "mg.example.com", BaseURL: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMailgunSenderSendsForm(t *testing.T) {
+	var form map[string][]string
+	var files map[string][2]string // field to filename and content
+	s := newMailgunStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/mg.example.com/messages" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "api" || password != "key-123" {
+			t.Errorf("basic auth = %q %q %v, want api:key-123", user, password, ok)
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		form = r.MultipartForm.Value
+		files = make(map[string][2]string)
+		for field, headers := range r.MultipartForm.File {
+			f, _ := headers[0].Open()
+			data, _ := io.ReadAll(f)
+			f.Close()
+			files[field] = [2]string{headers[0].Filename, string(data)}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": "<20250120.abc123@mg.example.com>", "message": "Queued. Thank you."}`)
+	})
+
+	email := NewEmail("ada@example.com", "Your receipt", `<p>Thanks <img src="cid:logo.png"></p>`, "")
+	email.From = Identity{Address: "billing@example.com", Name: "Billing"}
+	email.Cc = []string{"bola@example.com", "chi@example.com"}
+	email.Bcc = []string{"audit@example.com"}
+	email.ReplyTo = "support@example.com"
+	email.Headers = map[string]string{"X-Campaign": "spring"}
+	email.Tags = []string{"receipt", "billing", "transactional", "extra"}
+	email.Attachments = []Attachment{
+		{Filename: "receipt.pdf", ContentType: "application/pdf", Data: []byte("%PDF")},
+		{Filename: "logo.png", ContentType: "image/png", Data: []byte("png"), Inline: true, ContentID: "logo.png"},
+	}
+
+	result, err := s.Send(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"from":         {`"Billing" <billing@example.com>`},
+		"to":           {"ada@example.com"},
+		"cc":           {"bola@example.com", "chi@example.com"},
+		"bcc":          {"audit@example.com"},
+		"subject":      {"Your receipt"},
+		"text":         {"Thanks"},
+		"html":         {email.HTMLBody},
+		"h:Reply-To":   {"support@example.com"},
+		"h:X-Campaign": {"spring"},
+		"o:tag":        {"receipt", "billing", "transactional"},
+	}
+	if !reflect.DeepEqual(form, want) {
+		t.Errorf("form = %v\nwant %v", form, want)
+	}
+	wantFiles := map[string][2]string{
+		"attachment": {"receipt.pdf", "%PDF"},
+		"inline":     {"logo.png", "png"},
+	}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+
+	if result.Provider != "mailgun" || result.ProviderMessageID != "20250120.abc123@mg.example.com" {
+		t.Errorf("result = %+v", result)
+	}
+	if result.Metadata["message"] != "Queued. Thank you." || result.Metadata["status_code"] != "200" {
+		t.Errorf("metadata = %v", result.Metadata)
+	}
+}
+
+func TestMailgunSenderDefaultFrom(t *testing.T) {
+	var from string
+	s := newMailgunStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		from = r.FormValue("from")
+		io.WriteString(w, `{"id": "<1@mg.example.com>"}`)
+	})
+
+	if _, err := s.Send(context.Background(), NewEmail("ada@example.com", "Hi", "", "Hi")); err != nil {
+		t.Fatal(err)
+	}
+	if from != "Notification System <noreply@mg.example.com>" {
+		t.Errorf("from = %q", from)
+	}
+}
+
+func TestNewMailgunSenderBaseURL(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  config.MailgunConfig
+		want string
+	}{
+		{"default region", config.MailgunConfig{}, "https://api.mailgun.net"},
+		{"us", config.MailgunConfig{Region: "us"}, "https://api.mailgun.net"},
+		{"eu", config.MailgunConfig{Region: "EU"}, "https://api.eu.mailgun.net"},
+		{"base url wins", config.MailgunConfig{Region: "eu", BaseURL: "http://127.0.0.1:9000/"}, "http://127.0.0.1:9000"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.APIKey, tc.cfg.Domain = "key-123", "mg.example.com"
+			s, err := NewMailgunSender(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.baseURL != tc.want {
+				t.Errorf("baseURL = %s, want %s", s.baseURL, tc.want)
+			}
+		})
+	}
+
+	for _, cfg := range []config.MailgunConfig{
+		{APIKey: "key-123", Domain: "mg.example.com", Region: "ap"},
+		{Domain: "mg.example.com"},
+		{APIKey: "key-123"},
+	} {
+		if _, err := NewMailgunSender(cfg); err == nil {
+			t.Errorf("NewMailgunSender(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestMailgunSenderErrors(t *testing.T) {
+	cases := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusForbidden, true},
+		{http.StatusRequestEntityTooLarge, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			s := newMailgunStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				io.WriteString(w, `{"message": "rejected"}`)
+			})
+
+			_, err := s.Send(context.Background(), testEmail())
+			var providerErr *ProviderError
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("Send() = %v, want a ProviderError", err)
+			}
+			if providerErr.StatusCode != tc.status || providerErr.Permanent() != tc.permanent {
+				t.Errorf("error = %+v, permanent = %v, want %v", providerErr, providerErr.Permanent(), tc.permanent)
+			}
+			if !strings.Contains(err.Error(), "rejected") {
+				t.Errorf("error %q does not carry the response body", err)
+			}
+		})
+	}
+}
+
+func TestMailgunSenderHonoursContext(t *testing.T) {
+	s := newMailgunStandIn(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Send(ctx, testEmail()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Send() = %v, want context.Canceled", err)
+	}
+}
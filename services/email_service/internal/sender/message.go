@@ -0,0 +1,64 @@
+package sender
+
+import (
+	"fmt"
+	"net/mail"
+)
+
+// Email is an outgoing message handed to an EmailSender
+type Email struct {
+	From     Identity // empty Address falls back to the provider default
+	To       string
+	Cc       []string
+	Bcc      []string
+	ReplyTo  string
+	Subject  string
+	HTMLBody string
+	TextBody string
+
+	// Headers holds allow-listed custom headers (see FilterHeaders)
+	Headers     map[string]string
+	Attachments []Attachment
+	Tags        []string // provider analytics tags, e.g. the notification type
+}
+
+// Recipients returns every envelope recipient: To, Cc and Bcc
+func (e *Email) Recipients() []string {
+	recipients := make([]string, 0, 1+len(e.Cc)+len(e.Bcc))
+	recipients = append(recipients, e.To)
+	recipients = append(recipients, e.Cc...)
+	recipients = append(recipients, e.Bcc...)
+	return recipients
+}
+
+// NewEmail creates an outgoing email, deriving the plain-text part from the
+// HTML body when no text body is provided
+func NewEmail(to, subject, htmlBody, textBody string) *Email {
+	if textBody == "" {
+		textBody = HTMLToText(htmlBody)
+	}
+
+	return &Email{
+		To:       to,
+		Subject:  subject,
+		HTMLBody: htmlBody,
+		TextBody: textBody,
+	}
+}
+
+// Validate checks that every address on the email parses, which also keeps
+// line breaks out of the address headers
+func (e *Email) Validate() error {
+	addresses := e.Recipients()
+	if e.ReplyTo != "" {
+		addresses = append(addresses, e.ReplyTo)
+	}
+
+	for _, addr := range addresses {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid email address %q: %w", addr, err)
+		}
+	}
+
+	return nil
+}
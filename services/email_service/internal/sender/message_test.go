@@ -0,0 +1,71 @@
+package sender
+
+import (
+	"bytes"
+	"context"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBccOnlyInEnvelope(t *testing.T) {
+	email := NewEmail("ada@example.com", "Hello", "<p>Hi</p>", "")
+	email.Cc = []string{"bola@example.com"}
+	email.Bcc = []string{"audit@example.com", "Hidden <hidden@example.com>"}
+
+	want := []string{"ada@example.com", "bola@example.com", "audit@example.com", "Hidden <hidden@example.com>"}
+	if got := email.Recipients(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Recipients() = %v, want %v", got, want)
+	}
+
+	// The SMTP envelope carries every recipient, the headers never show Bcc
+	srv := newFakeSMTPServer(t)
+	if _, err := newTestSMTPSender(t, srv, 1).Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+	received := srv.lastMessage(t)
+	wantEnvelope := []string{"ada@example.com", "bola@example.com", "audit@example.com", "hidden@example.com"}
+	if !reflect.DeepEqual(received.recipients, wantEnvelope) {
+		t.Errorf("RCPT TO = %v, want %v", received.recipients, wantEnvelope)
+	}
+
+	message := received.data
+	for _, leaked := range []string{"audit@example.com", "hidden@example.com", "Bcc:"} {
+		if bytes.Contains(message, []byte(leaked)) {
+			t.Errorf("message contains %q", leaked)
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc := parsed.Header.Get("Cc"); cc != "<bola@example.com>" {
+		t.Errorf("Cc = %q", cc)
+	}
+}
+
+func TestValidateRejectsBadAddresses(t *testing.T) {
+	cases := map[string]func(*Email){
+		"to":        func(e *Email) { e.To = "not an address" },
+		"cc":        func(e *Email) { e.Cc = []string{"bola@example.com", "bad@"} },
+		"bcc":       func(e *Email) { e.Bcc = []string{"audit@example.com\r\nX-Injected: yes"} },
+		"reply-to":  func(e *Email) { e.ReplyTo = "support@example.com\nBcc: spy@example.com" },
+		"empty bcc": func(e *Email) { e.Bcc = []string{""} },
+	}
+
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			email := NewEmail("ada@example.com", "Hello", "<p>Hi</p>", "")
+			mutate(email)
+			if err := email.Validate(); err == nil || !strings.Contains(err.Error(), "invalid email address") {
+				t.Errorf("Validate() = %v", err)
+			}
+		})
+	}
+
+	if err := NewEmail("Ada <ada@example.com>", "Hello", "<p>Hi</p>", "").Validate(); err != nil {
+		t.Errorf("valid email: %v", err)
+	}
+}
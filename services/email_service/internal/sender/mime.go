@@ -0,0 +1,260 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxHeaderLine is the line length headers are folded at (RFC 5322 2.1.1)
+const maxHeaderLine = 78
+
+// mimePart is a rendered MIME entity: its part headers and encoded body
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// newTextPart builds a text part, picking the lightest transfer encoding that
+// keeps it 7-bit safe: none for short-lined ASCII, quoted-printable for mostly
+// ASCII text and base64 for text that is mostly non-ASCII (e.g. Arabic)
+func newTextPart(contentType, body string) *mimePart {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+
+	var nonASCII int
+	for i := 0; i < len(body); i++ {
+		if body[i] >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+
+	switch {
+	case nonASCII == 0 && maxLineLength(body) <= maxHeaderLine:
+		header.Set("Content-Transfer-Encoding", "7bit")
+		return &mimePart{header: header, body: normalizeCRLF([]byte(body))}
+	case nonASCII > len(body)/3:
+		header.Set("Content-Transfer-Encoding", "base64")
+		return &mimePart{header: header, body: encodeBase64Lines([]byte(body))}
+	default:
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		return &mimePart{header: header, body: encodeQuotedPrintable(body)}
+	}
+}
+
+// newAttachmentPart builds a base64-encoded part for an attachment. Inline
+// attachments carry a Content-ID so the HTML body can reference them as cid:.
+func newAttachmentPart(att Attachment) *mimePart {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(att.ContentType, map[string]string{"name": att.Filename}))
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	disposition := "attachment"
+	if att.Inline {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+att.ContentID+">")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}))
+
+	return &mimePart{header: header, body: encodeBase64Lines(att.Data)}
+}
+
+// buildMultipart wraps parts into a single multipart entity of the given subtype
+func buildMultipart(subtype string, parts []*mimePart) (*mimePart, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for _, p := range parts {
+		pw, err := writer.CreatePart(p.header)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s part: %w", subtype, err)
+		}
+		if _, err := pw.Write(p.body); err != nil {
+			return nil, fmt.Errorf("failed to write %s part: %w", subtype, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", fmt.Sprintf("multipart/%s; boundary=%q", subtype, writer.Boundary()))
+
+	return &mimePart{header: header, body: buf.Bytes()}, nil
+}
+
+// buildBody builds the top-level body entity for an email:
+//
+//	multipart/mixed
+//	├── multipart/related
+//	│   ├── multipart/alternative (text/plain, text/html)
+//	│   └── inline images
+//	└── attachments
+//
+// Levels without content are left out. Messages without an HTML body are sent
+// as text/plain and any inline attachments become regular attachments.
+func buildBody(email *Email) (*mimePart, error) {
+	var content *mimePart
+	var inline, attached []*mimePart
+
+	for _, att := range email.Attachments {
+		if att.Inline && email.HTMLBody != "" {
+			inline = append(inline, newAttachmentPart(att))
+			continue
+		}
+		att.Inline = false
+		attached = append(attached, newAttachmentPart(att))
+	}
+
+	if email.HTMLBody == "" {
+		content = newTextPart("text/plain; charset=UTF-8", email.TextBody)
+	} else {
+		// Plain text first and HTML last, as preferred by RFC 2046
+		alternative, err := buildMultipart("alternative", []*mimePart{
+			newTextPart("text/plain; charset=UTF-8", email.TextBody),
+			newTextPart("text/html; charset=UTF-8", email.HTMLBody),
+		})
+		if err != nil {
+			return nil, err
+		}
+		content = alternative
+	}
+
+	if len(inline) > 0 {
+		related, err := buildMultipart("related", append([]*mimePart{content}, inline...))
+		if err != nil {
+			return nil, err
+		}
+		content = related
+	}
+
+	if len(attached) > 0 {
+		mixed, err := buildMultipart("mixed", append([]*mimePart{content}, attached...))
+		if err != nil {
+			return nil, err
+		}
+		content = mixed
+	}
+
+	return content, nil
+}
+
+// encodeBase64Lines base64-encodes data wrapped at 76 characters per line (RFC 2045)
+func encodeBase64Lines(data []byte) []byte {
+	const lineLength = 76
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > lineLength {
+		buf.WriteString(encoded[:lineLength])
+		buf.WriteString("\r\n")
+		encoded = encoded[lineLength:]
+	}
+	buf.WriteString(encoded)
+
+	return buf.Bytes()
+}
+
+// encodeQuotedPrintable encodes text as quoted-printable with CRLF line breaks
+func encodeQuotedPrintable(text string) []byte {
+	var buf bytes.Buffer
+	writer := quotedprintable.NewWriter(&buf)
+	writer.Write([]byte(text))
+	writer.Close()
+	return buf.Bytes()
+}
+
+func maxLineLength(text string) int {
+	longest := 0
+	for _, line := range strings.Split(text, "\n") {
+		if n := len(strings.TrimSuffix(line, "\r")); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// encodeHeaderValue returns ASCII values unchanged and turns anything else
+// into RFC 2047 encoded-words: Q encoding when the text is mostly ASCII and B
+// encoding otherwise
+func encodeHeaderValue(value string) string {
+	value = stripLineBreaks(value)
+	nonASCII := 0
+	for _, r := range value {
+		if r >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII == 0 {
+		return value
+	}
+	if nonASCII > utf8.RuneCountInString(value)/2 {
+		return mime.BEncoding.Encode("UTF-8", value)
+	}
+	return mime.QEncoding.Encode("UTF-8", value)
+}
+
+// formatAddressList renders addresses for an address header. Display names
+// are encoded as needed; unparseable entries are kept as given since they
+// were validated before sending.
+func formatAddressList(addresses []string) string {
+	formatted := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if parsed, err := mail.ParseAddress(address); err == nil {
+			address = parsed.String()
+		}
+		formatted = append(formatted, address)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// lineBreaks turns CR and LF into spaces so a header value cannot end the
+// field early and inject headers of its own
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func stripLineBreaks(value string) string {
+	return lineBreaks.Replace(value)
+}
+
+// writeHeader writes a header field, folding it at whitespace so lines stay
+// within maxHeaderLine where possible. Line breaks in the value are replaced.
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	value = stripLineBreaks(value)
+	buf.WriteString(name)
+	buf.WriteString(":")
+	lineLength := len(name) + 1
+
+	for _, word := range strings.Split(value, " ") {
+		// Fold only when the word then fits, and never before an empty word,
+		// which would leave a line of only whitespace
+		fits := 1+len(word) <= maxHeaderLine
+		if word != "" && fits && lineLength+1+len(word) > maxHeaderLine {
+			buf.WriteString("\r\n")
+			lineLength = 0
+		}
+		buf.WriteString(" ")
+		buf.WriteString(word)
+		lineLength += 1 + len(word)
+	}
+	buf.WriteString("\r\n")
+}
+
+// newMessageID generates a unique Message-ID in the sender's domain
+func newMessageID(from string) string {
+	domain := "localhost"
+	if address, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(address.Address, "@"); at >= 0 {
+			domain = address.Address[at+1:]
+		}
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
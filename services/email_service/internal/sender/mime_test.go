@@ -0,0 +1,230 @@
+package sender
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// splitMultipart parses a multipart entity into its media subtype and parts
+func splitMultipart(t *testing.T, entity *mimePart) (string, []*mimePart) {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(entity.header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subtype, ok := strings.CutPrefix(mediaType, "multipart/")
+	if !ok {
+		t.Fatalf("Content-Type = %s, want multipart", mediaType)
+	}
+
+	var parts []*mimePart
+	reader := multipart.NewReader(bytes.NewReader(entity.body), params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, &mimePart{header: textproto.MIMEHeader(part.Header), body: body})
+	}
+	return subtype, parts
+}
+
+func mediaType(part *mimePart) string {
+	mediaType, _, _ := mime.ParseMediaType(part.header.Get("Content-Type"))
+	return mediaType
+}
+
+func TestBuildMessageEncodesHeaders(t *testing.T) {
+	subject := "Ẹ kú àbọ̀ sí HNG — bienvenue à l'équipe, مرحبا بكم في الفريق"
+	email := NewEmail(`"Adé Ọlá" <ade@example.com>`, subject, "<p>Hello</p>", "")
+	email.Cc = []string{"Zoë <zoe@example.com>", "team@example.com"}
+	email.Headers = map[string]string{"X-Tag": "onboarding", "Keywords": "welcome"}
+
+	message, messageID, err := buildMessage(`"Équipe HNG" <noreply@hng.example>`, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headerEnd := bytes.Index(message, []byte("\r\n\r\n"))
+	for _, line := range strings.Split(string(message[:headerEnd]), "\r\n") {
+		if len(line) > maxHeaderLine {
+			t.Errorf("header line longer than %d: %q", maxHeaderLine, line)
+		}
+		for _, r := range line {
+			if r > 127 {
+				t.Fatalf("raw non-ASCII in header line %q", line)
+			}
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := new(mime.WordDecoder)
+	if got, _ := decoder.DecodeHeader(parsed.Header.Get("Subject")); got != subject {
+		t.Errorf("Subject = %q, want %q", got, subject)
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil || to[0].Name != "Adé Ọlá" {
+		t.Errorf("To = %v (%v)", to, err)
+	}
+	if got := parsed.Header.Get("Message-ID"); got != messageID || !strings.HasSuffix(got, "@hng.example>") {
+		t.Errorf("Message-ID = %q", parsed.Header.Get("Message-ID"))
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Date: %v", err)
+	}
+
+	var order []string
+	for _, field := range splitHeaderFields(message[:headerEnd+2]) {
+		order = append(order, fieldName(field))
+	}
+	want := "Date,From,To,Cc,Subject,Message-ID,Keywords,X-Tag,MIME-Version,Content-Type"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("header order = %s, want %s", got, want)
+	}
+}
+
+func TestNewTextPartEncoding(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		encoding string
+	}{
+		{"short ascii", "Hello\nWorld", "7bit"},
+		{"long line", "<p>" + strings.Repeat("word ", 100) + "</p>", "quoted-printable"},
+		{"accented", "Bonjour, votre compte a été créé.", "quoted-printable"},
+		{"arabic", "مرحبا بكم في فريق الإشعارات", "base64"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			part := newTextPart("text/plain; charset=UTF-8", tc.body)
+			if got := part.header.Get("Content-Transfer-Encoding"); got != tc.encoding {
+				t.Fatalf("encoding = %s, want %s", got, tc.encoding)
+			}
+
+			for _, line := range strings.Split(string(part.body), "\r\n") {
+				if len(line) > 76 && tc.encoding != "7bit" {
+					t.Errorf("encoded line longer than 76: %q", line)
+				}
+			}
+
+			text, _ := extractBodies("text/plain", tc.encoding, bytes.NewReader(part.body))
+			if want := strings.ReplaceAll(tc.body, "\n", "\r\n"); text != want {
+				t.Errorf("decoded body = %q, want %q", text, want)
+			}
+		})
+	}
+}
+
+func TestWriteHeaderFoldsAtWhitespace(t *testing.T) {
+	var buf bytes.Buffer
+	value := strings.Repeat("lorem ipsum ", 20)
+	writeHeader(&buf, "Subject", value)
+
+	folded := buf.String()
+	if !strings.Contains(folded, "\r\n ") {
+		t.Fatalf("expected folded header, got %q", folded)
+	}
+	if unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n", ""); unfolded != "Subject: "+value {
+		t.Errorf("unfolded = %q", unfolded)
+	}
+
+	// An unbreakable value is left long rather than split mid-word
+	buf.Reset()
+	writeHeader(&buf, "X-Token", strings.Repeat("a", 100))
+	if got, _ := io.ReadAll(&buf); strings.Count(string(got), "\r\n") != 1 {
+		t.Errorf("unexpected fold in %q", got)
+	}
+}
+
+func TestBuildMessageStripsLineBreaks(t *testing.T) {
+	for _, subject := range []string{
+		"Hello\r\nBcc: victim@example.org",
+		"Héllo\nBcc: victim@example.org",
+		"Hello\rBcc: victim@example.org",
+	} {
+		email := NewEmail("ada@example.com", subject, "<p>Hello</p>", "")
+		email.ReplyTo = "support@example.com\r\nX-Injected: reply-to"
+		email.Headers = map[string]string{"X-Tag": "welcome\r\nX-Injected: custom"}
+
+		message, _, err := buildMessage("noreply@example.com", email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := mail.ReadMessage(bytes.NewReader(message))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"Bcc", "X-Injected"} {
+			if got := parsed.Header.Get(name); got != "" {
+				t.Errorf("subject %q injected %s: %q", subject, name, got)
+			}
+		}
+		decoder := new(mime.WordDecoder)
+		if got, _ := decoder.DecodeHeader(parsed.Header.Get("Subject")); strings.ContainsAny(got, "\r\n") ||
+			!strings.Contains(got, "Bcc: victim@example.org") {
+			t.Errorf("Subject = %q, want the line break replaced", got)
+		}
+	}
+}
+
+func TestBuildBodyAlternative(t *testing.T) {
+	email := NewEmail("ada@example.com", "Welcome", "<p>Hello <a href=\"https://example.com\">there</a></p>", "")
+
+	body, err := buildBody(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subtype, parts := splitMultipart(t, body)
+	if subtype != "alternative" || len(parts) != 2 {
+		t.Fatalf("got multipart/%s with %d parts, want multipart/alternative with 2", subtype, len(parts))
+	}
+
+	// Plain text first and HTML last, each decodable with its own encoding
+	for i, want := range []struct{ mediaType, body string }{
+		{"text/plain", "Hello there (https://example.com)"},
+		{"text/html", email.HTMLBody},
+	} {
+		if got := mediaType(parts[i]); got != want.mediaType {
+			t.Errorf("part %d is %s, want %s", i, got, want.mediaType)
+		}
+		if charset := parts[i].header.Get("Content-Type"); !strings.Contains(charset, "charset=UTF-8") {
+			t.Errorf("part %d Content-Type = %s, want UTF-8", i, charset)
+		}
+		text, html := extractBodies(want.mediaType, parts[i].header.Get("Content-Transfer-Encoding"), bytes.NewReader(parts[i].body))
+		if got := text + html; got != want.body {
+			t.Errorf("part %d body = %q, want %q", i, got, want.body)
+		}
+	}
+}
+
+func TestBuildBodyTextOnly(t *testing.T) {
+	body, err := buildBody(NewEmail("ada@example.com", "Welcome", "", "Plain hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mediaType(body); got != "text/plain" {
+		t.Errorf("Content-Type = %s, want text/plain without an HTML body", got)
+	}
+	if string(body.body) != "Plain hello" {
+		t.Errorf("body = %q", body.body)
+	}
+}
@@ -1,7 +1,10 @@
 package sender
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
+	"strconv"
 
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
 	"github.com/sendgrid/sendgrid-go"
@@ -26,21 +29,110 @@ func NewSendGridSender(cfg config.SendGridConfig) (*SendGridSender, error) {
 	}, nil
 }
 
-func (s *SendGridSender) Send(to, subject, body string) error {
+func (s *SendGridSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	message := s.newMessage(email)
+
+	personalization := mail.NewPersonalization()
+	personalization.AddTos(mail.NewEmail("", email.To))
+	for _, cc := range email.Cc {
+		personalization.AddCCs(mail.NewEmail("", cc))
+	}
+	for _, bcc := range email.Bcc {
+		personalization.AddBCCs(mail.NewEmail("", bcc))
+	}
+	message.AddPersonalizations(personalization)
+
+	return s.send(ctx, message)
+}
+
+// SendBulk sends one message to up to MaxBulkRecipients recipients, one
+// personalization each
+func (s *SendGridSender) SendBulk(ctx context.Context, email *BulkEmail) (*SendResult, error) {
+	if len(email.Recipients) > MaxBulkRecipients {
+		return nil, fmt.Errorf("bulk email has %d recipients, SendGrid allows %d per request", len(email.Recipients), MaxBulkRecipients)
+	}
+	return s.send(ctx, newBulkMessage(s.newMessage(&email.Email), email.Recipients))
+}
+
+// newBulkMessage adds a personalization per recipient to a shared message
+func newBulkMessage(message *mail.SGMailV3, recipients []BulkRecipient) *mail.SGMailV3 {
+	for _, recipient := range recipients {
+		personalization := mail.NewPersonalization()
+		personalization.AddTos(mail.NewEmail("", recipient.To))
+		// The batch shares one X-Message-Id, so events name the notification
+		if recipient.NotificationID != "" {
+			personalization.SetCustomArg("notification_id", recipient.NotificationID)
+		}
+		for tag, value := range recipient.Substitutions {
+			personalization.SetSubstitution(tag, value)
+		}
+		for key, value := range recipient.Headers {
+			personalization.SetHeader(key, value)
+		}
+		message.AddPersonalizations(personalization)
+	}
+	return message
+}
+
+// newMessage builds the parts of a message shared by every recipient
+func (s *SendGridSender) newMessage(email *Email) *mail.SGMailV3 {
 	from := mail.NewEmail("Notification System", "noreply@example.com")
-	toEmail := mail.NewEmail("", to)
-	message := mail.NewSingleEmail(from, subject, toEmail, "", body)
+	if email.From.Address != "" {
+		from = mail.NewEmail(email.From.Name, email.From.Address)
+	}
 
-	response, err := s.client.Send(message)
+	message := mail.NewV3Mail()
+	message.SetFrom(from)
+	message.Subject = email.Subject
+	if email.TextBody != "" {
+		message.AddContent(mail.NewContent("text/plain", email.TextBody))
+	}
+	if email.HTMLBody != "" {
+		message.AddContent(mail.NewContent("text/html", email.HTMLBody))
+	}
+
+	if email.ReplyTo != "" {
+		message.SetReplyTo(mail.NewEmail("", email.ReplyTo))
+	}
+	for key, value := range email.Headers {
+		message.SetHeader(key, value)
+	}
+	message.AddCategories(email.Tags...)
+
+	for _, att := range email.Attachments {
+		attachment := mail.NewAttachment().
+			SetFilename(att.Filename).
+			SetType(att.ContentType).
+			SetContent(base64.StdEncoding.EncodeToString(att.Data)).
+			SetDisposition("attachment")
+		if att.Inline {
+			attachment.SetDisposition("inline").SetContentID(att.ContentID)
+		}
+		message.AddAttachment(attachment)
+	}
+
+	return message
+}
+
+func (s *SendGridSender) send(ctx context.Context, message *mail.SGMailV3) (*SendResult, error) {
+	response, err := s.client.SendWithContext(ctx, message)
 	if err != nil {
-		return fmt.Errorf("failed to send email via SendGrid: %w", err)
+		return nil, fmt.Errorf("failed to send email via SendGrid: %w", err)
 	}
 
 	if response.StatusCode >= 400 {
-		return fmt.Errorf("SendGrid error: status %d, body: %s", response.StatusCode, response.Body)
+		return nil, &ProviderError{Provider: s.GetProviderName(), StatusCode: response.StatusCode, Body: response.Body}
+	}
+
+	result := &SendResult{
+		Provider: s.GetProviderName(),
+		Metadata: map[string]string{"status_code": strconv.Itoa(response.StatusCode)},
+	}
+	if ids := response.Headers["X-Message-Id"]; len(ids) > 0 {
+		result.ProviderMessageID = ids[0]
 	}
 
-	return nil
+	return result, nil
 }
 
 func (s *SendGridSender) GetProviderName() string {
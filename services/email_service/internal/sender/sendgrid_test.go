@@ -0,0 +1,41 @@
+package sender
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+func TestBulkMessagePersonalizations(t *testing.T) {
+	s := &SendGridSender{}
+	email := &BulkEmail{
+		Email: *NewEmail("", "Hi {{name}}", "<p>Hi {{name}}</p>", ""),
+		Recipients: []BulkRecipient{
+			{To: "ade@example.com", NotificationID: "notif-1", Substitutions: map[string]string{"{{name}}": "Ade"}},
+			{To: "bisi@example.com", NotificationID: "notif-2", Substitutions: map[string]string{"{{name}}": "Bisi"}, Headers: map[string]string{"List-Unsubscribe": "<https://example.com/u>"}},
+		},
+	}
+
+	var body struct {
+		Subject          string `json:"subject"`
+		Personalizations []struct {
+			To            []struct{ Email string } `json:"to"`
+			Substitutions map[string]string        `json:"substitutions"`
+			Headers       map[string]string        `json:"headers"`
+			CustomArgs    map[string]string        `json:"custom_args"`
+		} `json:"personalizations"`
+	}
+	if err := json.Unmarshal(mail.GetRequestBody(newBulkMessage(s.newMessage(&email.Email), email.Recipients)), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Subject != "Hi {{name}}" || len(body.Personalizations) != 2 {
+		t.Fatalf("body = %+v", body)
+	}
+	second := body.Personalizations[1]
+	if second.To[0].Email != "bisi@example.com" || second.Substitutions["{{name}}"] != "Bisi" || second.Headers["List-Unsubscribe"] == "" ||
+		second.CustomArgs["notification_id"] != "notif-2" {
+		t.Errorf("personalization = %+v", second)
+	}
+}
@@ -1,62 +1,314 @@
 package sender
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
 )
 
+const smtpDialTimeout = 10 * time.Second
+
+var smtpQueuedAsRe = regexp.MustCompile(`(?i)(?:queued as |\bid=)([^\s;,]+)`)
+
 type SMTPSender struct {
-	config config.SMTPConfig
-	auth   smtp.Auth
+	config    config.SMTPConfig
+	auth      smtp.Auth
+	tlsMode   string
+	tlsConfig *tls.Config
+	dkim      *DKIMSigner
+	pool      *smtpPool
 }
 
 func NewSMTPSender(cfg config.SMTPConfig) (*SMTPSender, error) {
-	if cfg.Username == "" || cfg.Password == "" {
-		return nil, fmt.Errorf("SMTP username and password are required")
+	auth, err := newSMTPAuth(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	auth := smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	tlsMode, err := resolveTLSMode(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	return &SMTPSender{
-		config: cfg,
-		auth:   auth,
-	}, nil
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dkim, err := NewDKIMSigner(cfg.DKIM)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SMTPSender{
+		config:    cfg,
+		auth:      auth,
+		tlsMode:   tlsMode,
+		tlsConfig: tlsConfig,
+		dkim:      dkim,
+	}
+	s.pool = newSMTPPool(cfg.PoolSize, time.Duration(cfg.IdleTimeout)*time.Second, s.dial)
+
+	return s, nil
 }
 
-func (s *SMTPSender) Send(to, subject, body string) error {
+func (s *SMTPSender) Send(ctx context.Context, email *Email) (*SendResult, error) {
+	// Without a sender identity, mail goes out as the authenticated user
+	from := email.From
+	if from.Address == "" {
+		from = Identity{Address: s.config.Username}
+	}
+
 	// Build MIME message
-	message := s.buildMessage(s.config.Username, to, subject, body)
+	message, messageID, err := buildMessage(from.String(), email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email message: %w", err)
+	}
 
-	// Send email
-	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-	err := smtp.SendMail(addr, s.auth, s.config.Username, []string{to}, []byte(message))
+	// Sign last so the signature covers the message exactly as transmitted
+	if s.dkim != nil {
+		if message, err = s.dkim.Sign(message); err != nil {
+			return nil, err
+		}
+	}
 
+	// Bcc recipients are only part of the envelope, never the headers
+	reply, err := s.deliver(ctx, from.Address, envelopeAddresses(email.Recipients()), message)
 	if err != nil {
-		return fmt.Errorf("failed to send email via SMTP: %w", err)
+		return nil, fmt.Errorf("failed to send email via SMTP: %w", err)
 	}
 
-	return nil
+	return &SendResult{
+		Provider:          s.GetProviderName(),
+		ProviderMessageID: parseSMTPQueueID(reply),
+		MessageID:         messageID,
+		Metadata:          map[string]string{"smtp_reply": reply},
+	}, nil
 }
 
 func (s *SMTPSender) GetProviderName() string {
 	return "smtp"
 }
 
-func (s *SMTPSender) buildMessage(from, to, subject, body string) string {
-	headers := make(map[string]string)
-	headers["From"] = from
-	headers["To"] = to
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
+// Close ends the pooled SMTP sessions
+func (s *SMTPSender) Close() error {
+	s.pool.Close()
+	return nil
+}
+
+// SMTPReplyError is a reply from the SMTP server rejecting a transaction
+type SMTPReplyError struct {
+	Err error // wraps the *textproto.Error with the reply code
+}
+
+func (e *SMTPReplyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SMTPReplyError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent reports whether the reply is a 5xx, which the server will give
+// again however often the message is retried
+func (e *SMTPReplyError) Permanent() bool {
+	var reply *textproto.Error
+	return errors.As(e.Err, &reply) && reply.Code >= 500 && reply.Code < 600
+}
+
+// deliver sends the message over a pooled session. A reused session that
+// fails at the connection level was most likely dropped by the server, so the
+// message is retried on another session while ctx allows.
+func (s *SMTPSender) deliver(ctx context.Context, from string, recipients []string, message []byte) (string, error) {
+	for {
+		session, err := s.pool.get(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		reply, err := session.send(ctx, from, recipients, message)
+		if err == nil {
+			s.pool.put(session)
+			return reply, nil
+		}
+
+		if isSMTPReply(err) {
+			// The server rejected the transaction but the session is fine
+			s.pool.put(session)
+			return "", &SMTPReplyError{Err: err}
+		}
+
+		s.pool.discard(session)
+		if !session.reused || ctx.Err() != nil {
+			return "", err
+		}
+	}
+}
+
+// dial opens a new SMTP session, negotiating TLS according to the configured
+// mode and authenticating unless auth is disabled. ctx bounds the whole
+// handshake, not just the TCP connect.
+func (s *SMTPSender) dial(ctx context.Context) (session *smtpSession, err error) {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+
+	var conn net.Conn
+	if s.tlsMode == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// An interrupted handshake must not surface as an authentication failure,
+	// which would be treated as permanent
+	stop := watchConn(ctx, conn)
+	defer func() {
+		if stop() {
+			return
+		}
+		if err == nil {
+			session.client.Close()
+		}
+		session, err = nil, interruptErr(ctx)
+	}()
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := s.startTLS(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if s.auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: server does not support AUTH")
+		}
+		if err := client.Auth(s.auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	return &smtpSession{conn: conn, client: client}, nil
+}
+
+func (s *SMTPSender) startTLS(client *smtp.Client) error {
+	switch s.tlsMode {
+	case TLSModeStartTLSRequired, TLSModeStartTLSOpportunistic:
+		ok, _ := client.Extension("STARTTLS")
+		if !ok {
+			if s.tlsMode == TLSModeStartTLSRequired {
+				return fmt.Errorf("SMTP server %s does not support STARTTLS", s.config.Host)
+			}
+			return nil
+		}
+		return client.StartTLS(s.tlsConfig)
+	default:
+		// Implicit TLS is already encrypted and "none" stays in plain text
+		return nil
+	}
+}
+
+// buildMessage renders the complete RFC 5322 message for an email and
+// returns it with its generated Message-ID. Headers are written in a fixed
+// order, non-ASCII values are RFC 2047 encoded and long lines are folded.
+func buildMessage(from string, email *Email) ([]byte, string, error) {
+	body, err := buildBody(email)
+	if err != nil {
+		return nil, "", err
+	}
+	messageID := newMessageID(from)
+
+	var buf bytes.Buffer
+	writeHeader(&buf, "Date", time.Now().Format(time.RFC1123Z))
+	writeHeader(&buf, "From", formatAddressList([]string{from}))
+	writeHeader(&buf, "To", formatAddressList([]string{email.To}))
+	if len(email.Cc) > 0 {
+		writeHeader(&buf, "Cc", formatAddressList(email.Cc))
+	}
+	if email.ReplyTo != "" {
+		writeHeader(&buf, "Reply-To", formatAddressList([]string{email.ReplyTo}))
+	}
+	writeHeader(&buf, "Subject", encodeHeaderValue(email.Subject))
+	writeHeader(&buf, "Message-ID", messageID)
+
+	// Custom headers are allow-listed and cannot override the ones above
+	keys := make([]string, 0, len(email.Headers))
+	for key := range email.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeHeader(&buf, key, encodeHeaderValue(email.Headers[key]))
+	}
+
+	writeHeader(&buf, "MIME-Version", "1.0")
+	writeHeader(&buf, "Content-Type", body.header.Get("Content-Type"))
+	if encoding := body.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader(&buf, "Content-Transfer-Encoding", encoding)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+
+	return buf.Bytes(), messageID, nil
+}
 
-	message := ""
-	for key, value := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", key, value)
+// envelopeAddresses strips display names, which RCPT TO does not accept
+func envelopeAddresses(addresses []string) []string {
+	envelope := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if parsed, err := mail.ParseAddress(address); err == nil {
+			address = parsed.Address
+		}
+		envelope = append(envelope, address)
 	}
-	message += "\r\n" + body
+	return envelope
+}
 
-	return message
+// parseSMTPQueueID extracts the queue ID from a final DATA reply. Postfix and
+// most relays say "queued as <id>" and Exim "id=<id>"; others, such as Gmail
+// and SES, put the ID near the end of the reply.
+func parseSMTPQueueID(reply string) string {
+	if match := smtpQueuedAsRe.FindStringSubmatch(reply); match != nil {
+		return match[1]
+	}
+	fields := strings.Fields(reply)
+	if len(fields) < 3 {
+		return ""
+	}
+	switch last := fields[len(fields)-1]; last {
+	case "gsmtp":
+		// "250 2.0.0 OK  1700000000 a1b2c3 - gsmtp"
+		if len(fields) >= 4 {
+			return fields[len(fields)-3]
+		}
+		return ""
+	default:
+		// "250 Ok 0100018b..." is the only shape left that carries an ID
+		if len(fields) == 3 && strings.EqualFold(fields[1], "ok") {
+			return last
+		}
+		return ""
+	}
 }
@@ -0,0 +1,178 @@
+package sender
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// SMTP TLS modes
+const (
+	TLSModeNone                  = "none"
+	TLSModeStartTLSRequired      = "starttls-required"
+	TLSModeStartTLSOpportunistic = "starttls-opportunistic"
+	TLSModeImplicit              = "implicit"
+)
+
+// SMTP auth mechanisms
+const (
+	AuthNone    = "none"
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthXOAUTH2 = "xoauth2"
+)
+
+// resolveTLSMode returns the configured TLS mode, defaulting to implicit TLS
+// on port 465 and opportunistic STARTTLS elsewhere
+func resolveTLSMode(cfg config.SMTPConfig) (string, error) {
+	mode := strings.ToLower(cfg.TLSMode)
+	switch mode {
+	case "":
+		if cfg.Port == 465 {
+			return TLSModeImplicit, nil
+		}
+		return TLSModeStartTLSOpportunistic, nil
+	case TLSModeNone, TLSModeStartTLSRequired, TLSModeStartTLSOpportunistic, TLSModeImplicit:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown SMTP TLS mode: %s", cfg.TLSMode)
+	}
+}
+
+// newTLSConfig builds the client TLS configuration, loading the custom root
+// CA and client certificate when configured
+func newTLSConfig(cfg config.SMTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SMTP CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in SMTP CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SMTP client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newSMTPAuth creates the auth mechanism selected in the config. A nil Auth
+// means the session is not authenticated.
+func newSMTPAuth(cfg config.SMTPConfig) (smtp.Auth, error) {
+	mechanism := strings.ToLower(cfg.AuthMechanism)
+	if mechanism == "" {
+		mechanism = AuthPlain
+	}
+
+	if mechanism == AuthNone {
+		return nil, nil
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("SMTP username is required for %s auth", mechanism)
+	}
+
+	switch mechanism {
+	case AuthPlain, AuthLogin, AuthCRAMMD5:
+		if cfg.Password == "" {
+			return nil, fmt.Errorf("SMTP username and password are required")
+		}
+	case AuthXOAUTH2:
+		if cfg.OAuthToken == "" {
+			return nil, fmt.Errorf("SMTP OAuth token is required for xoauth2 auth")
+		}
+	}
+
+	switch mechanism {
+	case AuthPlain:
+		return smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host), nil
+	case AuthLogin:
+		return &loginAuth{username: cfg.Username, password: cfg.Password, host: cfg.Host}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(cfg.Username, cfg.Password), nil
+	case AuthXOAUTH2:
+		return &xoauth2Auth{username: cfg.Username, token: cfg.OAuthToken, host: cfg.Host}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMTP auth mechanism: %s", cfg.AuthMechanism)
+	}
+}
+
+// requireSecureChannel mirrors smtp.PlainAuth: credentials are only sent in
+// the clear over TLS or to localhost
+func requireSecureChannel(server *smtp.ServerInfo, host string) error {
+	if server.Name != host {
+		return errors.New("wrong host name")
+	}
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return errors.New("unencrypted connection")
+	}
+	return nil
+}
+
+// loginAuth implements the LOGIN mechanism used by older Exchange and Office 365 relays
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := requireSecureChannel(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:", "username":
+		return []byte(a.username), nil
+	case "password:", "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge: %q", fromServer)
+	}
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism used by Gmail and Office 365
+type xoauth2Auth struct {
+	username, token, host string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := requireSecureChannel(server, a.host); err != nil {
+		return "", nil, err
+	}
+	resp := fmt.Sprintf("user=%s\x01auth=Bearer %s\x01\x01", a.username, a.token)
+	return "XOAUTH2", []byte(resp), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// The server sends a JSON error description; an empty reply ends the exchange
+		return []byte{}, nil
+	}
+	return nil, nil
+}
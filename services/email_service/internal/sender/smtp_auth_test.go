@@ -0,0 +1,264 @@
+package sender
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+)
+
+// newTestCertificate creates a self-signed certificate for 127.0.0.1 and
+// returns the server TLS config with the path of its PEM, to trust as CA
+func newTestCertificate(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, caFile
+}
+
+// newConfiguredSMTPSender creates a sender for srv from cfg
+func newConfiguredSMTPSender(t *testing.T, srv *fakeSMTPServer, cfg config.SMTPConfig) *SMTPSender {
+	t.Helper()
+
+	cfg.Host, cfg.Port, cfg.PoolSize = "127.0.0.1", srv.port(), 1
+	if cfg.Username == "" {
+		cfg.Username, cfg.Password = "user", "secret"
+	}
+	s, err := NewSMTPSender(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestResolveTLSMode(t *testing.T) {
+	cases := []struct {
+		mode string
+		port int
+		want string
+	}{
+		{"", 465, TLSModeImplicit},
+		{"", 587, TLSModeStartTLSOpportunistic},
+		{"", 25, TLSModeStartTLSOpportunistic},
+		{"STARTTLS-Required", 587, TLSModeStartTLSRequired},
+		{"starttls-opportunistic", 465, TLSModeStartTLSOpportunistic},
+		{"none", 465, TLSModeNone},
+		{"implicit", 2465, TLSModeImplicit},
+	}
+	for _, tc := range cases {
+		got, err := resolveTLSMode(config.SMTPConfig{TLSMode: tc.mode, Port: tc.port})
+		if err != nil || got != tc.want {
+			t.Errorf("resolveTLSMode(%q, %d) = %q, %v, want %q", tc.mode, tc.port, got, err, tc.want)
+		}
+	}
+
+	if _, err := resolveTLSMode(config.SMTPConfig{TLSMode: "ssl"}); err == nil {
+		t.Error("unknown TLS mode accepted")
+	}
+}
+
+func TestSMTPStartTLS(t *testing.T) {
+	serverTLS, caFile := newTestCertificate(t)
+
+	cases := []struct {
+		name     string
+		mode     string
+		offerTLS bool
+		wantErr  string
+	}{
+		{"required and offered", TLSModeStartTLSRequired, true, ""},
+		{"required but not offered", TLSModeStartTLSRequired, false, "does not support STARTTLS"},
+		{"opportunistic and offered", TLSModeStartTLSOpportunistic, true, ""},
+		{"opportunistic falls back to plain text", TLSModeStartTLSOpportunistic, false, ""},
+		{"none ignores the offer", TLSModeNone, true, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var srv *fakeSMTPServer
+			if tc.offerTLS {
+				srv = newFakeSMTPServerTLS(t, serverTLS)
+			} else {
+				srv = newFakeSMTPServer(t)
+			}
+			s := newConfiguredSMTPSender(t, srv, config.SMTPConfig{TLSMode: tc.mode, CAFile: caFile})
+
+			_, err := s.Send(context.Background(), testEmail())
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Send() = %v, want %q", err, tc.wantErr)
+				}
+				if srv.messages.Load() != 0 {
+					t.Error("message sent without TLS")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantUpgrades := int64(0)
+			if tc.offerTLS && tc.mode != TLSModeNone {
+				wantUpgrades = 1
+			}
+			if got := srv.tlsUpgrades.Load(); got != wantUpgrades {
+				t.Errorf("TLS upgrades = %d, want %d", got, wantUpgrades)
+			}
+		})
+	}
+}
+
+func TestSMTPStartTLSVerifiesCertificate(t *testing.T) {
+	serverTLS, _ := newTestCertificate(t)
+	srv := newFakeSMTPServerTLS(t, serverTLS)
+
+	// Without the custom CA the self-signed certificate is rejected
+	s := newConfiguredSMTPSender(t, srv, config.SMTPConfig{TLSMode: TLSModeStartTLSRequired})
+	if _, err := s.Send(context.Background(), testEmail()); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("Send() = %v, want a certificate error", err)
+	}
+}
+
+func TestSMTPAuthMechanisms(t *testing.T) {
+	cases := []struct {
+		mechanism string
+		cfg       config.SMTPConfig
+		want      []string
+	}{
+		{AuthPlain, config.SMTPConfig{}, []string{"PLAIN \x00user\x00secret"}},
+		{AuthLogin, config.SMTPConfig{}, []string{"LOGIN user secret"}},
+		{AuthXOAUTH2, config.SMTPConfig{Username: "user@example.com", OAuthToken: "ya29.token"}, []string{"XOAUTH2 user=user@example.com\x01auth=Bearer ya29.token\x01\x01"}},
+		{AuthNone, config.SMTPConfig{}, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.mechanism, func(t *testing.T) {
+			srv := newFakeSMTPServer(t)
+			tc.cfg.AuthMechanism = tc.mechanism
+			s := newConfiguredSMTPSender(t, srv, tc.cfg)
+
+			if _, err := s.Send(context.Background(), testEmail()); err != nil {
+				t.Fatal(err)
+			}
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if !reflect.DeepEqual(srv.auths, tc.want) {
+				t.Errorf("AUTH exchanges = %q, want %q", srv.auths, tc.want)
+			}
+		})
+	}
+
+	// CRAM-MD5 answers the server's challenge with an HMAC instead of the password
+	srv := newFakeSMTPServer(t)
+	s := newConfiguredSMTPSender(t, srv, config.SMTPConfig{AuthMechanism: AuthCRAMMD5})
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.auths) != 1 || !strings.HasPrefix(srv.auths[0], "CRAM-MD5 user ") || strings.Contains(srv.auths[0], "secret") {
+		t.Errorf("CRAM-MD5 exchange = %q", srv.auths)
+	}
+}
+
+func TestLoginAuthExchange(t *testing.T) {
+	auth := &loginAuth{username: "user", password: "secret", host: "smtp.example.com"}
+
+	mechanism, initial, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	if mechanism != "LOGIN" || initial != nil || err != nil {
+		t.Fatalf("Start() = %q, %q, %v", mechanism, initial, err)
+	}
+	for challenge, want := range map[string]string{"Username:": "user", "password": "secret"} {
+		if got, err := auth.Next([]byte(challenge), true); string(got) != want || err != nil {
+			t.Errorf("Next(%q) = %q, %v, want %q", challenge, got, err, want)
+		}
+	}
+	if _, err := auth.Next([]byte("Token:"), true); err == nil {
+		t.Error("unexpected challenge accepted")
+	}
+	if got, err := auth.Next(nil, false); got != nil || err != nil {
+		t.Errorf("final Next() = %q, %v", got, err)
+	}
+
+	// Credentials never go to another host or over plain text
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "evil.example.com", TLS: true}); err == nil {
+		t.Error("Start() accepted the wrong host")
+	}
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com"}); err == nil {
+		t.Error("Start() accepted an unencrypted connection")
+	}
+}
+
+func TestXOAUTH2AuthExchange(t *testing.T) {
+	auth := &xoauth2Auth{username: "user@example.com", token: "ya29.token", host: "smtp.example.com"}
+
+	mechanism, initial, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	if err != nil || mechanism != "XOAUTH2" || string(initial) != "user=user@example.com\x01auth=Bearer ya29.token\x01\x01" {
+		t.Fatalf("Start() = %q, %q, %v", mechanism, initial, err)
+	}
+
+	// A rejected token comes back as a JSON challenge, answered with an empty line
+	if got, err := auth.Next([]byte(`{"status":"401"}`), true); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("Next(error) = %q, %v, want an empty response", got, err)
+	}
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com"}); err == nil {
+		t.Error("Start() accepted an unencrypted connection")
+	}
+}
+
+func TestNewSMTPAuthValidatesConfig(t *testing.T) {
+	for _, cfg := range []config.SMTPConfig{
+		{AuthMechanism: "plain", Password: "secret"},
+		{AuthMechanism: "login", Username: "user"},
+		{AuthMechanism: "xoauth2", Username: "user"},
+		{AuthMechanism: "gssapi", Username: "user", Password: "secret"},
+	} {
+		if _, err := newSMTPAuth(cfg); err == nil {
+			t.Errorf("newSMTPAuth(%+v) succeeded", cfg)
+		}
+	}
+
+	if auth, err := newSMTPAuth(config.SMTPConfig{AuthMechanism: "none"}); auth != nil || err != nil {
+		t.Errorf("none = %v, %v, want no auth", auth, err)
+	}
+}
@@ -0,0 +1,231 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errPoolClosed = errors.New("SMTP connection pool is closed")
+
+// smtpPool keeps a bounded set of authenticated SMTP sessions open so each
+// message does not pay for dialing, TLS and AUTH again
+type smtpPool struct {
+	dial        func(ctx context.Context) (*smtpSession, error)
+	idleTimeout time.Duration
+
+	slots  chan struct{} // one token per open or dialing session
+	mu     sync.Mutex
+	idle   []*smtpSession
+	closed bool
+}
+
+type smtpSession struct {
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+	reused   bool
+	broken   bool // interrupted mid-command, so the protocol state is unknown
+}
+
+func newSMTPPool(size int, idleTimeout time.Duration, dial func(ctx context.Context) (*smtpSession, error)) *smtpPool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &smtpPool{
+		dial:        dial,
+		idleTimeout: idleTimeout,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// get returns an idle session, or dials a new one when none is available.
+// It blocks while the pool is at capacity, until ctx is done.
+func (p *smtpPool) get(ctx context.Context) (*smtpSession, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, errPoolClosed
+		}
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		session := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		// Sessions idle for too long have likely been dropped by the server
+		if p.idleTimeout > 0 && time.Since(session.lastUsed) > p.idleTimeout {
+			session.client.Close()
+			continue
+		}
+
+		// RSET clears any previous transaction and doubles as a liveness probe
+		stop := watchConn(ctx, session.conn)
+		err := session.client.Reset()
+		if !stop() || err != nil {
+			session.client.Close()
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				<-p.slots
+				return nil, ctxErr
+			}
+			continue
+		}
+
+		session.reused = true
+		return session, nil
+	}
+
+	session, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// put returns a healthy session to the pool
+func (p *smtpPool) put(session *smtpSession) {
+	if session.broken {
+		p.discard(session)
+		return
+	}
+	session.lastUsed = time.Now()
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		session.client.Quit()
+	} else {
+		p.idle = append(p.idle, session)
+		p.mu.Unlock()
+	}
+
+	<-p.slots
+}
+
+// discard closes a broken session and frees its slot
+func (p *smtpPool) discard(session *smtpSession) {
+	session.client.Close()
+	<-p.slots
+}
+
+// Close ends every idle session. Sessions in use are closed when returned.
+func (p *smtpPool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, session := range idle {
+		session.client.Quit()
+	}
+}
+
+// send runs one mail transaction on the session and returns the server's
+// final reply text. If ctx ends mid-transaction the connection is interrupted
+// and the session is marked broken.
+func (s *smtpSession) send(ctx context.Context, from string, recipients []string, message []byte) (reply string, err error) {
+	stop := watchConn(ctx, s.conn)
+	defer func() {
+		if stop() {
+			return
+		}
+		s.broken = true
+		// A completed transaction still counts; otherwise report why it stopped
+		if err != nil {
+			err = interruptErr(ctx)
+		}
+	}()
+
+	if err := s.client.Mail(from); err != nil {
+		return "", err
+	}
+	for _, rcpt := range recipients {
+		if err := s.client.Rcpt(rcpt); err != nil {
+			return "", err
+		}
+	}
+
+	// DATA is driven by hand because smtp.Client discards the final reply,
+	// which is where servers report the queue ID
+	id, err := s.client.Text.Cmd("DATA")
+	if err != nil {
+		return "", err
+	}
+	s.client.Text.StartResponse(id)
+	_, _, err = s.client.Text.ReadResponse(354)
+	s.client.Text.EndResponse(id)
+	if err != nil {
+		return "", err
+	}
+
+	w := s.client.Text.DotWriter()
+	if _, err := w.Write(message); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	code, msg, err := s.client.Text.ReadResponse(250)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(code) + " " + msg, nil
+}
+
+// watchConn applies the deadline and cancellation of ctx to conn until the
+// returned stop function is called. stop reports false if ctx interrupted the
+// connection, which leaves it unusable.
+func watchConn(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stopInterrupt := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+
+	return func() bool {
+		if !stopInterrupt() {
+			return false
+		}
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return false
+		}
+		conn.SetDeadline(time.Time{})
+		return true
+	}
+}
+
+// interruptErr returns why ctx interrupted a connection. The deadline may
+// pass a moment before ctx itself reports it.
+func interruptErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return context.DeadlineExceeded
+}
+
+// isSMTPReply reports whether err is a reply from the server, in which case
+// the connection itself is still usable
+func isSMTPReply(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code != 421
+}
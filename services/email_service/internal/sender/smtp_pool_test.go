@@ -0,0 +1,462 @@
+package sender
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/config"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/retry"
+)
+
+// fakeSMTPServer is a minimal local SMTP server that accepts every message
+type fakeSMTPServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config // offers STARTTLS when set
+	connections atomic.Int64
+	messages    atomic.Int64
+	tlsUpgrades atomic.Int64
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+
+	// received holds every accepted message with its envelope and auths the
+	// decoded AUTH exchanges, e.g. "LOGIN user secret"; both guarded by mu
+	received []receivedMessage
+	auths    []string
+}
+
+// receivedMessage is a message as the fake server saw it
+type receivedMessage struct {
+	recipients []string // RCPT TO addresses
+	data       []byte
+}
+
+func newFakeSMTPServer(t testing.TB) *fakeSMTPServer {
+	t.Helper()
+	return newFakeSMTPServerTLS(t, nil)
+}
+
+// newFakeSMTPServerTLS starts a fake server that offers STARTTLS with
+// tlsConfig, or no TLS when it is nil
+func newFakeSMTPServerTLS(t testing.TB, tlsConfig *tls.Config) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	srv := &fakeSMTPServer{listener: listener, tlsConfig: tlsConfig, conns: make(map[net.Conn]bool)}
+	go srv.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return srv
+}
+
+func (f *fakeSMTPServer) port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTPServer) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.connections.Add(1)
+		go f.handle(conn)
+	}
+}
+
+// dropAll closes every open connection, simulating a server-side disconnect
+func (f *fakeSMTPServer) dropAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeSMTPServer) handle(conn net.Conn) {
+	f.mu.Lock()
+	f.conns[conn] = true
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+
+	tp := textproto.NewConn(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			tp.PrintfLine("%s", line)
+		}
+	}
+
+	// readBase64 reads a client line of an AUTH exchange
+	readBase64 := func() string {
+		line, _ := tp.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		return string(decoded)
+	}
+
+	reply("220 fake ESMTP")
+	var recipients []string
+	secure := false
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-fake")
+			if f.tlsConfig != nil && !secure {
+				reply("250-STARTTLS")
+			}
+			reply("250-AUTH PLAIN LOGIN CRAM-MD5 XOAUTH2", "250 8BITMIME")
+		case "STARTTLS":
+			reply("220 2.0.0 Ready to start TLS")
+			tlsConn := tls.Server(conn, f.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			tp, secure = textproto.NewConn(tlsConn), true
+			f.tlsUpgrades.Add(1)
+		case "AUTH":
+			fields := strings.Fields(line)
+			exchange := strings.ToUpper(fields[1])
+			switch exchange {
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				exchange += " " + readBase64()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				exchange += " " + readBase64()
+			case "CRAM-MD5":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("<1.2@fake>")))
+				exchange += " " + readBase64()
+			default:
+				if len(fields) > 2 {
+					initial, _ := base64.StdEncoding.DecodeString(fields[2])
+					exchange += " " + string(initial)
+				}
+			}
+			f.mu.Lock()
+			f.auths = append(f.auths, exchange)
+			f.mu.Unlock()
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL", "RSET":
+			recipients = nil
+			reply("250 2.0.0 OK")
+		case "NOOP":
+			reply("250 2.0.0 OK")
+		case "RCPT":
+			if strings.Contains(line, "stall") {
+				// Never answer, like a server that hangs mid-transaction
+				continue
+			}
+			if strings.Contains(line, "full") {
+				reply("452 4.2.2 Mailbox full")
+				continue
+			}
+			if strings.Contains(line, "reject") {
+				reply("550 5.1.1 No such user")
+			} else {
+				_, to, _ := strings.Cut(line, ":")
+				recipients = append(recipients, strings.Trim(to, "<> "))
+				reply("250 2.1.5 OK")
+			}
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.received = append(f.received, receivedMessage{recipients: recipients, data: data})
+			f.mu.Unlock()
+			f.messages.Add(1)
+			reply("250 2.0.0 OK queued as " + strconv.FormatInt(f.messages.Load(), 10))
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Command not recognized")
+		}
+	}
+}
+
+// lastMessage returns the most recently accepted message
+func (f *fakeSMTPServer) lastMessage(t testing.TB) receivedMessage {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.received) == 0 {
+		t.Fatal("no message received")
+	}
+	return f.received[len(f.received)-1]
+}
+
+func newTestSMTPSender(t testing.TB, srv *fakeSMTPServer, poolSize int) *SMTPSender {
+	t.Helper()
+
+	s, err := NewSMTPSender(config.SMTPConfig{
+		Host:        "127.0.0.1",
+		Port:        srv.port(),
+		Username:    "user",
+		Password:    "secret",
+		PoolSize:    poolSize,
+		IdleTimeout: 30,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sender: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func testEmail() *Email {
+	return NewEmail("user@example.com", "Welcome", "<p>Hello <b>there</b></p>", "")
+}
+
+func TestSMTPSenderReusesSessions(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 2)
+
+	for i := 0; i < 5; i++ {
+		if _, err := s.Send(context.Background(), testEmail()); err != nil {
+			t.Fatalf("send %d failed: %v", i, err)
+		}
+	}
+
+	if got := srv.messages.Load(); got != 5 {
+		t.Errorf("messages = %d, want 5", got)
+	}
+	if got := srv.connections.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+}
+
+func TestSMTPSenderReturnsQueueID(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+
+	result, err := s.Send(context.Background(), testEmail())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProviderMessageID != "1" {
+		t.Errorf("ProviderMessageID = %q, want 1", result.ProviderMessageID)
+	}
+	if result.MessageID == "" || result.Metadata["smtp_reply"] != "250 2.0.0 OK queued as 1" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestParseSMTPQueueID(t *testing.T) {
+	cases := map[string]string{
+		"250 2.0.0 Ok: queued as 4BqKXN2fz5z9sWj":                      "4BqKXN2fz5z9sWj",
+		"250 OK id=1qWx3Z-0004Zb-2T":                                   "1qWx3Z-0004Zb-2T",
+		"250 2.0.0 OK  1700000000 a1b2c3si123.45 - gsmtp":              "a1b2c3si123.45",
+		"250 Ok 0100018b9f6e3a1c-6b1f0c2a-0000-0000-0000-000000000000": "0100018b9f6e3a1c-6b1f0c2a-0000-0000-0000-000000000000",
+		"250 2.0.0 Message accepted for delivery":                      "",
+	}
+
+	for reply, want := range cases {
+		if got := parseSMTPQueueID(reply); got != want {
+			t.Errorf("parseSMTPQueueID(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestSMTPSenderReconnectsAfterServerDisconnect(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("first send failed: %v", err)
+	}
+
+	srv.dropAll()
+
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("send after disconnect failed: %v", err)
+	}
+	if got := srv.connections.Load(); got != 2 {
+		t.Errorf("connections = %d, want 2", got)
+	}
+}
+
+func TestSMTPSenderDiscardsIdleSessions(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+	s.pool.idleTimeout = time.Millisecond
+
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("first send failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("second send failed: %v", err)
+	}
+
+	if got := srv.connections.Load(); got != 2 {
+		t.Errorf("connections = %d, want 2", got)
+	}
+}
+
+func TestSMTPSenderKeepsSessionAfterRejectedRecipient(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+
+	rejected := NewEmail("reject@example.com", "Welcome", "<p>Hello</p>", "")
+	if _, err := s.Send(context.Background(), rejected); err == nil {
+		t.Fatal("expected rejected recipient to fail")
+	}
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("send after rejection failed: %v", err)
+	}
+
+	if got := srv.connections.Load(); got != 1 {
+		t.Errorf("connections = %d, want 1", got)
+	}
+}
+
+func TestSMTPSenderClassifiesReplies(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+	retryable := retry.NewHandler(3, 1).IsRetryable
+
+	_, err := s.Send(context.Background(), NewEmail("reject@example.com", "Welcome", "<p>Hello</p>", ""))
+	var reply *SMTPReplyError
+	if !errors.As(err, &reply) || !reply.Permanent() {
+		t.Fatalf("550 reply: err = %v, want a permanent SMTPReplyError", err)
+	}
+	if retryable(err) {
+		t.Error("550 reply is retried")
+	}
+
+	_, err = s.Send(context.Background(), NewEmail("full@example.com", "Welcome", "<p>Hello</p>", ""))
+	if !errors.As(err, &reply) || reply.Permanent() {
+		t.Fatalf("452 reply: err = %v, want a temporary SMTPReplyError", err)
+	}
+	if !retryable(err) {
+		t.Error("452 reply is not retried")
+	}
+}
+
+func TestSMTPSenderStopsAtDeadline(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stalled := NewEmail("stall@example.com", "Welcome", "<p>Hello</p>", "")
+	_, err := s.Send(ctx, stalled)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("send returned after %v, want close to the 100ms deadline", elapsed)
+	}
+
+	// The interrupted session is discarded and its slot freed
+	if _, err := s.Send(context.Background(), testEmail()); err != nil {
+		t.Fatalf("send after deadline failed: %v", err)
+	}
+	if got := srv.connections.Load(); got != 2 {
+		t.Errorf("connections = %d, want 2", got)
+	}
+}
+
+func TestSMTPSenderCancelWhilePoolFull(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	s := newTestSMTPSender(t, srv, 1)
+
+	// Hold the only slot with a send that never completes
+	holdCtx, release := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Send(holdCtx, NewEmail("stall@example.com", "Welcome", "<p>Hello</p>", ""))
+	}()
+	defer func() {
+		release()
+		<-done
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := s.Send(ctx, testEmail()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want canceled", err)
+	}
+}
+
+func BenchmarkSMTPSendMail(b *testing.B) {
+	srv := newFakeSMTPServer(b)
+	s := newTestSMTPSender(b, srv, 1)
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.port()))
+	email := testEmail()
+
+	message, _, err := buildMessage("user", email)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := smtp.SendMail(addr, s.auth, "user", email.Recipients(), message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSMTPSenderPooled(b *testing.B) {
+	srv := newFakeSMTPServer(b)
+	s := newTestSMTPSender(b, srv, 1)
+	email := testEmail()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Send(context.Background(), email); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSMTPSenderPooledParallel(b *testing.B) {
+	srv := newFakeSMTPServer(b)
+	s := newTestSMTPSender(b, srv, 10)
+	email := testEmail()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.Send(context.Background(), email); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package suppression
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store for development and tests. Entries are
+// lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, address, category string) (*Entry, error) {
+	address, err := Normalize(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{scopeID(address, "")}
+	if category != "" {
+		ids = append(ids, scopeID(address, category))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range ids {
+		if entry, ok := s.entries[id]; ok && !entry.Expired(s.now()) {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, entry Entry) error {
+	address, err := Normalize(entry.Address)
+	if err != nil {
+		return err
+	}
+	entry.Address = address
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = s.now().UTC()
+	}
+
+	s.mu.Lock()
+	s.entries[scopeID(address, entry.Category)] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Remove(ctx context.Context, address, category string) error {
+	address, err := Normalize(address)
+	if err != nil {
+		return err
+	}
+
+	id := scopeID(address, category)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	delete(s.entries, id)
+	if !ok || entry.Expired(s.now()) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Entry, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	entries := make([]Entry, 0, len(s.entries))
+	for id, entry := range s.entries {
+		if entry.Expired(now) {
+			delete(s.entries, id)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	s.mu.Unlock()
+
+	sortEntries(entries)
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2025, 1, 20, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	expires := now.Add(time.Hour)
+	if err := store.Add(ctx, Entry{Address: "Adé <ADE@Example.com>", Reason: ReasonHardBounce, Source: "webhook:sendgrid"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(ctx, Entry{Address: "zoe@example.com", Reason: ReasonManual, ExpiresAt: &expires}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lookups are case-insensitive and ignore display names
+	entry, err := store.Get(ctx, "ade@example.COM", "")
+	if err != nil || entry == nil || entry.Reason != ReasonHardBounce {
+		t.Fatalf("Get = %+v, %v; want hard bounce entry", entry, err)
+	}
+
+	entries, _ := store.List(ctx)
+	if len(entries) != 2 || entries[0].Address != "ade@example.com" {
+		t.Fatalf("List = %+v", entries)
+	}
+
+	// Expired entries no longer suppress
+	now = now.Add(2 * time.Hour)
+	if entry, _ := store.Get(ctx, "zoe@example.com", ""); entry != nil {
+		t.Errorf("expired entry still returned: %+v", entry)
+	}
+	if entries, _ := store.List(ctx); len(entries) != 1 {
+		t.Errorf("List after expiry = %+v", entries)
+	}
+
+	if err := store.Remove(ctx, "ade@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove(ctx, "ade@example.com", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get(ctx, "not an address", ""); err == nil {
+		t.Error("expected invalid address error")
+	}
+}
+
+func TestMemoryStoreCategory(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if err := store.Add(ctx, Entry{Address: "ade@example.com", Category: "newsletter", Reason: ReasonUnsubscribe}); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _ := store.Get(ctx, "ade@example.com", "newsletter"); entry == nil {
+		t.Error("newsletter mail should be suppressed")
+	}
+	if entry, _ := store.Get(ctx, "ade@example.com", "billing"); entry != nil {
+		t.Errorf("billing mail should not be suppressed, got %+v", entry)
+	}
+	if entry, _ := store.Get(ctx, "ade@example.com", ""); entry != nil {
+		t.Errorf("uncategorized mail should not be suppressed, got %+v", entry)
+	}
+
+	// An address-wide entry applies to every category
+	if err := store.Add(ctx, Entry{Address: "ade@example.com", Reason: ReasonComplaint}); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := store.Get(ctx, "ade@example.com", "newsletter"); entry == nil || entry.Reason != ReasonComplaint {
+		t.Errorf("Get = %+v, want complaint entry", entry)
+	}
+}
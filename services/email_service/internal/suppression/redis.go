@@ -0,0 +1,146 @@
+package suppression
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setKey indexes every suppression entry so the list can be enumerated
+// without scanning the keyspace
+const setKey = "email:suppressions"
+
+// RedisStore keeps one key per entry, expiring with the entry, plus a set of
+// all entries for listing
+type RedisStore struct {
+	redis *redis.Client
+}
+
+func NewRedisStore(redis *redis.Client) *RedisStore {
+	return &RedisStore{redis: redis}
+}
+
+func (s *RedisStore) Get(ctx context.Context, address, category string) (*Entry, error) {
+	address, err := Normalize(address)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := s.get(ctx, scopeID(address, ""))
+	if entry != nil || err != nil || category == "" {
+		return entry, err
+	}
+	return s.get(ctx, scopeID(address, category))
+}
+
+func (s *RedisStore) get(ctx context.Context, id string) (*Entry, error) {
+	data, err := s.redis.Get(ctx, entryKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid suppression entry %s: %w", id, err)
+	}
+	return &entry, nil
+}
+
+func (s *RedisStore) Add(ctx context.Context, entry Entry) error {
+	address, err := Normalize(entry.Address)
+	if err != nil {
+		return err
+	}
+	entry.Address = address
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	var ttl time.Duration
+	if entry.ExpiresAt != nil {
+		if ttl = time.Until(*entry.ExpiresAt); ttl <= 0 {
+			return fmt.Errorf("suppression for %s has already expired", address)
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	id := scopeID(address, entry.Category)
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, entryKey(id), data, ttl)
+	pipe.SAdd(ctx, setKey, id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisStore) Remove(ctx context.Context, address, category string) error {
+	address, err := Normalize(address)
+	if err != nil {
+		return err
+	}
+
+	id := scopeID(address, category)
+	pipe := s.redis.TxPipeline()
+	deleted := pipe.Del(ctx, entryKey(id))
+	pipe.SRem(ctx, setKey, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List returns active entries sorted by address. Entries that have expired
+// are pruned from the set along the way.
+func (s *RedisStore) List(ctx context.Context) ([]Entry, error) {
+	ids, err := s.redis.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(ids))
+	var expired []interface{}
+	for _, id := range ids {
+		entry, err := s.get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			expired = append(expired, id)
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+
+	if len(expired) > 0 {
+		s.redis.SRem(ctx, setKey, expired...)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+func entryKey(id string) string {
+	return fmt.Sprintf("email:suppression:%s", id)
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Address != entries[j].Address {
+			return entries[i].Address < entries[j].Address
+		}
+		return entries[i].Category < entries[j].Category
+	})
+}
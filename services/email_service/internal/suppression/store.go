@@ -0,0 +1,98 @@
+package suppression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons an address is suppressed
+const (
+	ReasonHardBounce  = "hard_bounce"
+	ReasonComplaint   = "complaint"
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonManual      = "manual"
+)
+
+// ErrNotFound is returned when removing an address that is not suppressed
+var ErrNotFound = errors.New("address is not suppressed")
+
+// Entry is a suppressed address. An entry with a Category only applies to
+// mail of that category, such as a newsletter the user unsubscribed from.
+type Entry struct {
+	Address   string     `json:"address"`
+	Category  string     `json:"category,omitempty"` // empty suppresses all mail
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source"` // e.g. "webhook:sendgrid" or "admin"
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil never expires
+}
+
+// Expired reports whether the entry no longer applies at t
+func (e *Entry) Expired(t time.Time) bool {
+	return e.ExpiresAt != nil && !t.Before(*e.ExpiresAt)
+}
+
+// Store keeps the suppression list. Addresses are compared in normalized
+// form (see Normalize), and expired entries behave as if they were removed.
+type Store interface {
+	// Get returns the active entry that stops mail of a category to an
+	// address, preferring an address-wide entry, or nil if none applies
+	Get(ctx context.Context, address, category string) (*Entry, error)
+	// Add creates or replaces the entry for an address and category
+	Add(ctx context.Context, entry Entry) error
+	// Remove deletes the entry for an address and category, returning
+	// ErrNotFound if there was none
+	Remove(ctx context.Context, address, category string) error
+	// List returns every active entry
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// NewStore creates the store selected by name: "redis" (the default) or
+// "memory"
+func NewStore(name string, redis *redis.Client) (Store, error) {
+	switch strings.ToLower(name) {
+	case "", "redis":
+		return NewRedisStore(redis), nil
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown suppression store: %s", name)
+	}
+}
+
+// Normalize reduces an address, optionally with a display name, to the
+// lower-cased bare address used as the suppression key
+func Normalize(address string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q: %w", address, err)
+	}
+	return strings.ToLower(parsed.Address), nil
+}
+
+// scopeID identifies the entry for an address and category
+func scopeID(address, category string) string {
+	if category == "" {
+		return address
+	}
+	return category + ":" + address
+}
+
+// SuppressedError is returned when a send is skipped because the recipient
+// is on the suppression list
+type SuppressedError struct {
+	Entry Entry
+}
+
+func (e *SuppressedError) Error() string {
+	if e.Entry.Category != "" {
+		return fmt.Sprintf("address is suppressed: %s for %s (%s)", e.Entry.Address, e.Entry.Category, e.Entry.Reason)
+	}
+	return fmt.Sprintf("address is suppressed: %s (%s)", e.Entry.Address, e.Entry.Reason)
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,6 +15,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrTemplateNotFound is returned when the Template Service has no template
+// for the key
+var ErrTemplateNotFound = errors.New("template not found")
+
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
@@ -59,6 +64,9 @@ func (c *Client) FetchTemplate(ctx context.Context, templateKey string) (*models
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, templateKey)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("template service returned status %d: %s", resp.StatusCode, string(body))
@@ -70,7 +78,7 @@ func (c *Client) FetchTemplate(ctx context.Context, templateKey string) (*models
 	}
 
 	if !response.Success {
-		return nil, fmt.Errorf("template not found: %s", templateKey)
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, templateKey)
 	}
 
 	template := &models.EmailTemplate{
@@ -0,0 +1,152 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit allows Count sends per Window
+type Limit struct {
+	Count  int
+	Window time.Duration
+}
+
+// IsZero reports whether the limit is unset, i.e. unlimited
+func (l Limit) IsZero() bool {
+	return l.Count <= 0 || l.Window <= 0
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Count, l.Window)
+}
+
+// ParseLimit parses "<count>/<window>", e.g. "100/1m" or "20/10s"
+func ParseLimit(value string) (Limit, error) {
+	countPart, windowPart, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: want <count>/<window>", value)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: count must be a positive integer", value)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowPart))
+	if err != nil || window < time.Second {
+		return Limit{}, fmt.Errorf("invalid limit %q: window must be a duration of at least 1s", value)
+	}
+	return Limit{Count: count, Window: window}, nil
+}
+
+// ParseDomainLimits parses "gmail.com=100/1m,yahoo.com=50/1m"
+func ParseDomainLimits(items []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(items))
+	for _, item := range items {
+		domain, value, ok := strings.Cut(item, "=")
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !ok || domain == "" {
+			return nil, fmt.Errorf("invalid domain limit %q: want <domain>=<count>/<window>", item)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("domain %s: %w", domain, err)
+		}
+		limits[domain] = limit
+	}
+	return limits, nil
+}
+
+// reserveScript takes a slot in the domain's current window. It returns 0
+// when a slot was taken, otherwise the milliseconds until the window resets.
+var reserveScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= tonumber(ARGV[1]) then
+	local ttl = redis.call('PTTL', KEYS[1])
+	if ttl <= 0 then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+		ttl = tonumber(ARGV[2])
+	end
+	return ttl
+end
+if redis.call('INCR', KEYS[1]) == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript gives back a slot taken in the domain's current window
+var releaseScript = redis.NewScript(`
+if tonumber(redis.call('GET', KEYS[1]) or '0') > 0 then
+	redis.call('DECR', KEYS[1])
+end
+return 0
+`)
+
+// DomainThrottle rate limits sends per recipient domain. Counters live in
+// Redis, so the limits hold across all consumer replicas.
+type DomainThrottle struct {
+	client       *redis.Client
+	limits       map[string]Limit
+	defaultLimit Limit // zero leaves unlisted domains unlimited
+}
+
+func NewDomainThrottle(client *redis.Client, limits map[string]Limit, defaultLimit Limit) *DomainThrottle {
+	return &DomainThrottle{
+		client:       client,
+		limits:       limits,
+		defaultLimit: defaultLimit,
+	}
+}
+
+// LimitFor returns the limit for a domain, matching parent domains so that
+// a limit for example.com also covers mail.example.com
+func (t *DomainThrottle) LimitFor(domain string) (string, Limit) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for candidate := domain; ; {
+		if limit, ok := t.limits[candidate]; ok {
+			return candidate, limit
+		}
+		_, parent, ok := strings.Cut(candidate, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			break
+		}
+		candidate = parent
+	}
+	return domain, t.defaultLimit
+}
+
+// Reserve takes a send slot for the domain. It returns zero when the send
+// may go ahead, or how long to wait before the domain's window has room.
+func (t *DomainThrottle) Reserve(ctx context.Context, domain string) (time.Duration, error) {
+	key, limit := t.LimitFor(domain)
+	if limit.IsZero() {
+		return 0, nil
+	}
+
+	wait, err := reserveScript.Run(ctx, t.client,
+		[]string{"email:throttle:" + key},
+		limit.Count, limit.Window.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check domain throttle: %w", err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Release gives back a slot taken by Reserve for a send that did not happen,
+// e.g. a bulk batch the provider refused that is resent per recipient
+func (t *DomainThrottle) Release(ctx context.Context, domain string) error {
+	key, limit := t.LimitFor(domain)
+	if limit.IsZero() {
+		return nil
+	}
+
+	if err := releaseScript.Run(ctx, t.client, []string{"email:throttle:" + key}).Err(); err != nil {
+		return fmt.Errorf("failed to release domain throttle slot: %w", err)
+	}
+	return nil
+}
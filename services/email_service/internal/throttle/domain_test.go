@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestParseDomainLimits(t *testing.T) {
+	limits, err := ParseDomainLimits([]string{"Gmail.com=100/1m", " yahoo.com = 20/10s "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := limits["gmail.com"]; got != (Limit{Count: 100, Window: time.Minute}) {
+		t.Errorf("gmail.com = %v", got)
+	}
+	if got := limits["yahoo.com"]; got != (Limit{Count: 20, Window: 10 * time.Second}) {
+		t.Errorf("yahoo.com = %v", got)
+	}
+
+	for _, bad := range []string{"gmail.com", "gmail.com=100", "gmail.com=0/1m", "gmail.com=10/100ms", "=10/1m"} {
+		if _, err := ParseDomainLimits([]string{bad}); err == nil {
+			t.Errorf("ParseDomainLimits(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLimitForMatchesParentDomains(t *testing.T) {
+	throttle := NewDomainThrottle(nil, map[string]Limit{
+		"example.com": {Count: 10, Window: time.Minute},
+	}, Limit{Count: 1000, Window: time.Minute})
+
+	cases := map[string]struct {
+		key   string
+		count int
+	}{
+		"example.com":      {"example.com", 10},
+		"mail.example.com": {"example.com", 10},
+		"Example.COM.":     {"example.com", 10},
+		"other.com":        {"other.com", 1000},
+	}
+	for domain, want := range cases {
+		key, limit := throttle.LimitFor(domain)
+		if key != want.key || limit.Count != want.count {
+			t.Errorf("LimitFor(%q) = %s %v, want %s %d", domain, key, limit, want.key, want.count)
+		}
+	}
+}
+
+func TestReserveAndRelease(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	throttle := NewDomainThrottle(client, map[string]Limit{"gmail.com": {Count: 2, Window: time.Minute}}, Limit{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if wait, err := throttle.Reserve(ctx, "gmail.com"); err != nil || wait != 0 {
+			t.Fatalf("reserve %d: wait %v, err %v", i+1, wait, err)
+		}
+	}
+	if wait, err := throttle.Reserve(ctx, "gmail.com"); err != nil || wait <= 0 {
+		t.Fatalf("reserve over limit: wait %v, err %v", wait, err)
+	}
+
+	if err := throttle.Release(ctx, "gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, err := throttle.Reserve(ctx, "gmail.com"); err != nil || wait != 0 {
+		t.Errorf("reserve after release: wait %v, err %v", wait, err)
+	}
+
+	// Releasing an expired or unused window does not go below zero
+	mr.FastForward(time.Minute)
+	if err := throttle.Release(ctx, "gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := client.Get(ctx, "email:throttle:gmail.com").Int(); got != 0 {
+		t.Errorf("counter after releasing an expired window = %d", got)
+	}
+
+	// Unlimited domains are not tracked
+	if err := throttle.Release(ctx, "example.com"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,153 @@
+package tracking
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidToken is returned for malformed or tampered tracking tokens
+var ErrInvalidToken = errors.New("invalid tracking token")
+
+// Target is what a tracking token refers to: the notification and, for
+// clicks, the original link
+type Target struct {
+	NotificationID string `json:"n"`
+	UserID         string `json:"u,omitempty"`
+	URL            string `json:"l,omitempty"`
+}
+
+// hrefRe matches the href attribute of anchor tags, capturing the quote and
+// the (HTML-escaped) value
+var hrefRe = regexp.MustCompile(`(?is)(<a\s[^>]*?\bhref\s*=\s*)(["'])(.*?)(["'])`)
+
+var bodyCloseRe = regexp.MustCompile(`(?i)</body\s*>`)
+
+// Tracker rewrites HTML for open and click tracking. Tokens are signed so
+// the redirect endpoint cannot be used as an open redirect. They do not
+// expire: links in old emails keep working.
+type Tracker struct {
+	secret  []byte
+	baseURL string
+}
+
+func NewTracker(secret, baseURL string) (*Tracker, error) {
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("tracking secret must be at least 16 characters")
+	}
+	if !strings.HasPrefix(baseURL, "https://") && !strings.HasPrefix(baseURL, "http://") {
+		return nil, fmt.Errorf("invalid tracking base URL %q", baseURL)
+	}
+
+	return &Tracker{
+		secret:  []byte(secret),
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// Instrument rewrites links in htmlBody to click-tracking redirects and adds
+// an open-tracking pixel, as requested
+func (t *Tracker) Instrument(htmlBody string, target Target, opens, clicks bool) (string, error) {
+	if clicks {
+		var err error
+		htmlBody = hrefRe.ReplaceAllStringFunc(htmlBody, func(match string) string {
+			parts := hrefRe.FindStringSubmatch(match)
+			prefix, quote, value := parts[1], parts[2], parts[3]
+			if quote != parts[4] || !isTrackable(html.UnescapeString(value)) {
+				return match
+			}
+
+			link := target
+			link.URL = html.UnescapeString(strings.TrimSpace(value))
+			token, tokenErr := t.Token(link)
+			if tokenErr != nil {
+				err = tokenErr
+				return match
+			}
+			return prefix + quote + html.EscapeString(t.ClickURL(token)) + quote
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if opens {
+		token, err := t.Token(Target{NotificationID: target.NotificationID, UserID: target.UserID})
+		if err != nil {
+			return "", err
+		}
+		pixel := `<img src="` + html.EscapeString(t.OpenURL(token)) + `" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+
+		// Place the pixel last in the body so it loads after the content
+		if loc := bodyCloseRe.FindStringIndex(htmlBody); loc != nil {
+			htmlBody = htmlBody[:loc[0]] + pixel + htmlBody[loc[0]:]
+		} else {
+			htmlBody += pixel
+		}
+	}
+
+	return htmlBody, nil
+}
+
+// isTrackable reports whether a link can be sent through the redirect.
+// Anchors, mailto: and tel: links and template placeholders are left alone.
+func isTrackable(link string) bool {
+	link = strings.TrimSpace(link)
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+// Token signs a target. The format is the base64url JSON target and its
+// HMAC-SHA256, joined by a dot.
+func (t *Tracker) Token(target Target) (string, error) {
+	payload, err := json.Marshal(target)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + t.sign(encoded), nil
+}
+
+// Verify checks a token's signature and returns its target
+func (t *Tracker) Verify(token string) (*Target, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(t.sign(encoded))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var target Target
+	if err := json.Unmarshal(payload, &target); err != nil || target.NotificationID == "" {
+		return nil, ErrInvalidToken
+	}
+	return &target, nil
+}
+
+// OpenURL returns the pixel URL for a token
+func (t *Tracker) OpenURL(token string) string {
+	return t.baseURL + "/api/v1/track/open/" + token
+}
+
+// ClickURL returns the redirect URL for a token
+func (t *Tracker) ClickURL(token string) string {
+	return t.baseURL + "/api/v1/track/click/" + token
+}
+
+func (t *Tracker) sign(encoded string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package tracking
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newTestTracker(t *testing.T) *Tracker {
+	t.Helper()
+
+	tracker, err := NewTracker("0123456789abcdef-secret", "https://email.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracker
+}
+
+func TestInstrumentRewritesLinksAndAddsPixel(t *testing.T) {
+	tracker := newTestTracker(t)
+	body := `<html><body><p><a href="https://example.com/a?x=1&amp;y=2">A</a>
+<a class="btn" href='http://example.com/b'>B</a>
+<a href="mailto:help@example.com">Mail</a> <a href="#top">Top</a> <a href="{{link}}">T</a></p></BODY></html>`
+
+	out, err := tracker.Instrument(body, Target{NotificationID: "notif-1", UserID: "user-1"}, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, untouched := range []string{`href="mailto:help@example.com"`, `href="#top"`, `href="{{link}}"`} {
+		if !strings.Contains(out, untouched) {
+			t.Errorf("%s was rewritten: %s", untouched, out)
+		}
+	}
+
+	clicks := regexp.MustCompile(`https://email\.example\.com/api/v1/track/click/([\w.-]+)`).FindAllStringSubmatch(out, -1)
+	if len(clicks) != 2 {
+		t.Fatalf("found %d click links, want 2: %s", len(clicks), out)
+	}
+	want := []string{"https://example.com/a?x=1&y=2", "http://example.com/b"}
+	for i, match := range clicks {
+		target, err := tracker.Verify(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target.URL != want[i] || target.NotificationID != "notif-1" || target.UserID != "user-1" {
+			t.Errorf("click %d target = %+v", i, target)
+		}
+	}
+
+	pixel := strings.Index(out, "/api/v1/track/open/")
+	if pixel < 0 || pixel > strings.Index(out, "</BODY>") {
+		t.Errorf("pixel missing or outside body: %s", out)
+	}
+}
+
+func TestInstrumentHonoursOptIns(t *testing.T) {
+	tracker := newTestTracker(t)
+	body := `<a href="https://example.com">x</a>`
+
+	out, _ := tracker.Instrument(body, Target{NotificationID: "n"}, true, false)
+	if !strings.Contains(out, `href="https://example.com"`) || !strings.Contains(out, "/track/open/") {
+		t.Errorf("opens only: %s", out)
+	}
+	out, _ = tracker.Instrument(body, Target{NotificationID: "n"}, false, true)
+	if strings.Contains(out, "/track/open/") || !strings.Contains(out, "/track/click/") {
+		t.Errorf("clicks only: %s", out)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	tracker := newTestTracker(t)
+	token, _ := tracker.Token(Target{NotificationID: "n", URL: "https://example.com"})
+
+	forged, _ := tracker.Token(Target{NotificationID: "n", URL: "https://evil.example"})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	for _, bad := range []string{"", "garbage", payload + "." + signature, token + "x"} {
+		if _, err := tracker.Verify(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%q) = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+
+	other, _ := NewTracker("another-secret-of-16+", "https://email.example.com")
+	if _, err := other.Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token verified with a different secret")
+	}
+}
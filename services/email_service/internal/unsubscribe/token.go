@@ -0,0 +1,132 @@
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for malformed or tampered tokens
+	ErrInvalidToken = errors.New("invalid unsubscribe token")
+	// ErrExpiredToken is returned for tokens past their expiry
+	ErrExpiredToken = errors.New("unsubscribe token has expired")
+)
+
+// Claims identify who is opting out of what
+type Claims struct {
+	UserID   string `json:"u"`
+	Category string `json:"c"`
+	Address  string `json:"e"`
+	Expires  int64  `json:"x"` // Unix seconds
+}
+
+// Signer issues and verifies unsubscribe tokens. A token is the base64url
+// JSON claims and their HMAC-SHA256, joined by a dot.
+type Signer struct {
+	secret  []byte
+	ttl     time.Duration
+	baseURL string
+	mailto  string
+	now     func() time.Time
+}
+
+// NewSigner creates a signer issuing tokens valid for ttl. baseURL is the
+// public URL of the email service; mailto optionally adds a mailto:
+// unsubscribe address for clients without one-click support.
+func NewSigner(secret string, ttl time.Duration, baseURL, mailto string) (*Signer, error) {
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("unsubscribe secret must be at least 16 characters")
+	}
+	if _, err := url.ParseRequestURI(baseURL); err != nil || !strings.HasPrefix(baseURL, "https://") && !strings.HasPrefix(baseURL, "http://") {
+		return nil, fmt.Errorf("invalid unsubscribe base URL %q", baseURL)
+	}
+
+	return &Signer{
+		secret:  []byte(secret),
+		ttl:     ttl,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		mailto:  mailto,
+		now:     time.Now,
+	}, nil
+}
+
+// Token issues a token for a user opting out of a category at an address
+func (s *Signer) Token(userID, category, address string) (string, error) {
+	payload, err := json.Marshal(Claims{
+		UserID:   userID,
+		Category: category,
+		Address:  address,
+		Expires:  s.now().Add(s.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded), nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (s *Signer) Verify(token string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(encoded))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Address == "" {
+		return nil, ErrInvalidToken
+	}
+	if s.now().Unix() >= claims.Expires {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+// Headers returns the List-Unsubscribe and List-Unsubscribe-Post (RFC 8058)
+// headers for a message
+func (s *Signer) Headers(userID, category, address string) (map[string]string, error) {
+	token, err := s.Token(userID, category, address)
+	if err != nil {
+		return nil, err
+	}
+
+	listUnsubscribe := "<" + s.URL(token) + ">"
+	if s.mailto != "" {
+		listUnsubscribe = "<mailto:" + s.mailto + "?subject=unsubscribe>, " + listUnsubscribe
+	}
+
+	return map[string]string{
+		"List-Unsubscribe":      listUnsubscribe,
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}, nil
+}
+
+// URL returns the one-click unsubscribe URL for a token
+func (s *Signer) URL(token string) string {
+	return s.baseURL + "/api/v1/unsubscribe?token=" + url.QueryEscape(token)
+}
+
+func (s *Signer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IsTransactional reports whether a category is transactional mail, which
+// never carries unsubscribe headers
+func IsTransactional(category string) bool {
+	return category == "" || strings.EqualFold(category, "transactional")
+}
@@ -0,0 +1,82 @@
+package unsubscribe
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+
+	signer, err := NewSigner("0123456789abcdef-secret", 24*time.Hour, "https://email.example.com/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	signer := newTestSigner(t)
+
+	token, err := signer.Token("user-42", "newsletter", "ade@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := signer.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != "user-42" || claims.Category != "newsletter" || claims.Address != "ade@example.com" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestTokenRejectsTamperingAndExpiry(t *testing.T) {
+	signer := newTestSigner(t)
+	token, _ := signer.Token("user-42", "newsletter", "ade@example.com")
+
+	// Re-sign the payload for another category with a different key
+	other, _ := NewSigner("another-secret-of-16+", time.Hour, "https://email.example.com", "")
+	forged, _ := other.Token("user-42", "billing", "ade@example.com")
+	payload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	if _, err := signer.Verify(payload + "." + signature); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("forged token: err = %v, want ErrInvalidToken", err)
+	}
+	if _, err := signer.Verify("garbage"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("garbage token: err = %v, want ErrInvalidToken", err)
+	}
+
+	signer.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	if _, err := signer.Verify(token); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("old token: err = %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	signer, err := NewSigner("0123456789abcdef-secret", time.Hour, "https://email.example.com", "unsubscribe@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers, err := signer.Headers("user-42", "newsletter", "ade@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post = %q", headers["List-Unsubscribe-Post"])
+	}
+
+	value := headers["List-Unsubscribe"]
+	if !strings.HasPrefix(value, "<mailto:unsubscribe@example.com?subject=unsubscribe>, <https://email.example.com/api/v1/unsubscribe?token=") {
+		t.Fatalf("List-Unsubscribe = %q", value)
+	}
+
+	link, _ := url.Parse(strings.TrimSuffix(value[strings.LastIndex(value, "<")+1:], ">"))
+	if _, err := signer.Verify(link.Query().Get("token")); err != nil {
+		t.Errorf("token in header does not verify: %v", err)
+	}
+}
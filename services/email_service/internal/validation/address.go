@@ -0,0 +1,278 @@
+package validation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// Reasons reported by InvalidAddressError
+const (
+	ReasonSyntax     = "syntax"
+	ReasonDisposable = "disposable_domain"
+	ReasonNoMX       = "no_mail_server"
+)
+
+// InvalidAddressError is returned for recipients that can never be delivered
+// to. It is permanent, so the message is failed without retries.
+type InvalidAddressError struct {
+	Address string
+	Reason  string
+	Detail  string
+}
+
+func (e *InvalidAddressError) Error() string {
+	return fmt.Sprintf("invalid email address %q (%s): %s", e.Address, e.Reason, e.Detail)
+}
+
+// Permanent marks the error as not worth retrying
+func (e *InvalidAddressError) Permanent() bool {
+	return true
+}
+
+// Resolver looks up mail exchangers. *net.Resolver satisfies it; tests use
+// a fake.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// defaultDisposableDomains is a small built-in list of throwaway mailbox
+// providers, extended with ADDRESS_DISPOSABLE_DOMAINS_FILE
+var defaultDisposableDomains = []string{
+	"10minutemail.com",
+	"discard.email",
+	"dispostable.com",
+	"getnada.com",
+	"guerrillamail.com",
+	"mailinator.com",
+	"maildrop.cc",
+	"sharklasers.com",
+	"temp-mail.org",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+const (
+	mxCacheTTL      = time.Hour
+	maxLocalPartLen = 64
+	maxDomainLen    = 253
+	maxLabelLen     = 63
+)
+
+var errNullMX = errors.New("domain does not accept mail (null MX)")
+
+type mxResult struct {
+	err     error // nil when the domain accepts mail
+	expires time.Time
+}
+
+// Validator checks recipient addresses before a send: syntax, disposable
+// domains and, when a resolver is set, that the domain accepts mail
+type Validator struct {
+	disposable map[string]struct{}
+	resolver   Resolver // nil skips the MX check
+	mxTimeout  time.Duration
+
+	mu      sync.Mutex
+	mxCache map[string]mxResult
+}
+
+// NewValidator creates a validator. blockDisposable enables the built-in
+// disposable list plus extraDisposable; resolver may be nil.
+func NewValidator(blockDisposable bool, extraDisposable []string, resolver Resolver, mxTimeout time.Duration) *Validator {
+	disposable := make(map[string]struct{})
+	if blockDisposable {
+		for _, domain := range append(defaultDisposableDomains, extraDisposable...) {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				disposable[domain] = struct{}{}
+			}
+		}
+	}
+
+	return &Validator{
+		disposable: disposable,
+		resolver:   resolver,
+		mxTimeout:  mxTimeout,
+		mxCache:    make(map[string]mxResult),
+	}
+}
+
+// LoadDomainList reads one domain per line, ignoring blanks and # comments
+func LoadDomainList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open domain list: %w", err)
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			domains = append(domains, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read domain list: %w", err)
+	}
+	return domains, nil
+}
+
+// Validate returns an *InvalidAddressError when address cannot receive
+// mail. Lookup failures other than "no such domain" are not treated as
+// invalid, so a DNS outage does not fail mail.
+func (v *Validator) Validate(ctx context.Context, address string) error {
+	domain, err := CheckSyntax(address)
+	if err != nil {
+		return err
+	}
+
+	if v.isDisposable(domain) {
+		return &InvalidAddressError{Address: address, Reason: ReasonDisposable, Detail: domain + " is a disposable mailbox provider"}
+	}
+
+	if v.resolver != nil {
+		if err := v.checkMX(ctx, domain); err != nil {
+			return &InvalidAddressError{Address: address, Reason: ReasonNoMX, Detail: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// CheckSyntax validates an RFC 5322 address (with RFC 6531 UTF-8 local parts
+// and IDN domains) and returns its domain in ASCII (punycode) form
+func CheckSyntax(address string) (string, error) {
+	invalid := func(detail string) error {
+		return &InvalidAddressError{Address: address, Reason: ReasonSyntax, Detail: detail}
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", invalid(err.Error())
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	if len(local) > maxLocalPartLen {
+		return "", invalid("local part longer than 64 octets")
+	}
+	if strings.HasPrefix(domain, "[") {
+		return "", invalid("address literals are not accepted")
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return "", invalid(fmt.Sprintf("invalid domain %q: %v", domain, err))
+	}
+	ascii = strings.ToLower(ascii)
+	if len(ascii) > maxDomainLen {
+		return "", invalid("domain longer than 253 octets")
+	}
+
+	labels := strings.Split(ascii, ".")
+	if len(labels) < 2 {
+		return "", invalid(fmt.Sprintf("domain %q is not fully qualified", domain))
+	}
+	for _, label := range labels {
+		if !validLabel(label) {
+			return "", invalid(fmt.Sprintf("invalid domain label %q", label))
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return "", invalid("top-level domain is numeric")
+	}
+
+	return ascii, nil
+}
+
+// validLabel checks an ASCII DNS label: letters, digits and inner hyphens
+func validLabel(label string) bool {
+	if label == "" || len(label) > maxLabelLen || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDisposable matches the domain and its parent domains against the list
+func (v *Validator) isDisposable(domain string) bool {
+	for {
+		if _, ok := v.disposable[domain]; ok {
+			return true
+		}
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			return false
+		}
+		domain = parent
+	}
+}
+
+// checkMX verifies the domain has a mail server. Definite answers are cached
+// for an hour; lookup failures are not cached and let the send go ahead.
+func (v *Validator) checkMX(ctx context.Context, domain string) error {
+	v.mu.Lock()
+	cached, ok := v.mxCache[domain]
+	v.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.err
+	}
+
+	if v.mxTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.mxTimeout)
+		defer cancel()
+	}
+
+	err := v.lookupMX(ctx, domain)
+	if err != nil && !isNotFound(err) && !errors.Is(err, errNullMX) {
+		return nil
+	}
+
+	v.mu.Lock()
+	v.mxCache[domain] = mxResult{err: err, expires: time.Now().Add(mxCacheTTL)}
+	v.mu.Unlock()
+	return err
+}
+
+// lookupMX accepts MX records that are not a RFC 7505 null MX, or an address
+// record as the RFC 5321 implicit MX
+func (v *Validator) lookupMX(ctx context.Context, domain string) error {
+	records, err := v.resolver.LookupMX(ctx, domain)
+	if len(records) > 0 {
+		if len(records) == 1 && (records[0].Host == "." || records[0].Host == "") {
+			return errNullMX
+		}
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	if _, err := v.resolver.LookupHost(ctx, domain); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers from maps; missing names are NXDOMAIN
+type fakeResolver struct {
+	mx      map[string][]*net.MX
+	hosts   map[string][]string
+	fail    error
+	lookups int
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.lookups++
+	if r.fail != nil {
+		return nil, r.fail
+	}
+	if records, ok := r.mx[name]; ok {
+		return records, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func reasonOf(err error) string {
+	var invalid *InvalidAddressError
+	if errors.As(err, &invalid) {
+		return invalid.Reason
+	}
+	return ""
+}
+
+func TestCheckSyntax(t *testing.T) {
+	cases := []struct {
+		address string
+		domain  string // empty when invalid
+	}{
+		{"user@example.com", "example.com"},
+		{"Ade <first.last+tag@Mail.Example.COM>", "mail.example.com"},
+		{"user@bücher.example", "xn--bcher-kva.example"},
+		{"用户@例子.广告", "xn--fsqu00a.xn--4rr70v"},
+		{"user@example", ""},
+		{"user@-example.com", ""},
+		{"user@exa_mple.com", ""},
+		{"user@[192.0.2.1]", ""},
+		{"user@example.123", ""},
+		{"plainaddress", ""},
+		{"a@b@example.com", ""},
+		{"user@example.com\r\nBcc: x@example.com", ""},
+		{strings.Repeat("a", 65) + "@example.com", ""},
+	}
+
+	for _, tc := range cases {
+		domain, err := CheckSyntax(tc.address)
+		if tc.domain == "" {
+			if reasonOf(err) != ReasonSyntax {
+				t.Errorf("CheckSyntax(%q) = %q, %v; want syntax error", tc.address, domain, err)
+			}
+			continue
+		}
+		if err != nil || domain != tc.domain {
+			t.Errorf("CheckSyntax(%q) = %q, %v; want %q", tc.address, domain, err, tc.domain)
+		}
+	}
+}
+
+func TestValidateDisposableDomains(t *testing.T) {
+	validator := NewValidator(true, []string{"Burner.Example"}, nil, 0)
+
+	for _, address := range []string{"x@mailinator.com", "x@eu.mailinator.com", "x@burner.example"} {
+		if err := validator.Validate(context.Background(), address); reasonOf(err) != ReasonDisposable {
+			t.Errorf("Validate(%q) = %v, want disposable", address, err)
+		}
+	}
+	if err := validator.Validate(context.Background(), "x@notmailinator.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := NewValidator(false, nil, nil, 0).Validate(context.Background(), "x@mailinator.com"); err != nil {
+		t.Errorf("disposable check should be off: %v", err)
+	}
+}
+
+func TestValidateMX(t *testing.T) {
+	resolver := &fakeResolver{
+		mx: map[string][]*net.MX{
+			"example.com":    {{Host: "mx.example.com.", Pref: 10}},
+			"nullmx.example": {{Host: ".", Pref: 0}},
+		},
+		hosts: map[string][]string{"implicit.example": {"192.0.2.10"}},
+	}
+	validator := NewValidator(false, nil, resolver, 0)
+	ctx := context.Background()
+
+	for _, address := range []string{"a@example.com", "a@implicit.example"} {
+		if err := validator.Validate(ctx, address); err != nil {
+			t.Errorf("Validate(%q) = %v", address, err)
+		}
+	}
+	for _, address := range []string{"a@nullmx.example", "a@missing.example"} {
+		if err := validator.Validate(ctx, address); reasonOf(err) != ReasonNoMX {
+			t.Errorf("Validate(%q) = %v, want no MX", address, err)
+		}
+	}
+
+	// Answers are cached per domain
+	lookups := resolver.lookups
+	_ = validator.Validate(ctx, "b@missing.example")
+	if resolver.lookups != lookups {
+		t.Errorf("expected a cached answer")
+	}
+}
+
+func TestValidateIgnoresLookupFailures(t *testing.T) {
+	resolver := &fakeResolver{fail: &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}}
+	if err := NewValidator(false, nil, resolver, 0).Validate(context.Background(), "a@example.com"); err != nil {
+		t.Errorf("temporary DNS failure should not invalidate the address: %v", err)
+	}
+}
+
+func TestInvalidAddressErrorIsPermanent(t *testing.T) {
+	_, err := CheckSyntax("nope")
+	var permanent interface{ Permanent() bool }
+	if !errors.As(err, &permanent) || !permanent.Permanent() {
+		t.Errorf("%v is not marked permanent", err)
+	}
+}
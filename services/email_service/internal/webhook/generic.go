@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Generic webhooks are signed with HMAC-SHA256 over "<timestamp>.<body>" using
+// the shared WEBHOOK_SECRET, hex encoded with an optional "sha256=" prefix
+const (
+	GenericSignatureHeader = "X-Webhook-Signature"
+	GenericTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// genericStatuses accepts the canonical statuses and common provider synonyms
+var genericStatuses = map[string]string{
+	StatusDelivered:  StatusDelivered,
+	"delivery":       StatusDelivered,
+	StatusBounced:    StatusBounced,
+	"bounce":         StatusBounced,
+	StatusComplained: StatusComplained,
+	"complaint":      StatusComplained,
+	"spamreport":     StatusComplained,
+	StatusDeferred:   StatusDeferred,
+	"deferral":       StatusDeferred,
+}
+
+type genericEvent struct {
+	Provider          string    `json:"provider"`
+	ProviderMessageID string    `json:"provider_message_id"`
+	NotificationID    string    `json:"notification_id"`
+	Event             string    `json:"event"`
+	Recipient         string    `json:"recipient"`
+	Reason            string    `json:"reason"`
+	BounceType        string    `json:"bounce_type"` // "hard" (default) or "soft"
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// SignGeneric returns the signature for a generic webhook body
+func SignGeneric(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyGenericSignature checks the HMAC signature of a generic webhook
+// request and rejects timestamps further than tolerance from now
+func VerifyGenericSignature(secret []byte, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	if err := checkTimestamp(timestamp, tolerance); err != nil {
+		return err
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want, _ := hex.DecodeString(SignGeneric(secret, timestamp, body))
+	if !hmac.Equal(got, want) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParseGenericEvents decodes a single event object or an array of them.
+// Every event needs a known status and a provider message or notification ID.
+func ParseGenericEvents(body []byte) ([]Event, error) {
+	var raw []genericEvent
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		var single genericEvent
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return nil, fmt.Errorf("invalid event payload: %w", err)
+		}
+		raw = append(raw, single)
+	} else if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid event payload: %w", err)
+	}
+
+	events := make([]Event, 0, len(raw))
+	for i, e := range raw {
+		status, ok := genericStatuses[strings.ToLower(e.Event)]
+		if !ok {
+			return nil, fmt.Errorf("event %d: unknown event type %q", i, e.Event)
+		}
+		if e.ProviderMessageID == "" && e.NotificationID == "" {
+			return nil, fmt.Errorf("event %d: provider_message_id or notification_id is required", i)
+		}
+
+		provider := e.Provider
+		if provider == "" {
+			provider = "generic"
+		}
+		events = append(events, Event{
+			Provider:          provider,
+			ProviderMessageID: e.ProviderMessageID,
+			NotificationID:    e.NotificationID,
+			Status:            status,
+			Recipient:         e.Recipient,
+			Reason:            e.Reason,
+			Timestamp:         e.Timestamp,
+			Permanent: status == StatusComplained ||
+				status == StatusBounced && !strings.EqualFold(e.BounceType, "soft"),
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MessageRef identifies the notification behind a provider message ID
+type MessageRef struct {
+	NotificationID string `json:"notification_id"`
+	UserID         string `json:"user_id"`
+	Provider       string `json:"provider"`
+}
+
+// MessageIndex maps provider message IDs to notifications so webhook events,
+// which only carry the provider's ID, can be attributed
+type MessageIndex struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewMessageIndex(redis *redis.Client, ttl time.Duration) *MessageIndex {
+	return &MessageIndex{
+		redis: redis,
+		ttl:   ttl,
+	}
+}
+
+// Record stores the notification for a provider message ID
+func (i *MessageIndex) Record(ctx context.Context, providerMessageID string, ref MessageRef) error {
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	return i.redis.Set(ctx, indexKey(providerMessageID), data, i.ttl).Err()
+}
+
+// RecordRecipient stores the notification for one recipient of a provider
+// message with many, such as a SendGrid bulk send sharing one X-Message-Id
+func (i *MessageIndex) RecordRecipient(ctx context.Context, providerMessageID, recipient string, ref MessageRef) error {
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	return i.redis.Set(ctx, recipientKey(providerMessageID, recipient), data, i.ttl).Err()
+}
+
+// Lookup returns the notification for a provider message ID and recipient,
+// preferring an entry for the recipient over one for the whole message. It
+// returns nil when neither is known or both have expired.
+func (i *MessageIndex) Lookup(ctx context.Context, providerMessageID, recipient string) (*MessageRef, error) {
+	keys := []string{indexKey(providerMessageID)}
+	if recipient != "" {
+		keys = append([]string{recipientKey(providerMessageID, recipient)}, keys...)
+	}
+	values, err := i.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var ref MessageRef
+		if err := json.Unmarshal([]byte(data), &ref); err != nil {
+			return nil, fmt.Errorf("invalid message index entry: %w", err)
+		}
+		return &ref, nil
+	}
+	return nil, nil
+}
+
+func indexKey(providerMessageID string) string {
+	return fmt.Sprintf("email:provider_message:%s", providerMessageID)
+}
+
+func recipientKey(providerMessageID, recipient string) string {
+	return fmt.Sprintf("email:provider_message:%s:%s", providerMessageID, strings.ToLower(strings.TrimSpace(recipient)))
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMessageIndexRecipients(t *testing.T) {
+	processor, _, publisher, _ := newTestProcessor(t)
+	ctx := context.Background()
+	index := processor.index
+
+	// A bulk send: one provider ID shared by every recipient
+	for _, ref := range []struct{ recipient, notificationID, userID string }{
+		{"ade@example.com", "notif-1", "user-1"},
+		{"Zoe@Example.com", "notif-2", "user-2"},
+	} {
+		err := index.RecordRecipient(ctx, "bulk-1", ref.recipient, MessageRef{NotificationID: ref.notificationID, UserID: ref.userID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ref, err := index.Lookup(ctx, "bulk-1", "zoe@example.com")
+	if err != nil || ref == nil || ref.NotificationID != "notif-2" {
+		t.Fatalf("Lookup(zoe) = %+v, %v, want notif-2", ref, err)
+	}
+	if ref, _ := index.Lookup(ctx, "bulk-1", "other@example.com"); ref != nil {
+		t.Errorf("Lookup(other) = %+v, want nil", ref)
+	}
+
+	// A single send is found by its ID whatever the recipient
+	if err := index.Record(ctx, "single-1", MessageRef{NotificationID: "notif-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if ref, _ := index.Lookup(ctx, "single-1", "ola@example.com"); ref == nil || ref.NotificationID != "notif-3" {
+		t.Errorf("Lookup(single-1) = %+v, want notif-3", ref)
+	}
+
+	// Events for a bulk send are attributed per recipient
+	err = processor.Process(ctx, []Event{
+		{Provider: "sendgrid", ProviderMessageID: "bulk-1", Status: StatusDelivered, Recipient: "ade@example.com"},
+		{Provider: "sendgrid", ProviderMessageID: "bulk-1", Status: StatusDelivered, Recipient: "zoe@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"user-1", "user-2"} {
+		if got := publisher.published[i].UserID; got != want {
+			t.Errorf("event %d attributed to %q, want %q", i, got, want)
+		}
+	}
+}
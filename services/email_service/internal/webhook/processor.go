@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Delivery statuses reported by provider webhooks
+const (
+	StatusDelivered  = "delivered"
+	StatusBounced    = "bounced"
+	StatusComplained = "complained"
+	StatusDeferred   = "deferred"
+)
+
+// Event is a provider event mapped to one of the delivery statuses
+type Event struct {
+	Provider          string
+	ProviderMessageID string
+	NotificationID    string // set when the provider echoes it back
+	Status            string
+	Recipient         string
+	Reason            string
+	Timestamp         time.Time
+	Permanent         bool // hard bounce or complaint: the recipient is suppressed
+}
+
+// StatusPublisher publishes status updates to the status queue
+type StatusPublisher interface {
+	PublishStatus(ctx context.Context, status models.StatusMessage) error
+}
+
+// Processor turns webhook events into status messages, attributing them to
+// notifications through the message index
+type Processor struct {
+	index        *MessageIndex
+	publisher    StatusPublisher
+	suppressions suppression.Store
+}
+
+func NewProcessor(index *MessageIndex, publisher StatusPublisher, suppressions suppression.Store) *Processor {
+	return &Processor{
+		index:        index,
+		publisher:    publisher,
+		suppressions: suppressions,
+	}
+}
+
+// Process publishes a status message for each event and suppresses the
+// recipients of hard bounces and complaints. Events whose provider message
+// ID is unknown are still published, keyed by that ID alone.
+//
+// The whole batch is resolved before anything is published, so an error
+// leaves nothing published and the provider can safely retry. Once
+// publishing starts, failures are logged and skipped rather than returned: a
+// retry would publish the earlier events a second time.
+func (p *Processor) Process(ctx context.Context, events []Event) error {
+	statuses := make([]models.StatusMessage, 0, len(events))
+	for _, event := range events {
+		status, err := p.resolve(ctx, event)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+
+	for i, event := range events {
+		if err := p.suppress(ctx, event); err != nil {
+			logger.Log.Error("failed to suppress webhook recipient", zap.Error(err),
+				zap.String("provider", event.Provider),
+				zap.String("provider_message_id", event.ProviderMessageID),
+			)
+		}
+
+		if err := p.publisher.PublishStatus(ctx, statuses[i]); err != nil {
+			logger.Log.Error("failed to publish webhook status", zap.Error(err),
+				zap.String("provider", event.Provider),
+				zap.String("provider_message_id", event.ProviderMessageID),
+				zap.String("status", event.Status),
+			)
+		}
+	}
+
+	return nil
+}
+
+// resolve builds the status message for an event, attributing it through the
+// message index
+func (p *Processor) resolve(ctx context.Context, event Event) (models.StatusMessage, error) {
+	status := models.StatusMessage{
+		NotificationID:    event.NotificationID,
+		Status:            event.Status,
+		Timestamp:         event.Timestamp,
+		Error:             event.Reason,
+		Provider:          event.Provider,
+		ProviderMessageID: event.ProviderMessageID,
+	}
+	if status.Timestamp.IsZero() {
+		status.Timestamp = time.Now()
+	}
+
+	var ref *MessageRef
+	if event.ProviderMessageID != "" {
+		var err error
+		if ref, err = p.index.Lookup(ctx, event.ProviderMessageID, event.Recipient); err != nil {
+			return status, fmt.Errorf("failed to look up provider message %s: %w", event.ProviderMessageID, err)
+		}
+	}
+	if ref != nil {
+		if status.NotificationID == "" {
+			status.NotificationID = ref.NotificationID
+		}
+		status.UserID = ref.UserID
+	} else {
+		logger.Log.Warn("webhook event for unknown provider message",
+			zap.String("provider", event.Provider),
+			zap.String("provider_message_id", event.ProviderMessageID),
+			zap.String("status", event.Status),
+		)
+	}
+
+	return status, nil
+}
+
+func (p *Processor) suppress(ctx context.Context, event Event) error {
+	if !event.Permanent || event.Recipient == "" {
+		return nil
+	}
+
+	reason := suppression.ReasonHardBounce
+	if event.Status == StatusComplained {
+		reason = suppression.ReasonComplaint
+	}
+	err := p.suppressions.Add(ctx, suppression.Entry{
+		Address: event.Recipient,
+		Reason:  reason,
+		Source:  "webhook:" + event.Provider,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to suppress %s: %w", event.Recipient, err)
+	}
+
+	logger.Log.Info("recipient suppressed",
+		zap.String("recipient", event.Recipient),
+		zap.String("reason", reason),
+		zap.String("provider", event.Provider),
+	)
+	return nil
+}
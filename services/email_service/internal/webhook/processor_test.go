@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/models"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/internal/suppression"
+	"github.com/brainox/hng-group55-distributed-notification-system/services/email_service/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// fakePublisher records published statuses and fails for the provider
+// message IDs in failFor
+type fakePublisher struct {
+	published []models.StatusMessage
+	failFor   map[string]bool
+}
+
+func (p *fakePublisher) PublishStatus(ctx context.Context, status models.StatusMessage) error {
+	if p.failFor[status.ProviderMessageID] {
+		return errors.New("channel closed")
+	}
+	p.published = append(p.published, status)
+	return nil
+}
+
+func newTestProcessor(t *testing.T) (*Processor, *miniredis.Miniredis, *fakePublisher, *suppression.MemoryStore) {
+	t.Helper()
+	logger.Log = zap.NewNop()
+
+	mr := miniredis.RunT(t)
+	index := NewMessageIndex(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Hour)
+	publisher := &fakePublisher{failFor: map[string]bool{}}
+	suppressions := suppression.NewMemoryStore()
+	return NewProcessor(index, publisher, suppressions), mr, publisher, suppressions
+}
+
+var processorBatch = []Event{
+	{Provider: "sendgrid", ProviderMessageID: "msg-1", Status: StatusDelivered, Recipient: "ade@example.com"},
+	{Provider: "sendgrid", ProviderMessageID: "msg-2", Status: StatusBounced, Recipient: "zoe@example.com", Permanent: true},
+	{Provider: "sendgrid", ProviderMessageID: "msg-3", Status: StatusDelivered, Recipient: "ola@example.com"},
+}
+
+func TestProcessAttributesAndSuppresses(t *testing.T) {
+	processor, _, publisher, suppressions := newTestProcessor(t)
+	ctx := context.Background()
+
+	ref := MessageRef{NotificationID: "notif-1", UserID: "user-1", Provider: "sendgrid"}
+	if err := processor.index.Record(ctx, "msg-1", ref); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processor.Process(ctx, processorBatch); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 3 {
+		t.Fatalf("published %d statuses, want 3", len(publisher.published))
+	}
+	if got := publisher.published[0]; got.NotificationID != "notif-1" || got.UserID != "user-1" {
+		t.Errorf("status not attributed: %+v", got)
+	}
+	if got := publisher.published[2]; got.NotificationID != "" || got.ProviderMessageID != "msg-3" || got.Timestamp.IsZero() {
+		t.Errorf("unknown message status = %+v", got)
+	}
+	if entry, _ := suppressions.Get(ctx, "zoe@example.com", ""); entry == nil || entry.Reason != suppression.ReasonHardBounce {
+		t.Errorf("hard bounce not suppressed: %+v", entry)
+	}
+}
+
+func TestProcessLookupFailurePublishesNothing(t *testing.T) {
+	processor, mr, publisher, _ := newTestProcessor(t)
+
+	mr.SetError("LOADING")
+	if err := processor.Process(context.Background(), processorBatch); err == nil {
+		t.Fatal("expected the lookup error")
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("published %d statuses before failing, a retry would repeat them", len(publisher.published))
+	}
+
+	// The retried batch is published exactly once
+	mr.SetError("")
+	if err := processor.Process(context.Background(), processorBatch); err != nil {
+		t.Fatal(err)
+	}
+	if len(publisher.published) != 3 {
+		t.Errorf("published %d statuses on retry, want 3", len(publisher.published))
+	}
+}
+
+func TestProcessSkipsPublishFailures(t *testing.T) {
+	processor, _, publisher, _ := newTestProcessor(t)
+	publisher.failFor["msg-2"] = true
+
+	// Returning an error would make the provider retry and republish msg-1
+	if err := processor.Process(context.Background(), processorBatch); err != nil {
+		t.Fatalf("Process() = %v, want the failure logged and skipped", err)
+	}
+	var ids []string
+	for _, status := range publisher.published {
+		ids = append(ids, status.ProviderMessageID)
+	}
+	if len(ids) != 2 || ids[0] != "msg-1" || ids[1] != "msg-3" {
+		t.Errorf("published %v, want [msg-1 msg-3]", ids)
+	}
+}
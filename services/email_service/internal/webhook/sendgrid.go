@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SendGrid signs event webhooks with ECDSA over the timestamp followed by the
+// raw request body
+const (
+	SendGridSignatureHeader = "X-Twilio-Email-Event-Webhook-Signature"
+	SendGridTimestampHeader = "X-Twilio-Email-Event-Webhook-Timestamp"
+)
+
+// ErrInvalidSignature is returned when a webhook request fails verification
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// sendGridStatuses maps SendGrid delivery events to statuses. Engagement
+// events (open, click, unsubscribe) and "processed" are ignored.
+var sendGridStatuses = map[string]string{
+	"delivered":  StatusDelivered,
+	"bounce":     StatusBounced,
+	"dropped":    StatusBounced,
+	"spamreport": StatusComplained,
+	"deferred":   StatusDeferred,
+}
+
+// ParseSendGridPublicKey parses the base64 DER verification key shown in the
+// SendGrid mail settings
+func ParseSendGridPublicKey(encoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SendGrid webhook public key: %w", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SendGrid webhook public key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("SendGrid webhook public key is %T, want ECDSA", key)
+	}
+	return ecKey, nil
+}
+
+// VerifySendGridSignature checks the signature of a SendGrid event webhook
+// request and rejects timestamps further than tolerance from now
+func VerifySendGridSignature(key *ecdsa.PublicKey, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	if err := checkTimestamp(timestamp, tolerance); err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(timestamp))
+	hash.Write(body)
+	if !ecdsa.VerifyASN1(key, hash.Sum(nil), sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type sendGridEvent struct {
+	Event          string `json:"event"`
+	Email          string `json:"email"`
+	Timestamp      int64  `json:"timestamp"`
+	SGMessageID    string `json:"sg_message_id"`
+	Reason         string `json:"reason"`
+	Response       string `json:"response"`
+	Type           string `json:"type"`            // bounce events: "bounce" or "blocked"
+	NotificationID string `json:"notification_id"` // custom_args, when set
+}
+
+// ParseSendGridEvents decodes a SendGrid event batch, keeping only delivery
+// events
+func ParseSendGridEvents(body []byte) ([]Event, error) {
+	var raw []sendGridEvent
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid SendGrid event payload: %w", err)
+	}
+
+	events := make([]Event, 0, len(raw))
+	for _, e := range raw {
+		status, ok := sendGridStatuses[e.Event]
+		if !ok {
+			continue
+		}
+
+		reason := e.Reason
+		if reason == "" {
+			reason = e.Response
+		}
+
+		events = append(events, Event{
+			Provider:          "sendgrid",
+			ProviderMessageID: sendGridMessageID(e.SGMessageID),
+			NotificationID:    e.NotificationID,
+			Status:            status,
+			Recipient:         e.Email,
+			Reason:            reason,
+			Timestamp:         time.Unix(e.Timestamp, 0),
+			// Blocked messages were refused for the content or sending IP,
+			// not because the mailbox is bad
+			Permanent: (e.Event == "bounce" && e.Type != "blocked") || e.Event == "spamreport",
+		})
+	}
+	return events, nil
+}
+
+// sendGridMessageID reduces sg_message_id ("<X-Message-Id>.filter...") to the
+// X-Message-Id returned when the message was sent
+func sendGridMessageID(sgMessageID string) string {
+	id, _, _ := strings.Cut(sgMessageID, ".")
+	return id
+}
+
+// checkTimestamp rejects webhook timestamps (Unix seconds) outside the
+// tolerance, limiting replays of captured requests
+func checkTimestamp(timestamp string, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		skew := time.Since(time.Unix(seconds, 0))
+		if skew > tolerance || skew < -tolerance {
+			return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const sendGridBatch = `[
+	{"email":"ade@example.com","timestamp":1700000000,"event":"processed","sg_message_id":"W86EgYT6SQKk0lRflfLRsA.filterdrecv-1"},
+	{"email":"ade@example.com","timestamp":1700000001,"event":"delivered","sg_message_id":"W86EgYT6SQKk0lRflfLRsA.filterdrecv-1","response":"250 OK"},
+	{"email":"zoe@example.com","timestamp":1700000002,"event":"bounce","sg_message_id":"Xy12.filterdrecv-2","reason":"550 5.1.1 No such user"},
+	{"email":"zoe@example.com","timestamp":1700000003,"event":"spamreport","sg_message_id":"Xy12.filterdrecv-2"},
+	{"email":"zoe@example.com","timestamp":1700000004,"event":"open","sg_message_id":"Xy12.filterdrecv-2"}
+]`
+
+func TestSendGridSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey, err := ParseSendGridPublicKey(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	hash := sha256.Sum256([]byte(timestamp + sendGridBatch))
+	sig, _ := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	if err := VerifySendGridSignature(publicKey, signature, timestamp, []byte(sendGridBatch), time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+
+	tampered := []byte(sendGridBatch[:len(sendGridBatch)-2] + " ]")
+	if err := VerifySendGridSignature(publicKey, signature, timestamp, tampered, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered body: err = %v, want ErrInvalidSignature", err)
+	}
+
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	hash = sha256.Sum256([]byte(old + sendGridBatch))
+	sig, _ = ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err := VerifySendGridSignature(publicKey, base64.StdEncoding.EncodeToString(sig), old, []byte(sendGridBatch), time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("stale timestamp: err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestParseSendGridEvents(t *testing.T) {
+	events, err := ParseSendGridEvents([]byte(sendGridBatch))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		id, status, reason string
+		permanent          bool
+	}{
+		{"W86EgYT6SQKk0lRflfLRsA", StatusDelivered, "250 OK", false},
+		{"Xy12", StatusBounced, "550 5.1.1 No such user", true},
+		{"Xy12", StatusComplained, "", true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.ProviderMessageID != w.id || e.Status != w.status || e.Reason != w.reason || e.Permanent != w.permanent || e.Provider != "sendgrid" {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestGenericWebhook(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"provider":"postmark","provider_message_id":"abc-123","event":"Bounce","reason":"mailbox full"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	signature := "sha256=" + SignGeneric(secret, timestamp, body)
+	if err := VerifyGenericSignature(secret, signature, timestamp, body, time.Minute); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := VerifyGenericSignature([]byte("other"), signature, timestamp, body, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong secret: err = %v, want ErrInvalidSignature", err)
+	}
+
+	events, err := ParseGenericEvents(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Status != StatusBounced || events[0].Provider != "postmark" || events[0].ProviderMessageID != "abc-123" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	if _, err := ParseGenericEvents([]byte(`[{"provider_message_id":"x","event":"opened"}]`)); err == nil {
+		t.Error("expected unknown event type to be rejected")
+	}
+	if _, err := ParseGenericEvents([]byte(`[{"event":"delivered"}]`)); err == nil {
+		t.Error("expected event without an ID to be rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package response
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+type Response struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+func Success(c *gin.Context, code int, data interface{}, message string) {
+	c.JSON(code, Response{
+		Success: true,
+		Data:    data,
+		Message: message,
+	})
+}
+
+func Error(c *gin.Context, code int, err error, message string) {
+	c.JSON(code, Response{
+		Success: false,
+		Error:   err.Error(),
+		Message: message,
+	})
+}
+
+func ErrorMessage(c *gin.Context, code int, errorMsg string, message string) {
+	c.JSON(code, Response{
+		Success: false,
+		Error:   errorMsg,
+		Message: message,
+	})
+}